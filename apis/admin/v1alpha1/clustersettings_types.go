@@ -0,0 +1,125 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	xpv2 "github.com/crossplane/crossplane-runtime/v2/apis/common/v2"
+)
+
+// Scheduler profiles supported by Orchard.
+const (
+	SchedulerProfileOptimizeUtilization = "optimize-utilization"
+	SchedulerProfileDistributeLoad      = "distribute-load"
+)
+
+// HostDirPolicy allows VMs to mount host directories under a path prefix.
+type HostDirPolicy struct {
+	// PathPrefix is the host path prefix that VM hostDirs must match
+	// +kubebuilder:validation:Required
+	PathPrefix string `json:"pathPrefix"`
+
+	// Ro restricts matching mounts to read-only
+	// +optional
+	Ro *bool `json:"ro,omitempty"`
+}
+
+// ClusterSettingsParameters are the configurable fields of ClusterSettings.
+type ClusterSettingsParameters struct {
+	// SchedulerProfile is the scheduler profile to use. optimize-utilization
+	// picks the busiest worker that can fit a VM first (Orchard's default),
+	// distribute-load picks the least occupied one.
+	// +kubebuilder:validation:Enum=optimize-utilization;distribute-load
+	// +optional
+	SchedulerProfile *string `json:"schedulerProfile,omitempty"`
+
+	// HostDirPolicies, if not empty, only allow VMs with hostDirs that match
+	// one of the listed policies
+	// +optional
+	HostDirPolicies []HostDirPolicy `json:"hostDirPolicies,omitempty"`
+}
+
+// ClusterSettingsObservation are the observable fields of ClusterSettings.
+type ClusterSettingsObservation struct {
+	// SchedulerProfile is the scheduler profile currently in effect
+	SchedulerProfile string `json:"schedulerProfile,omitempty"`
+
+	// HostDirPolicies are the hostDir policies currently in effect
+	HostDirPolicies []HostDirPolicy `json:"hostDirPolicies,omitempty"`
+}
+
+// A ClusterSettingsSpec defines the desired state of ClusterSettings.
+type ClusterSettingsSpec struct {
+	xpv2.ManagedResourceSpec `json:",inline"`
+	ForProvider              ClusterSettingsParameters `json:"forProvider"`
+}
+
+// A ClusterSettingsStatus represents the observed state of ClusterSettings.
+type ClusterSettingsStatus struct {
+	xpv1.ResourceStatus `json:",inline"`
+	AtProvider          ClusterSettingsObservation `json:"atProvider,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterSettings is a managed resource that represents the settings of an
+// Orchard cluster. Orchard has exactly one set of cluster settings, so the
+// resource must be named "cluster" and reference a ClusterProviderConfig.
+// Deleting it with the Delete management policy restores Orchard's defaults;
+// omit Delete from the management policies to leave the settings in place.
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="SYNCED",type="string",JSONPath=".status.conditions[?(@.type=='Synced')].status"
+// +kubebuilder:printcolumn:name="SCHEDULER",type="string",JSONPath=".status.atProvider.schedulerProfile"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,categories={crossplane,managed,orchard}
+// +kubebuilder:validation:XValidation:rule="self.metadata.name == 'cluster'",message="ClusterSettings is a singleton and must be named 'cluster'"
+// +kubebuilder:rbac:groups=admin.orchard.crossplane.io,resources=clustersettings,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=admin.orchard.crossplane.io,resources=clustersettings/status,verbs=get;update;patch
+type ClusterSettings struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterSettingsSpec   `json:"spec"`
+	Status ClusterSettingsStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterSettingsList contains a list of ClusterSettings
+type ClusterSettingsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterSettings `json:"items"`
+}
+
+// ClusterSettings type metadata.
+var (
+	ClusterSettingsKind             = reflect.TypeOf(ClusterSettings{}).Name()
+	ClusterSettingsGroupKind        = schema.GroupKind{Group: Group, Kind: ClusterSettingsKind}.String()
+	ClusterSettingsKindAPIVersion   = ClusterSettingsKind + "." + SchemeGroupVersion.String()
+	ClusterSettingsGroupVersionKind = SchemeGroupVersion.WithKind(ClusterSettingsKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&ClusterSettings{}, &ClusterSettingsList{})
+}
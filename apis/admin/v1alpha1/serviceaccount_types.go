@@ -0,0 +1,97 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	xpv2 "github.com/crossplane/crossplane-runtime/v2/apis/common/v2"
+)
+
+// ServiceAccountParameters are the configurable fields of a ServiceAccount.
+type ServiceAccountParameters struct {
+	// Roles granted to the service account
+	// +kubebuilder:validation:items:Enum="compute:read";"compute:write";"admin:read";"admin:write"
+	// +listType=set
+	// +optional
+	Roles []string `json:"roles,omitempty"`
+}
+
+// ServiceAccountObservation are the observable fields of a ServiceAccount.
+type ServiceAccountObservation struct {
+	// Roles currently granted to the service account in Orchard
+	Roles []string `json:"roles,omitempty"`
+}
+
+// A ServiceAccountSpec defines the desired state of a ServiceAccount.
+type ServiceAccountSpec struct {
+	xpv2.ManagedResourceSpec `json:",inline"`
+	ForProvider              ServiceAccountParameters `json:"forProvider"`
+}
+
+// A ServiceAccountStatus represents the observed state of a ServiceAccount.
+type ServiceAccountStatus struct {
+	xpv1.ResourceStatus `json:",inline"`
+	AtProvider          ServiceAccountObservation `json:"atProvider,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// A ServiceAccount is a managed resource that represents an Orchard service
+// account. The token Orchard generates for it is written to the connection
+// secret under the "credentials" key in the same {"token": "..."} format a
+// ProviderConfig expects, and under the "token" key as a plain value.
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="SYNCED",type="string",JSONPath=".status.conditions[?(@.type=='Synced')].status"
+// +kubebuilder:printcolumn:name="ROLES",type="string",JSONPath=".status.atProvider.roles"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Namespaced,categories={crossplane,managed,orchard}
+// +kubebuilder:rbac:groups=admin.orchard.crossplane.io,resources=serviceaccounts,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=admin.orchard.crossplane.io,resources=serviceaccounts/status,verbs=get;update;patch
+type ServiceAccount struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ServiceAccountSpec   `json:"spec"`
+	Status ServiceAccountStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ServiceAccountList contains a list of ServiceAccount
+type ServiceAccountList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServiceAccount `json:"items"`
+}
+
+// ServiceAccount type metadata.
+var (
+	ServiceAccountKind             = reflect.TypeOf(ServiceAccount{}).Name()
+	ServiceAccountGroupKind        = schema.GroupKind{Group: Group, Kind: ServiceAccountKind}.String()
+	ServiceAccountKindAPIVersion   = ServiceAccountKind + "." + SchemeGroupVersion.String()
+	ServiceAccountGroupVersionKind = SchemeGroupVersion.WithKind(ServiceAccountKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&ServiceAccount{}, &ServiceAccountList{})
+}
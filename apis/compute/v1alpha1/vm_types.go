@@ -19,6 +19,7 @@ package v1alpha1
 import (
 	"reflect"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
@@ -26,12 +27,397 @@ import (
 	xpv2 "github.com/crossplane/crossplane-runtime/v2/apis/common/v2"
 )
 
+// AnnotationAdopt opts a VM into adopting an existing Orchard VM with the same
+// name. Without it, creating a VM whose name is already taken in Orchard fails
+// rather than silently taking over the existing VM. Setting the
+// crossplane.io/external-name annotation before creation also adopts the
+// named VM.
+const AnnotationAdopt = "compute.orchard.crossplane.io/adopt"
+
+// Update strategies for changes to VM fields Orchard cannot apply to a running
+// VM in place.
+const (
+	// UpdateStrategyInPlace sends every change to Orchard and reports changes
+	// that only take effect once the VM restarts or is recreated.
+	UpdateStrategyInPlace = "InPlace"
+
+	// UpdateStrategyRecreate deletes the Orchard VM and creates it again under
+	// the same name. The startup script runs again on the new VM.
+	UpdateStrategyRecreate = "Recreate"
+
+	// UpdateStrategyReject leaves the VM untouched and reports the change as
+	// rejected.
+	UpdateStrategyReject = "Reject"
+)
+
+// Re-provisioning policies for provisioning steps whose script or env changed
+// after they ran.
+const (
+	// ReprovisionPolicyRerun runs the changed steps again on the running VM.
+	ReprovisionPolicyRerun = "Rerun"
+
+	// ReprovisionPolicyRecreate deletes the Orchard VM and creates it again,
+	// so every step runs on a fresh VM.
+	ReprovisionPolicyRecreate = "Recreate"
+
+	// ReprovisionPolicyIgnore leaves the VM as provisioned.
+	ReprovisionPolicyIgnore = "Ignore"
+)
+
+// Actions taken when a VM's liveness probe fails.
+const (
+	// LivenessFailureActionNone marks the VM unavailable.
+	LivenessFailureActionNone = "None"
+
+	// LivenessFailureActionRecreate deletes the VM and creates it again.
+	LivenessFailureActionRecreate = "Recreate"
+)
+
+// Actions taken when a VM's pre-delete script fails.
+const (
+	// PreDeleteFailurePolicyBlock keeps the VM until the script succeeds.
+	PreDeleteFailurePolicyBlock = "Block"
+
+	// PreDeleteFailurePolicyContinue deletes the VM anyway.
+	PreDeleteFailurePolicyContinue = "Continue"
+)
+
+// Guest operating systems a VM may run.
+const (
+	// GuestOSMacOS is a macOS guest, such as the cirruslabs macOS images.
+	GuestOSMacOS = "macOS"
+
+	// GuestOSLinux is a Linux guest, such as ghcr.io/cirruslabs/ubuntu.
+	GuestOSLinux = "Linux"
+)
+
+// Probe results.
+const (
+	ProbeStatusUnknown = "Unknown"
+	ProbeStatusSuccess = "Success"
+	ProbeStatusFailure = "Failure"
+)
+
+// TypeSpecApplied indicates whether Orchard has applied the VM's spec.
+const TypeSpecApplied xpv1.ConditionType = "SpecApplied"
+
+// Reasons for the SpecApplied condition.
+const (
+	ReasonSpecApplied    xpv1.ConditionReason = "Applied"
+	ReasonRestartPending xpv1.ConditionReason = "RestartPending"
+	ReasonRecreating     xpv1.ConditionReason = "Recreating"
+	ReasonChangeRejected xpv1.ConditionReason = "ChangeRejected"
+)
+
+// SpecApplied returns a condition indicating that Orchard runs the VM as
+// specified.
+func SpecApplied() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeSpecApplied,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonSpecApplied,
+	}
+}
+
+// SpecRestartPending returns a condition indicating that a change was sent to
+// Orchard but only takes effect once the VM restarts or is recreated.
+func SpecRestartPending(msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeSpecApplied,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonRestartPending,
+		Message:            msg,
+	}
+}
+
+// SpecRecreating returns a condition indicating that the VM is being
+// recreated to apply a change.
+func SpecRecreating(msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeSpecApplied,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonRecreating,
+		Message:            msg,
+	}
+}
+
+// SpecChangeRejected returns a condition indicating that a change was not
+// applied because of the Reject update strategy.
+func SpecChangeRejected(msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeSpecApplied,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonChangeRejected,
+		Message:            msg,
+	}
+}
+
+// ReasonCredentialsUnavailable is the Ready condition's reason when a VM's SSH
+// credentials cannot be read.
+const ReasonCredentialsUnavailable xpv1.ConditionReason = "CredentialsUnavailable"
+
+// CredentialsUnavailable returns a condition indicating that the VM cannot be
+// managed because its SSH credentials cannot be read.
+func CredentialsUnavailable(msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               xpv1.TypeReady,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonCredentialsUnavailable,
+		Message:            msg,
+	}
+}
+
 // VMStartupScript represents a startup script for a VM.
 type VMStartupScript struct {
 	// ScriptContent is the shell script to run after VM boots
 	ScriptContent string `json:"scriptContent,omitempty"`
+
+	// ScriptFrom reads the script from a key of a ConfigMap or Secret in the
+	// VM's namespace. It takes precedence over ScriptContent.
+	// +optional
+	ScriptFrom *VMValueSource `json:"scriptFrom,omitempty"`
+
 	// Env is a map of environment variables for the script
 	Env map[string]string `json:"env,omitempty"`
+
+	// EnvFrom sets an environment variable for every key of ConfigMaps or
+	// Secrets in the VM's namespace. Keys that are not valid variable names
+	// are skipped. Env and EnvVars take precedence.
+	// +optional
+	EnvFrom []corev1.EnvFromSource `json:"envFrom,omitempty"`
+
+	// EnvVars sets environment variables from keys of ConfigMaps or Secrets
+	// in the VM's namespace, so secret values stay out of the VM's spec. They
+	// take precedence over Env. Entries take the shape of a container's
+	// env[].valueFrom; they are a separate list because Env is a map of
+	// plain values, and turning it into a list would break existing VMs.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	EnvVars []VMEnvVar `json:"envVars,omitempty"`
+
+	VMScriptOptions `json:",inline"`
+}
+
+// VMScriptOptions control how a script runs on a VM.
+type VMScriptOptions struct {
+	// Interpreter runs the script, e.g. "/bin/zsh" or "/usr/bin/env python3".
+	// The script runs by its shebang if unset.
+	// +optional
+	Interpreter string `json:"interpreter,omitempty"`
+
+	// WorkingDir is the directory the script runs in. Defaults to the home
+	// directory of the SSH user.
+	// +optional
+	WorkingDir string `json:"workingDir,omitempty"`
+
+	// RunAs runs the script as another user, e.g. "root", with sudo. On
+	// macOS sudo is given the SSH password if it asks for one; on Linux the
+	// SSH user needs passwordless sudo. Defaults to the SSH user.
+	// +optional
+	RunAs string `json:"runAs,omitempty"`
+}
+
+// VMEnvVar is an environment variable whose value is read from a ConfigMap
+// or Secret.
+type VMEnvVar struct {
+	// Name of the environment variable
+	// +kubebuilder:validation:Pattern=`^[A-Za-z_][A-Za-z0-9_]*$`
+	Name string `json:"name"`
+
+	// ValueFrom selects the key holding the variable's value
+	ValueFrom VMValueSource `json:"valueFrom"`
+}
+
+// VMValueSource selects a key of a ConfigMap or Secret in the VM's
+// namespace. Exactly one of its fields must be set.
+// +kubebuilder:validation:XValidation:rule="has(self.configMapKeyRef) != has(self.secretKeyRef)",message="exactly one of configMapKeyRef and secretKeyRef must be set"
+type VMValueSource struct {
+	// ConfigMapKeyRef selects a key of a ConfigMap
+	// +optional
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+
+	// SecretKeyRef selects a key of a Secret
+	// +optional
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
+// VMProvisioningStep is a named script run on a VM after it boots. Steps run
+// in order, after the startup script.
+type VMProvisioningStep struct {
+	// Name identifies the step. Names must be unique within a VM.
+	// startupScript, userData and preDeleteScript are reserved.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:XValidation:rule="!(self in ['startupScript', 'userData', 'preDeleteScript'])",message="startupScript, userData and preDeleteScript are reserved step names"
+	Name string `json:"name"`
+
+	// ScriptContent is the shell script the step runs
+	ScriptContent string `json:"scriptContent"`
+
+	// Env is a map of environment variables for the script
+	// +optional
+	Env map[string]string `json:"env,omitempty"`
+
+	// Timeout limits how long the script may run, e.g. "10m". A script that
+	// times out fails the step. Scripts run without a limit by default.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// ContinueOnFailure runs the remaining steps even if this one fails.
+	// Otherwise provisioning stops at this step until its script, env or
+	// script options change.
+	// +optional
+	ContinueOnFailure bool `json:"continueOnFailure,omitempty"`
+
+	// RetryPolicy overrides the VM's retry policy for this step
+	// +optional
+	RetryPolicy *VMRetryPolicy `json:"retryPolicy,omitempty"`
+
+	VMScriptOptions `json:",inline"`
+}
+
+// VMPreDeleteScript is a script run on a VM before it is deleted, e.g. to
+// deregister it from a CI system.
+type VMPreDeleteScript struct {
+	// ScriptContent is the shell script to run before the VM is deleted
+	ScriptContent string `json:"scriptContent"`
+
+	// Env is a map of environment variables for the script
+	// +optional
+	Env map[string]string `json:"env,omitempty"`
+
+	// Timeout limits how long the script may run. A script that times out
+	// fails.
+	// +kubebuilder:default="5m"
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// OnFailure controls what happens when the script fails or the VM cannot
+	// be reached. Continue deletes the VM anyway, and Block keeps it and runs
+	// the script again until it succeeds.
+	// +kubebuilder:validation:Enum=Block;Continue
+	// +kubebuilder:default=Continue
+	// +optional
+	OnFailure string `json:"onFailure,omitempty"`
+
+	VMScriptOptions `json:",inline"`
+}
+
+// VMRetryPolicy controls how a failed provisioning step is retried. Errors
+// reaching the VM over SSH are always retried and do not count as attempts.
+type VMRetryPolicy struct {
+	// MaxAttempts is how many times a step runs before it fails, including
+	// its first run.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=3
+	// +optional
+	MaxAttempts int32 `json:"maxAttempts,omitempty"`
+
+	// Backoff is the delay before the first retry, e.g. "10s". It doubles
+	// with every further retry. Defaults to 10s.
+	// +optional
+	Backoff *metav1.Duration `json:"backoff,omitempty"`
+
+	// MaxBackoff caps the delay between retries. Defaults to 5m.
+	// +optional
+	MaxBackoff *metav1.Duration `json:"maxBackoff,omitempty"`
+
+	// RetryableExitCodes are the exit codes a step is retried for. Steps are
+	// retried for any failure, including timeouts, if none are listed.
+	// +listType=set
+	// +optional
+	RetryableExitCodes []int32 `json:"retryableExitCodes,omitempty"`
+
+	// Deadline limits how long a step is retried for, measured from its
+	// first attempt, e.g. "1h". No retry starts after it passes.
+	// +optional
+	Deadline *metav1.Duration `json:"deadline,omitempty"`
+}
+
+// VMProbe periodically checks the health of a VM's workload. Exactly one of
+// exec, tcpSocket and httpGet must be set.
+// +kubebuilder:validation:XValidation:rule="(has(self.exec) ? 1 : 0) + (has(self.tcpSocket) ? 1 : 0) + (has(self.httpGet) ? 1 : 0) == 1",message="exactly one of exec, tcpSocket and httpGet must be set"
+type VMProbe struct {
+	// Exec runs a command on the VM over SSH. It succeeds if the command
+	// exits with code 0.
+	// +optional
+	Exec *VMExecAction `json:"exec,omitempty"`
+
+	// TCPSocket connects to a port of the VM through Orchard's
+	// port-forward endpoint. It succeeds if the connection is established.
+	// +optional
+	TCPSocket *VMTCPSocketAction `json:"tcpSocket,omitempty"`
+
+	// HTTPGet sends an HTTP GET request to a port of the VM through
+	// Orchard's port-forward endpoint. It succeeds if the response status
+	// is at least 200 and below 400.
+	// +optional
+	HTTPGet *VMHTTPGetAction `json:"httpGet,omitempty"`
+
+	// PeriodSeconds is how often the probe runs
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=10
+	// +optional
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+
+	// TimeoutSeconds is how long the probe may take before it fails
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=5
+	// +optional
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+
+	// FailureThreshold is how many consecutive failures mark the probe
+	// failed
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=3
+	// +optional
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+
+	// SuccessThreshold is how many consecutive successes mark the probe
+	// successful
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1
+	// +optional
+	SuccessThreshold int32 `json:"successThreshold,omitempty"`
+}
+
+// VMExecAction runs a command on a VM.
+type VMExecAction struct {
+	// Command is the shell command to run
+	// +kubebuilder:validation:MinLength=1
+	Command string `json:"command"`
+}
+
+// VMTCPSocketAction connects to a port of a VM.
+type VMTCPSocketAction struct {
+	// Port to connect to
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+}
+
+// VMHTTPGetAction sends an HTTP GET request to a port of a VM.
+type VMHTTPGetAction struct {
+	// Path to request
+	// +kubebuilder:default="/"
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Port to send the request to
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+
+	// Scheme is the scheme of the request
+	// +kubebuilder:validation:Enum=HTTP;HTTPS
+	// +kubebuilder:default=HTTP
+	// +optional
+	Scheme string `json:"scheme,omitempty"`
 }
 
 // VMHostDir represents a host directory to mount to a VM.
@@ -50,6 +436,14 @@ type VMParameters struct {
 	// +kubebuilder:validation:Required
 	Image string `json:"image"`
 
+	// GuestOS is the operating system the image runs. It selects the default
+	// SSH credentials, where scripts are uploaded, the shell that runs
+	// scripts without a shebang and the commands used to configure the VM.
+	// +kubebuilder:validation:Enum=macOS;Linux
+	// +kubebuilder:default=macOS
+	// +optional
+	GuestOS string `json:"guestOS,omitempty"`
+
 	// CPU is the number of CPUs assigned to this VM
 	// +kubebuilder:validation:Minimum=1
 	// +optional
@@ -64,10 +458,80 @@ type VMParameters struct {
 	// +optional
 	DiskSize *int32 `json:"diskSize,omitempty"`
 
+	// UserData is a #cloud-config document applied over SSH after the VM
+	// boots, before the startup script. The hostname, write_files, users,
+	// ssh_authorized_keys and runcmd keys are supported; any other key is
+	// ignored.
+	// +kubebuilder:validation:XValidation:rule="self.startsWith('#cloud-config')",message="userData must start with #cloud-config"
+	// +optional
+	UserData string `json:"userData,omitempty"`
+
 	// StartupScript is the startup script to run after the VM boots
 	// +optional
 	StartupScript *VMStartupScript `json:"startupScript,omitempty"`
 
+	// ProvisioningSteps are scripts to run in order after the VM boots,
+	// following the startup script. Completed steps are not run again, so
+	// fixing a failed step resumes provisioning from that step.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	ProvisioningSteps []VMProvisioningStep `json:"provisioningSteps,omitempty"`
+
+	// ReprovisionPolicy controls what happens when the script or env of the
+	// startup script or a provisioning step changes after it ran. Rerun runs
+	// the changed steps again on the running VM, Recreate replaces the VM so
+	// every step runs again, and Ignore leaves the VM as provisioned.
+	// +kubebuilder:validation:Enum=Rerun;Recreate;Ignore
+	// +kubebuilder:default=Rerun
+	// +optional
+	ReprovisionPolicy string `json:"reprovisionPolicy,omitempty"`
+
+	// RetryPolicy controls how the startup script and provisioning steps are
+	// retried when they fail. Steps may override it. Failed steps are not
+	// retried by default.
+	// +optional
+	RetryPolicy *VMRetryPolicy `json:"retryPolicy,omitempty"`
+
+	// RebootTimeout limits how long to wait for the VM to come back after
+	// the startup script or a provisioning step requests a reboot, by
+	// exiting with code 194 or by creating the file named by
+	// $ORCHARD_REBOOT_MARKER.
+	// +kubebuilder:default="10m"
+	// +optional
+	RebootTimeout *metav1.Duration `json:"rebootTimeout,omitempty"`
+
+	// PreDeleteScript runs on the VM before it is deleted. It only runs if
+	// the VM is running.
+	// +optional
+	PreDeleteScript *VMPreDeleteScript `json:"preDeleteScript,omitempty"`
+
+	// SensitiveOutputs lists the keys of script outputs that are published
+	// to the VM's connection secret instead of status.atProvider.outputs.
+	// Scripts write outputs as key=value lines to the file named by
+	// $ORCHARD_OUTPUT.
+	// +listType=set
+	// +optional
+	SensitiveOutputs []string `json:"sensitiveOutputs,omitempty"`
+
+	// ReadinessProbe checks that the VM's workload is ready once the VM is
+	// provisioned. The VM is only available while the probe succeeds.
+	// +optional
+	ReadinessProbe *VMProbe `json:"readinessProbe,omitempty"`
+
+	// LivenessProbe checks that the VM's workload is still healthy once the
+	// VM is provisioned. The VM is unavailable while the probe fails.
+	// +optional
+	LivenessProbe *VMProbe `json:"livenessProbe,omitempty"`
+
+	// LivenessFailureAction controls what happens when the liveness probe
+	// fails. None marks the VM unavailable, and Recreate also deletes the
+	// VM and creates it again.
+	// +kubebuilder:validation:Enum=None;Recreate
+	// +kubebuilder:default=None
+	// +optional
+	LivenessFailureAction string `json:"livenessFailureAction,omitempty"`
+
 	// Username is the SSH username to use when connecting to a VM
 	// +optional
 	Username *string `json:"username,omitempty"`
@@ -76,6 +540,16 @@ type VMParameters struct {
 	// +optional
 	Password *string `json:"password,omitempty"`
 
+	// UsernameSecretRef selects a key of a Secret in the VM's namespace
+	// holding the SSH username. Takes precedence over Username.
+	// +optional
+	UsernameSecretRef *xpv1.LocalSecretKeySelector `json:"usernameSecretRef,omitempty"`
+
+	// PasswordSecretRef selects a key of a Secret in the VM's namespace
+	// holding the SSH password. Takes precedence over Password.
+	// +optional
+	PasswordSecretRef *xpv1.LocalSecretKeySelector `json:"passwordSecretRef,omitempty"`
+
 	// Headless indicates whether to run without graphics
 	// +optional
 	Headless *bool `json:"headless,omitempty"`
@@ -155,12 +629,119 @@ type VMObservation struct {
 	// CloudInitMessage provides additional details about cloud-init execution
 	// +optional
 	CloudInitMessage string `json:"cloudInitMessage,omitempty"`
+
+	// ProvisioningSteps is the status of each provisioning step, in the
+	// order they run. The startup script is reported as the step named
+	// "startupScript".
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	ProvisioningSteps []VMProvisioningStepStatus `json:"provisioningSteps,omitempty"`
+
+	// ReadinessProbe is the state of the VM's readiness probe
+	// +optional
+	ReadinessProbe *VMProbeStatus `json:"readinessProbe,omitempty"`
+
+	// LivenessProbe is the state of the VM's liveness probe
+	// +optional
+	LivenessProbe *VMProbeStatus `json:"livenessProbe,omitempty"`
+
+	// PreDeleteScript is the status of the VM's pre-delete script, once the
+	// VM is being deleted
+	// +optional
+	PreDeleteScript *VMProvisioningStepStatus `json:"preDeleteScript,omitempty"`
+
+	// ProvisioningLogsRef references the ConfigMap in the VM's namespace
+	// holding the output of each provisioning step, under the key
+	// "<step>.log". The last 32KiB of each step's output are kept.
+	// +optional
+	ProvisioningLogsRef *corev1.LocalObjectReference `json:"provisioningLogsRef,omitempty"`
+
+	// Outputs are the values the startup script and provisioning steps
+	// wrote to $ORCHARD_OUTPUT, except those listed in sensitiveOutputs.
+	// Later steps override the values of earlier ones.
+	// +optional
+	Outputs map[string]string `json:"outputs,omitempty"`
+}
+
+// VMProbeStatus is the observed state of a probe.
+type VMProbeStatus struct {
+	// Status is the probe's result once its thresholds were reached:
+	// Unknown, Success or Failure
+	// +kubebuilder:validation:Enum=Unknown;Success;Failure
+	Status string `json:"status"`
+
+	// ConsecutiveFailures is how many times in a row the probe failed
+	// +optional
+	ConsecutiveFailures int32 `json:"consecutiveFailures,omitempty"`
+
+	// LastTransitionTime is when the probe's status last changed
+	// +optional
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+
+	// Message describes the probe's last failure
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// VMProvisioningStepStatus is the observed status of a provisioning step.
+type VMProvisioningStepStatus struct {
+	// Name of the step
+	Name string `json:"name"`
+
+	// Status of the step (pending, running, completed, failed)
+	// +kubebuilder:validation:Enum=pending;running;completed;failed
+	Status string `json:"status"`
+
+	// ExitCode of the step's script, if it ran to completion
+	// +optional
+	ExitCode *int32 `json:"exitCode,omitempty"`
+
+	// Duration of the step's last run
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// Message provides additional details about a failed step
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// ContentHash is the hash of the script and env the step last ran with.
+	// A failed step is retried once it changes.
+	// +optional
+	ContentHash string `json:"contentHash,omitempty"`
+
+	// ObservedGeneration is the VM's metadata.generation when the step last
+	// ran
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Attempts is how many times the step ran in its current run, counting
+	// retries
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
+
+	// FirstAttemptTime is when the first attempt of the step's current run
+	// started
+	// +optional
+	FirstAttemptTime *metav1.Time `json:"firstAttemptTime,omitempty"`
 }
 
 // A VMSpec defines the desired state of a VM.
 type VMSpec struct {
 	xpv2.ManagedResourceSpec `json:",inline"`
 	ForProvider              VMParameters `json:"forProvider"`
+
+	// UpdateStrategy controls changes to fields Orchard cannot apply to a
+	// running VM: image, diskSize, labels and resources cannot change on an
+	// existing VM, while cpu, memory, headless, nested, suspendable, hostDirs
+	// and network settings need a restart. InPlace sends the change to
+	// Orchard anyway, Recreate deletes and recreates the VM under the same
+	// name, and Reject leaves the VM unchanged. Other fields are always
+	// updated in place.
+	// +kubebuilder:validation:Enum=InPlace;Recreate;Reject
+	// +kubebuilder:default=InPlace
+	// +optional
+	UpdateStrategy string `json:"updateStrategy,omitempty"`
 }
 
 // A VMStatus represents the observed state of a VM.
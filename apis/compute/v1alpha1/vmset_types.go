@@ -0,0 +1,145 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+)
+
+// VMSet labels and annotations set on the VMs it owns.
+const (
+	// LabelVMSetName is set on every VM owned by a VMSet to the VMSet's name.
+	LabelVMSetName = "compute.orchard.crossplane.io/vmset"
+
+	// AnnotationTemplateHash records the hash of the template fields that
+	// require a VM to be replaced when they change.
+	AnnotationTemplateHash = "compute.orchard.crossplane.io/template-hash"
+)
+
+// VMSetUpdateStrategy controls how VMs are replaced when the template's
+// image, CPU or memory change.
+type VMSetUpdateStrategy struct {
+	// MaxUnavailable is the maximum number of VMs that can be unavailable
+	// during a rolling replacement, as an absolute number or a percentage of
+	// replicas. Defaults to 1.
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+
+	// MaxSurge is the maximum number of VMs that can be created above the
+	// desired replicas during a rolling replacement, as an absolute number or
+	// a percentage of replicas. Defaults to 0, since Orchard workers usually
+	// have a hard limit on concurrently running VMs.
+	// +optional
+	MaxSurge *intstr.IntOrString `json:"maxSurge,omitempty"`
+}
+
+// A VMSetSpec defines the desired state of a VMSet.
+type VMSetSpec struct {
+	// Replicas is the desired number of VMs
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=1
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Template is the specification of the VMs in the set
+	Template VMParameters `json:"template"`
+
+	// UpdateStrategy controls rolling replacement of VMs
+	// +optional
+	UpdateStrategy VMSetUpdateStrategy `json:"updateStrategy,omitempty"`
+
+	// ProviderConfigReference is passed to every VM in the set
+	// +kubebuilder:default={"kind": "ClusterProviderConfig", "name": "default"}
+	// +optional
+	ProviderConfigReference *xpv1.ProviderConfigReference `json:"providerConfigRef,omitempty"`
+
+	// ManagementPolicies are passed to every VM in the set
+	// +kubebuilder:default={"*"}
+	// +optional
+	ManagementPolicies xpv1.ManagementPolicies `json:"managementPolicies,omitempty"`
+}
+
+// A VMSetStatus represents the observed state of a VMSet.
+type VMSetStatus struct {
+	xpv1.ConditionedStatus `json:",inline"`
+
+	// Replicas is the number of VMs owned by the set
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// ReadyReplicas is the number of VMs that are Available
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// UpdatedReplicas is the number of VMs that match the current template
+	UpdatedReplicas int32 `json:"updatedReplicas,omitempty"`
+
+	// ObservedGeneration is the VMSet generation the status was computed for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// A VMSet manages a fleet of identical VMs with stable ordinal names
+// (<name>-0, <name>-1, ...). Changes to the template's image, CPU or memory
+// replace VMs one batch at a time according to the update strategy; other
+// template changes are applied to the VMs in place.
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="DESIRED",type="integer",JSONPath=".spec.replicas"
+// +kubebuilder:printcolumn:name="CURRENT",type="integer",JSONPath=".status.replicas"
+// +kubebuilder:printcolumn:name="AVAILABLE",type="integer",JSONPath=".status.readyReplicas"
+// +kubebuilder:printcolumn:name="UP-TO-DATE",type="integer",JSONPath=".status.updatedReplicas"
+// +kubebuilder:printcolumn:name="IMAGE",type="string",JSONPath=".spec.template.image"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas
+// +kubebuilder:resource:scope=Namespaced,categories={crossplane,orchard}
+// +kubebuilder:rbac:groups=compute.orchard.crossplane.io,resources=vmsets,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=compute.orchard.crossplane.io,resources=vmsets/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=compute.orchard.crossplane.io,resources=vms,verbs=create;delete
+type VMSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VMSetSpec   `json:"spec"`
+	Status VMSetStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VMSetList contains a list of VMSet
+type VMSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VMSet `json:"items"`
+}
+
+// VMSet type metadata.
+var (
+	VMSetKind             = reflect.TypeOf(VMSet{}).Name()
+	VMSetGroupKind        = schema.GroupKind{Group: Group, Kind: VMSetKind}.String()
+	VMSetKindAPIVersion   = VMSetKind + "." + SchemeGroupVersion.String()
+	VMSetGroupVersionKind = SchemeGroupVersion.WithKind(VMSetKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&VMSet{}, &VMSetList{})
+}
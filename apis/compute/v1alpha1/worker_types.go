@@ -0,0 +1,97 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	xpv2 "github.com/crossplane/crossplane-runtime/v2/apis/common/v2"
+)
+
+// WorkerParameters are the configurable fields of a Worker.
+// Workers register themselves with the Orchard controller, so there is
+// nothing to configure; the worker is identified by its external name.
+type WorkerParameters struct{}
+
+// WorkerObservation are the observable fields of a Worker.
+type WorkerObservation struct {
+	// Name is the name the worker registered with
+	Name string `json:"name,omitempty"`
+
+	// Resources maps the resource name to the amount of this resource
+	// provided by the worker for running VMs
+	Resources map[string]int `json:"resources,omitempty"`
+}
+
+// A WorkerSpec defines the desired state of a Worker.
+type WorkerSpec struct {
+	xpv2.ManagedResourceSpec `json:",inline"`
+	ForProvider              WorkerParameters `json:"forProvider,omitempty"`
+}
+
+// A WorkerStatus represents the observed state of a Worker.
+type WorkerStatus struct {
+	xpv1.ResourceStatus `json:",inline"`
+	AtProvider          WorkerObservation `json:"atProvider,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// A Worker is a managed resource that represents an Orchard worker host.
+// Workers cannot be created through the Orchard API; existing workers are
+// imported with the Observe management policy and may be decommissioned by
+// deleting the resource with a policy that includes Delete.
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="SYNCED",type="string",JSONPath=".status.conditions[?(@.type=='Synced')].status"
+// +kubebuilder:printcolumn:name="EXTERNAL-NAME",type="string",JSONPath=".metadata.annotations.crossplane\\.io/external-name"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Namespaced,categories={crossplane,managed,orchard}
+// +kubebuilder:rbac:groups=compute.orchard.crossplane.io,resources=workers,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=compute.orchard.crossplane.io,resources=workers/status,verbs=get;update;patch
+type Worker struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WorkerSpec   `json:"spec"`
+	Status WorkerStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// WorkerList contains a list of Worker
+type WorkerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Worker `json:"items"`
+}
+
+// Worker type metadata.
+var (
+	WorkerKind             = reflect.TypeOf(Worker{}).Name()
+	WorkerGroupKind        = schema.GroupKind{Group: Group, Kind: WorkerKind}.String()
+	WorkerKindAPIVersion   = WorkerKind + "." + SchemeGroupVersion.String()
+	WorkerGroupVersionKind = SchemeGroupVersion.WithKind(WorkerKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&Worker{}, &WorkerList{})
+}
@@ -20,6 +20,7 @@ package apis
 import (
 	"k8s.io/apimachinery/pkg/runtime"
 
+	adminv1alpha1 "github.com/ravan/provider-orchard/apis/admin/v1alpha1"
 	computev1alpha1 "github.com/ravan/provider-orchard/apis/compute/v1alpha1"
 	orchardv1alpha1 "github.com/ravan/provider-orchard/apis/v1alpha1"
 )
@@ -29,6 +30,7 @@ func init() {
 	AddToSchemes = append(AddToSchemes,
 		orchardv1alpha1.SchemeBuilder.AddToScheme,
 		computev1alpha1.SchemeBuilder.AddToScheme,
+		adminv1alpha1.SchemeBuilder.AddToScheme,
 	)
 }
 
@@ -20,6 +20,21 @@ type ProviderCredentials struct {
 	xpv1.CommonCredentialSelectors `json:",inline"`
 }
 
+// Strategies for deriving the Orchard name of a new VM.
+const (
+	// VMNameStrategyName uses the VM's metadata.name. VMs with the same name
+	// in different namespaces map to the same Orchard VM.
+	VMNameStrategyName = "Name"
+
+	// VMNameStrategyNamespacedName uses <namespace>-<name>-<hash>, where the
+	// hash of <namespace>/<name> keeps e.g. team-a/b and team/a-b apart.
+	VMNameStrategyNamespacedName = "NamespacedName"
+
+	// VMNameStrategyGenerateName uses <namespace>-<generateName> (or
+	// <namespace>-<name>- without one) followed by a random suffix.
+	VMNameStrategyGenerateName = "GenerateName"
+)
+
 type ProviderConfigSpec struct {
 	// Credentials required to authenticate to this provider.
 	Credentials ProviderCredentials `json:"credentials"`
@@ -28,6 +43,14 @@ type ProviderConfigSpec struct {
 	// +optional
 	// +kubebuilder:default="http://localhost:6120"
 	BaseURL string `json:"baseURL,omitempty"`
+
+	// VMNameStrategy controls how the Orchard name of a new VM is derived
+	// from its metadata. Names longer than Orchard allows are shortened with a
+	// hashed suffix. VMs that already have an external name keep it.
+	// +optional
+	// +kubebuilder:validation:Enum=Name;NamespacedName;GenerateName
+	// +kubebuilder:default=NamespacedName
+	VMNameStrategy string `json:"vmNameStrategy,omitempty"`
 }
 
 // +kubebuilder:object:root=true
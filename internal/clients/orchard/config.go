@@ -0,0 +1,101 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package orchardclient
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apisv1alpha1 "github.com/ravan/provider-orchard/apis/v1alpha1"
+)
+
+const (
+	errGetPC      = "cannot get ProviderConfig"
+	errGetCPC     = "cannot get ClusterProviderConfig"
+	errGetCreds   = "cannot get credentials"
+	errParseToken = "cannot parse token from credentials"
+)
+
+// GetConfig resolves the Orchard base URL, bearer token and VM naming strategy
+// for a managed resource from the ProviderConfig or ClusterProviderConfig it
+// references.
+func GetConfig(ctx context.Context, kube client.Client, mg resource.ModernManaged) (OrchardConfig, error) {
+	spec, err := getProviderConfigSpec(ctx, kube, mg)
+	if err != nil {
+		return OrchardConfig{}, err
+	}
+
+	cd := spec.Credentials
+	data, err := resource.CommonCredentialExtractor(ctx, cd.Source, kube, cd.CommonCredentialSelectors)
+	if err != nil {
+		return OrchardConfig{}, errors.Wrap(err, errGetCreds)
+	}
+
+	token, err := ExtractToken(data)
+	if err != nil {
+		return OrchardConfig{}, err
+	}
+
+	return OrchardConfig{
+		BaseURL:        spec.BaseURL,
+		Token:          token,
+		VMNameStrategy: spec.VMNameStrategy,
+	}, nil
+}
+
+// getProviderConfigSpec retrieves the spec of the ProviderConfig or ClusterProviderConfig a managed resource references
+func getProviderConfigSpec(ctx context.Context, kube client.Client, m resource.ModernManaged) (apisv1alpha1.ProviderConfigSpec, error) {
+	ref := m.GetProviderConfigReference()
+
+	switch ref.Kind {
+	case "ProviderConfig":
+		pc := &apisv1alpha1.ProviderConfig{}
+		if err := kube.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: m.GetNamespace()}, pc); err != nil {
+			return apisv1alpha1.ProviderConfigSpec{}, errors.Wrap(err, errGetPC)
+		}
+		return pc.Spec, nil
+	case "ClusterProviderConfig":
+		cpc := &apisv1alpha1.ClusterProviderConfig{}
+		if err := kube.Get(ctx, types.NamespacedName{Name: ref.Name}, cpc); err != nil {
+			return apisv1alpha1.ProviderConfigSpec{}, errors.Wrap(err, errGetCPC)
+		}
+		return cpc.Spec, nil
+	default:
+		return apisv1alpha1.ProviderConfigSpec{}, errors.Errorf("unsupported provider config kind: %s", ref.Kind)
+	}
+}
+
+// ExtractToken parses and extracts the token from credential data of the
+// form {"token": "..."}.
+func ExtractToken(data []byte) (string, error) {
+	var creds map[string]string
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return "", errors.Wrap(err, errParseToken)
+	}
+
+	token, ok := creds["token"]
+	if !ok {
+		return "", errors.New(errParseToken)
+	}
+
+	return token, nil
+}
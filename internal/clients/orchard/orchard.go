@@ -25,6 +25,9 @@ import (
 type OrchardConfig struct {
 	BaseURL string
 	Token   string
+
+	// VMNameStrategy is the ProviderConfig's strategy for naming new VMs
+	VMNameStrategy string
 }
 
 // OrchardClient wraps the generated Orchard API client with authentication
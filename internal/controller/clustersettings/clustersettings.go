@@ -0,0 +1,346 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clustersettings
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/feature"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+
+	"github.com/pkg/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/controller"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/event"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/ratelimiter"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/statemetrics"
+
+	v1alpha1 "github.com/ravan/provider-orchard/apis/admin/v1alpha1"
+	apisv1alpha1 "github.com/ravan/provider-orchard/apis/v1alpha1"
+	orchardclient "github.com/ravan/provider-orchard/internal/clients/orchard"
+)
+
+const (
+	errNotClusterSettings = "managed resource is not a ClusterSettings custom resource"
+	errTrackPCUsage       = "cannot track ProviderConfig usage"
+	errClusterPCOnly      = "ClusterSettings must reference a ClusterProviderConfig"
+
+	errNewClient             = "cannot create new Orchard client"
+	errGetClusterSettings    = "cannot get cluster settings"
+	errUpdateClusterSettings = "cannot update cluster settings"
+	errDecodeClusterSettings = "cannot decode cluster settings response"
+)
+
+// hostDirPolicy mirrors the anonymous element type the generated client uses
+// for ClusterSettings.HostDirPolicies.
+type hostDirPolicy = struct {
+	PathPrefix *string `json:"pathPrefix,omitempty"`
+	Ro         *bool   `json:"ro,omitempty"`
+}
+
+// SetupGated adds a controller that reconciles ClusterSettings managed resources with safe-start support.
+func SetupGated(mgr ctrl.Manager, o controller.Options) error {
+	o.Gate.Register(func() {
+		if err := Setup(mgr, o); err != nil {
+			panic(errors.Wrap(err, "cannot setup ClusterSettings controller"))
+		}
+	}, v1alpha1.ClusterSettingsGroupVersionKind)
+	return nil
+}
+
+func Setup(mgr ctrl.Manager, o controller.Options) error {
+	name := managed.ControllerName(v1alpha1.ClusterSettingsGroupKind)
+
+	opts := []managed.ReconcilerOption{
+		managed.WithExternalConnector(&connector{
+			kube:  mgr.GetClient(),
+			usage: resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ClusterProviderConfigUsage{}),
+		}),
+		managed.WithLogger(o.Logger.WithValues("controller", name)),
+		managed.WithPollInterval(o.PollInterval),
+		managed.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+	}
+
+	if o.Features.Enabled(feature.EnableBetaManagementPolicies) {
+		opts = append(opts, managed.WithManagementPolicies())
+	}
+
+	if o.Features.Enabled(feature.EnableAlphaChangeLogs) {
+		opts = append(opts, managed.WithChangeLogger(o.ChangeLogOptions.ChangeLogger))
+	}
+
+	if o.MetricOptions != nil {
+		opts = append(opts, managed.WithMetricRecorder(o.MetricOptions.MRMetrics))
+	}
+
+	if o.MetricOptions != nil && o.MetricOptions.MRStateMetrics != nil {
+		stateMetricsRecorder := statemetrics.NewMRStateRecorder(
+			mgr.GetClient(), o.Logger, o.MetricOptions.MRStateMetrics, &v1alpha1.ClusterSettingsList{}, o.MetricOptions.PollStateMetricInterval,
+		)
+		if err := mgr.Add(stateMetricsRecorder); err != nil {
+			return errors.Wrap(err, "cannot register MR state metrics recorder for kind v1alpha1.ClusterSettingsList")
+		}
+	}
+
+	r := managed.NewReconciler(mgr, resource.ManagedKind(v1alpha1.ClusterSettingsGroupVersionKind), opts...)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		WithEventFilter(resource.DesiredStateChanged()).
+		For(&v1alpha1.ClusterSettings{}).
+		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
+}
+
+// A connector is expected to produce an ExternalClient when its Connect method
+// is called.
+type connector struct {
+	kube  client.Client
+	usage *resource.ProviderConfigUsageTracker
+}
+
+// Connect tracks ClusterProviderConfig usage and builds an Orchard client from
+// its credentials. ClusterSettings is cluster scoped, so a namespaced
+// ProviderConfig cannot be resolved for it.
+func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.ExternalClient, error) {
+	cr, ok := mg.(*v1alpha1.ClusterSettings)
+	if !ok {
+		return nil, errors.New(errNotClusterSettings)
+	}
+
+	if ref := cr.GetProviderConfigReference(); ref != nil && ref.Kind != "ClusterProviderConfig" {
+		return nil, errors.New(errClusterPCOnly)
+	}
+
+	if err := c.usage.Track(ctx, cr); err != nil {
+		return nil, errors.Wrap(err, errTrackPCUsage)
+	}
+
+	cfg, err := orchardclient.GetConfig(ctx, c.kube, cr)
+	if err != nil {
+		return nil, err
+	}
+
+	orchardClient, err := orchardclient.NewOrchardClient(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, errNewClient)
+	}
+
+	return &external{client: orchardClient}, nil
+}
+
+// An ExternalClient observes, then either creates, updates, or deletes an
+// external resource to ensure it reflects the managed resource's desired state.
+type external struct {
+	client *orchardclient.OrchardClient
+}
+
+func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
+	cr, ok := mg.(*v1alpha1.ClusterSettings)
+	if !ok {
+		return managed.ExternalObservation{}, errors.New(errNotClusterSettings)
+	}
+
+	resp, err := c.client.GetClusterSettings(ctx)
+	if err != nil {
+		return managed.ExternalObservation{}, errors.Wrap(err, errGetClusterSettings)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return managed.ExternalObservation{}, errors.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var settings orchardclient.ClusterSettings
+	if err := json.NewDecoder(resp.Body).Decode(&settings); err != nil {
+		return managed.ExternalObservation{}, errors.Wrap(err, errDecodeClusterSettings)
+	}
+
+	updateClusterSettingsStatus(cr, &settings)
+
+	// Cluster settings always exist in Orchard. Once the resource is being
+	// deleted and the defaults have been restored, report them as gone so
+	// the finalizer can be removed.
+	if meta.WasDeleted(cr) && isDefault(&settings) {
+		return managed.ExternalObservation{ResourceExists: false}, nil
+	}
+
+	cr.SetConditions(xpv1.Available())
+
+	return managed.ExternalObservation{
+		ResourceExists:   true,
+		ResourceUpToDate: isClusterSettingsUpToDate(&cr.Spec.ForProvider, &settings),
+	}, nil
+}
+
+// updateClusterSettingsStatus updates the CR status fields from the cluster settings response
+func updateClusterSettingsStatus(cr *v1alpha1.ClusterSettings, settings *orchardclient.ClusterSettings) {
+	cr.Status.AtProvider.SchedulerProfile = schedulerProfile(settings.SchedulerProfile)
+	cr.Status.AtProvider.HostDirPolicies = nil
+	if settings.HostDirPolicies != nil {
+		for _, p := range *settings.HostDirPolicies {
+			policy := v1alpha1.HostDirPolicy{Ro: p.Ro}
+			if p.PathPrefix != nil {
+				policy.PathPrefix = *p.PathPrefix
+			}
+			cr.Status.AtProvider.HostDirPolicies = append(cr.Status.AtProvider.HostDirPolicies, policy)
+		}
+	}
+}
+
+func (c *external) Create(ctx context.Context, mg resource.Managed) (managed.ExternalCreation, error) {
+	// Cluster settings always exist, so Observe never asks for them to be
+	// created. Apply the desired settings anyway in case it does.
+	_, err := c.Update(ctx, mg)
+	return managed.ExternalCreation{}, err
+}
+
+func (c *external) Update(ctx context.Context, mg resource.Managed) (managed.ExternalUpdate, error) {
+	cr, ok := mg.(*v1alpha1.ClusterSettings)
+	if !ok {
+		return managed.ExternalUpdate{}, errors.New(errNotClusterSettings)
+	}
+
+	return managed.ExternalUpdate{}, c.put(ctx, buildClusterSettings(&cr.Spec.ForProvider))
+}
+
+func (c *external) Delete(ctx context.Context, mg resource.Managed) (managed.ExternalDelete, error) {
+	cr, ok := mg.(*v1alpha1.ClusterSettings)
+	if !ok {
+		return managed.ExternalDelete{}, errors.New(errNotClusterSettings)
+	}
+
+	cr.SetConditions(xpv1.Deleting())
+
+	// Restore Orchard's defaults: no scheduler profile and no hostDir policies.
+	return managed.ExternalDelete{}, c.put(ctx, orchardclient.ClusterSettings{
+		HostDirPolicies: &[]hostDirPolicy{},
+	})
+}
+
+func (c *external) Disconnect(ctx context.Context) error {
+	return nil
+}
+
+func (c *external) put(ctx context.Context, settings orchardclient.ClusterSettings) error {
+	resp, err := c.client.PutClusterSettings(ctx, settings)
+	if err != nil {
+		return errors.Wrap(err, errUpdateClusterSettings)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return errors.Errorf("unexpected status code updating cluster settings: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Helper functions
+
+func buildClusterSettings(params *v1alpha1.ClusterSettingsParameters) orchardclient.ClusterSettings {
+	policies := make([]hostDirPolicy, len(params.HostDirPolicies))
+	for i, p := range params.HostDirPolicies {
+		policies[i] = hostDirPolicy{
+			PathPrefix: &p.PathPrefix,
+			Ro:         p.Ro,
+		}
+	}
+
+	settings := orchardclient.ClusterSettings{
+		HostDirPolicies: &policies,
+	}
+
+	if params.SchedulerProfile != nil {
+		profile := orchardclient.ClusterSettingsSchedulerProfile(*params.SchedulerProfile)
+		settings.SchedulerProfile = &profile
+	}
+
+	return settings
+}
+
+// isClusterSettingsUpToDate compares the desired settings with the observed
+// ones. An unset scheduler profile means Orchard's default, and hostDir
+// policies are compared without regard to order.
+func isClusterSettingsUpToDate(params *v1alpha1.ClusterSettingsParameters, settings *orchardclient.ClusterSettings) bool {
+	desiredProfile := v1alpha1.SchedulerProfileOptimizeUtilization
+	if params.SchedulerProfile != nil {
+		desiredProfile = *params.SchedulerProfile
+	}
+	if desiredProfile != schedulerProfile(settings.SchedulerProfile) {
+		return false
+	}
+
+	desired := make([]string, 0, len(params.HostDirPolicies))
+	for _, p := range params.HostDirPolicies {
+		desired = append(desired, policyKey(p.PathPrefix, p.Ro))
+	}
+
+	var observed []string
+	if settings.HostDirPolicies != nil {
+		for _, p := range *settings.HostDirPolicies {
+			prefix := ""
+			if p.PathPrefix != nil {
+				prefix = *p.PathPrefix
+			}
+			observed = append(observed, policyKey(prefix, p.Ro))
+		}
+	}
+
+	if len(desired) != len(observed) {
+		return false
+	}
+	sort.Strings(desired)
+	sort.Strings(observed)
+	for i := range desired {
+		if desired[i] != observed[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// isDefault reports whether the settings match what Orchard uses when nothing
+// has been configured.
+func isDefault(settings *orchardclient.ClusterSettings) bool {
+	return isClusterSettingsUpToDate(&v1alpha1.ClusterSettingsParameters{}, settings)
+}
+
+// schedulerProfile returns the effective scheduler profile, treating an unset
+// profile as Orchard's default.
+func schedulerProfile(p *orchardclient.ClusterSettingsSchedulerProfile) string {
+	if p == nil || *p == "" {
+		return v1alpha1.SchedulerProfileOptimizeUtilization
+	}
+	return string(*p)
+}
+
+// policyKey returns a comparable representation of a hostDir policy, treating
+// an unset ro flag as false.
+func policyKey(prefix string, ro *bool) string {
+	if ro != nil && *ro {
+		return prefix + ":ro"
+	}
+	return prefix + ":rw"
+}
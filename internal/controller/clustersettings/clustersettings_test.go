@@ -0,0 +1,264 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clustersettings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1alpha1 "github.com/ravan/provider-orchard/apis/admin/v1alpha1"
+	orchardclient "github.com/ravan/provider-orchard/internal/clients/orchard"
+)
+
+// Mock HTTP client for testing
+type mockHTTPClient struct {
+	DoFunc func(req *http.Request) (*http.Response, error)
+}
+
+func (m *mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	return m.DoFunc(req)
+}
+
+// newMockOrchardClient creates an OrchardClient with a mock HTTP client for testing
+func newMockOrchardClient(httpClient *mockHTTPClient) *orchardclient.OrchardClient {
+	client, err := orchardclient.NewClientWithResponses(
+		"http://localhost:6120",
+		orchardclient.WithHTTPClient(httpClient),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return &orchardclient.OrchardClient{
+		ClientWithResponses: client,
+	}
+}
+
+func respondWith(status int, body any) *mockHTTPClient {
+	return &mockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			var b []byte
+			if body != nil {
+				b, _ = json.Marshal(body)
+			}
+			return &http.Response{
+				StatusCode: status,
+				Body:       io.NopCloser(bytes.NewBuffer(b)),
+			}, nil
+		},
+	}
+}
+
+func TestObserve(t *testing.T) {
+	distribute := orchardclient.ClusterSettingsSchedulerProfile(v1alpha1.SchedulerProfileDistributeLoad)
+	deleted := metav1.NewTime(time.Now())
+
+	type want struct {
+		o   managed.ExternalObservation
+		err error
+	}
+
+	cases := map[string]struct {
+		reason   string
+		settings orchardclient.ClusterSettings
+		cr       *v1alpha1.ClusterSettings
+		want     want
+	}{
+		"UpToDate": {
+			reason: "Should report up to date when the scheduler profile matches",
+			settings: orchardclient.ClusterSettings{
+				SchedulerProfile: &distribute,
+			},
+			cr: &v1alpha1.ClusterSettings{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+				Spec: v1alpha1.ClusterSettingsSpec{
+					ForProvider: v1alpha1.ClusterSettingsParameters{
+						SchedulerProfile: ptr(v1alpha1.SchedulerProfileDistributeLoad),
+					},
+				},
+			},
+			want: want{
+				o: managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true},
+			},
+		},
+		"SchedulerProfileDrifted": {
+			reason:   "Should report drift when someone resets the scheduler profile by hand",
+			settings: orchardclient.ClusterSettings{},
+			cr: &v1alpha1.ClusterSettings{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+				Spec: v1alpha1.ClusterSettingsSpec{
+					ForProvider: v1alpha1.ClusterSettingsParameters{
+						SchedulerProfile: ptr(v1alpha1.SchedulerProfileDistributeLoad),
+					},
+				},
+			},
+			want: want{
+				o: managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: false},
+			},
+		},
+		"DeletedAndRestored": {
+			reason:   "Should report the settings as gone once deleted and back at their defaults",
+			settings: orchardclient.ClusterSettings{},
+			cr: &v1alpha1.ClusterSettings{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster", DeletionTimestamp: &deleted},
+			},
+			want: want{
+				o: managed.ExternalObservation{ResourceExists: false},
+			},
+		},
+		"DeletedNotYetRestored": {
+			reason: "Should keep reporting the settings while they still differ from the defaults",
+			settings: orchardclient.ClusterSettings{
+				SchedulerProfile: &distribute,
+			},
+			cr: &v1alpha1.ClusterSettings{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster", DeletionTimestamp: &deleted},
+			},
+			want: want{
+				o: managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: false},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := &external{client: newMockOrchardClient(respondWith(http.StatusOK, tc.settings))}
+			got, err := e.Observe(context.Background(), tc.cr)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.o, got); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestIsClusterSettingsUpToDate(t *testing.T) {
+	cases := map[string]struct {
+		reason   string
+		params   *v1alpha1.ClusterSettingsParameters
+		settings *orchardclient.ClusterSettings
+		want     bool
+	}{
+		"EmptyMatchesDefaults": {
+			reason:   "Should treat an unset profile as optimize-utilization",
+			params:   &v1alpha1.ClusterSettingsParameters{},
+			settings: &orchardclient.ClusterSettings{},
+			want:     true,
+		},
+		"ExplicitDefaultProfile": {
+			reason: "Should treat an explicit optimize-utilization as equal to an unset profile",
+			params: &v1alpha1.ClusterSettingsParameters{
+				SchedulerProfile: ptr(v1alpha1.SchedulerProfileOptimizeUtilization),
+			},
+			settings: &orchardclient.ClusterSettings{},
+			want:     true,
+		},
+		"PoliciesInAnyOrder": {
+			reason: "Should compare hostDir policies without regard to order",
+			params: &v1alpha1.ClusterSettingsParameters{
+				HostDirPolicies: []v1alpha1.HostDirPolicy{
+					{PathPrefix: "/Users/shared"},
+					{PathPrefix: "/opt/cache", Ro: ptr(true)},
+				},
+			},
+			settings: &orchardclient.ClusterSettings{
+				HostDirPolicies: &[]hostDirPolicy{
+					{PathPrefix: ptr("/opt/cache"), Ro: ptr(true)},
+					{PathPrefix: ptr("/Users/shared"), Ro: ptr(false)},
+				},
+			},
+			want: true,
+		},
+		"PolicyReadOnlyDiffers": {
+			reason: "Should detect a policy whose ro flag changed",
+			params: &v1alpha1.ClusterSettingsParameters{
+				HostDirPolicies: []v1alpha1.HostDirPolicy{
+					{PathPrefix: "/opt/cache"},
+				},
+			},
+			settings: &orchardclient.ClusterSettings{
+				HostDirPolicies: &[]hostDirPolicy{
+					{PathPrefix: ptr("/opt/cache"), Ro: ptr(true)},
+				},
+			},
+			want: false,
+		},
+		"PolicyMissing": {
+			reason: "Should detect a policy that was removed in Orchard",
+			params: &v1alpha1.ClusterSettingsParameters{
+				HostDirPolicies: []v1alpha1.HostDirPolicy{
+					{PathPrefix: "/opt/cache"},
+				},
+			},
+			settings: &orchardclient.ClusterSettings{},
+			want:     false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := isClusterSettingsUpToDate(tc.params, tc.settings)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nisClusterSettingsUpToDate(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	var put orchardclient.ClusterSettings
+	client := newMockOrchardClient(&mockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			_ = json.NewDecoder(req.Body).Decode(&put)
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString(""))}, nil
+		},
+	})
+
+	e := &external{client: client}
+	if _, err := e.Delete(context.Background(), &v1alpha1.ClusterSettings{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}); err != nil {
+		t.Fatalf("e.Delete(...): unexpected error: %v", err)
+	}
+
+	if !isDefault(&put) {
+		t.Errorf("e.Delete(...): should restore default cluster settings, got %+v", put)
+	}
+}
+
+func TestUpdateError(t *testing.T) {
+	e := &external{client: newMockOrchardClient(respondWith(http.StatusForbidden, nil))}
+	_, err := e.Update(context.Background(), &v1alpha1.ClusterSettings{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}})
+	want := errors.Errorf("unexpected status code updating cluster settings: %d", http.StatusForbidden)
+	if diff := cmp.Diff(want, err, test.EquateErrors()); diff != "" {
+		t.Errorf("e.Update(...): -want error, +got error:\n%s\n", diff)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
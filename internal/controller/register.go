@@ -20,8 +20,12 @@ import (
 	"github.com/crossplane/crossplane-runtime/v2/pkg/controller"
 	ctrl "sigs.k8s.io/controller-runtime"
 
+	"github.com/ravan/provider-orchard/internal/controller/clustersettings"
 	"github.com/ravan/provider-orchard/internal/controller/config"
+	"github.com/ravan/provider-orchard/internal/controller/serviceaccount"
 	"github.com/ravan/provider-orchard/internal/controller/vm"
+	"github.com/ravan/provider-orchard/internal/controller/vmset"
+	"github.com/ravan/provider-orchard/internal/controller/worker"
 )
 
 // SetupGated creates all Orchard controllers with safe-start support and adds them to
@@ -30,6 +34,10 @@ func SetupGated(mgr ctrl.Manager, o controller.Options) error {
 	for _, setup := range []func(ctrl.Manager, controller.Options) error{
 		config.SetupGated,
 		vm.SetupGated,
+		vmset.SetupGated,
+		worker.SetupGated,
+		serviceaccount.SetupGated,
+		clustersettings.SetupGated,
 	} {
 		if err := setup(mgr, o); err != nil {
 			return err
@@ -0,0 +1,365 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serviceaccount
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/feature"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+
+	"github.com/pkg/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/controller"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/event"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/ratelimiter"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/statemetrics"
+
+	v1alpha1 "github.com/ravan/provider-orchard/apis/admin/v1alpha1"
+	apisv1alpha1 "github.com/ravan/provider-orchard/apis/v1alpha1"
+	orchardclient "github.com/ravan/provider-orchard/internal/clients/orchard"
+)
+
+const (
+	errNotServiceAccount = "managed resource is not a ServiceAccount custom resource"
+	errTrackPCUsage      = "cannot track ProviderConfig usage"
+
+	errNewClient            = "cannot create new Orchard client"
+	errGetServiceAccount    = "cannot get service account"
+	errCreateServiceAccount = "cannot create service account"
+	errUpdateServiceAccount = "cannot update service account"
+	errDeleteServiceAccount = "cannot delete service account"
+	errDecodeServiceAccount = "cannot decode service account response"
+	errBuildCredentials     = "cannot build credentials connection detail"
+
+	// ConnectionDetailCredentials is the connection secret key holding the
+	// token in the {"token": "..."} JSON format read by ProviderConfigs.
+	ConnectionDetailCredentials = "credentials"
+
+	// ConnectionDetailToken is the connection secret key holding the raw token.
+	ConnectionDetailToken = "token"
+)
+
+// SetupGated adds a controller that reconciles ServiceAccount managed resources with safe-start support.
+func SetupGated(mgr ctrl.Manager, o controller.Options) error {
+	o.Gate.Register(func() {
+		if err := Setup(mgr, o); err != nil {
+			panic(errors.Wrap(err, "cannot setup ServiceAccount controller"))
+		}
+	}, v1alpha1.ServiceAccountGroupVersionKind)
+	return nil
+}
+
+func Setup(mgr ctrl.Manager, o controller.Options) error {
+	name := managed.ControllerName(v1alpha1.ServiceAccountGroupKind)
+
+	opts := []managed.ReconcilerOption{
+		managed.WithExternalConnector(&connector{
+			kube:  mgr.GetClient(),
+			usage: resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
+		}),
+		managed.WithLogger(o.Logger.WithValues("controller", name)),
+		managed.WithPollInterval(o.PollInterval),
+		managed.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+	}
+
+	if o.Features.Enabled(feature.EnableBetaManagementPolicies) {
+		opts = append(opts, managed.WithManagementPolicies())
+	}
+
+	if o.Features.Enabled(feature.EnableAlphaChangeLogs) {
+		opts = append(opts, managed.WithChangeLogger(o.ChangeLogOptions.ChangeLogger))
+	}
+
+	if o.MetricOptions != nil {
+		opts = append(opts, managed.WithMetricRecorder(o.MetricOptions.MRMetrics))
+	}
+
+	if o.MetricOptions != nil && o.MetricOptions.MRStateMetrics != nil {
+		stateMetricsRecorder := statemetrics.NewMRStateRecorder(
+			mgr.GetClient(), o.Logger, o.MetricOptions.MRStateMetrics, &v1alpha1.ServiceAccountList{}, o.MetricOptions.PollStateMetricInterval,
+		)
+		if err := mgr.Add(stateMetricsRecorder); err != nil {
+			return errors.Wrap(err, "cannot register MR state metrics recorder for kind v1alpha1.ServiceAccountList")
+		}
+	}
+
+	r := managed.NewReconciler(mgr, resource.ManagedKind(v1alpha1.ServiceAccountGroupVersionKind), opts...)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		WithEventFilter(resource.DesiredStateChanged()).
+		For(&v1alpha1.ServiceAccount{}).
+		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
+}
+
+// A connector is expected to produce an ExternalClient when its Connect method
+// is called.
+type connector struct {
+	kube  client.Client
+	usage *resource.ProviderConfigUsageTracker
+}
+
+// Connect tracks ProviderConfig usage and builds an Orchard client from the
+// credentials of the referenced ProviderConfig.
+func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.ExternalClient, error) {
+	cr, ok := mg.(*v1alpha1.ServiceAccount)
+	if !ok {
+		return nil, errors.New(errNotServiceAccount)
+	}
+
+	if err := c.usage.Track(ctx, cr); err != nil {
+		return nil, errors.Wrap(err, errTrackPCUsage)
+	}
+
+	cfg, err := orchardclient.GetConfig(ctx, c.kube, cr)
+	if err != nil {
+		return nil, err
+	}
+
+	orchardClient, err := orchardclient.NewOrchardClient(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, errNewClient)
+	}
+
+	return &external{client: orchardClient}, nil
+}
+
+// An ExternalClient observes, then either creates, updates, or deletes an
+// external resource to ensure it reflects the managed resource's desired state.
+type external struct {
+	client *orchardclient.OrchardClient
+}
+
+func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
+	cr, ok := mg.(*v1alpha1.ServiceAccount)
+	if !ok {
+		return managed.ExternalObservation{}, errors.New(errNotServiceAccount)
+	}
+
+	saName := meta.GetExternalName(cr)
+	if saName == "" {
+		return managed.ExternalObservation{ResourceExists: false}, nil
+	}
+
+	sa, found, err := c.get(ctx, saName)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+	if !found {
+		return managed.ExternalObservation{ResourceExists: false}, nil
+	}
+
+	cr.Status.AtProvider.Roles = nil
+	if sa.Roles != nil {
+		cr.Status.AtProvider.Roles = *sa.Roles
+	}
+	cr.SetConditions(xpv1.Available())
+
+	details, err := connectionDetails(sa)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+
+	return managed.ExternalObservation{
+		ResourceExists:    true,
+		ResourceUpToDate:  rolesEqual(cr.Spec.ForProvider.Roles, cr.Status.AtProvider.Roles),
+		ConnectionDetails: details,
+	}, nil
+}
+
+// get fetches a service account, reporting whether it exists.
+func (c *external) get(ctx context.Context, name string) (*orchardclient.ServiceAccount, bool, error) {
+	resp, err := c.client.GetServiceAccountsName(ctx, name)
+	if err != nil {
+		return nil, false, errors.Wrap(err, errGetServiceAccount)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, false, nil
+	case http.StatusOK:
+		var sa orchardclient.ServiceAccount
+		if err := json.NewDecoder(resp.Body).Decode(&sa); err != nil {
+			return nil, false, errors.Wrap(err, errDecodeServiceAccount)
+		}
+		return &sa, true, nil
+	default:
+		return nil, false, errors.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+}
+
+func (c *external) Create(ctx context.Context, mg resource.Managed) (managed.ExternalCreation, error) {
+	cr, ok := mg.(*v1alpha1.ServiceAccount)
+	if !ok {
+		return managed.ExternalCreation{}, errors.New(errNotServiceAccount)
+	}
+
+	saName := meta.GetExternalName(cr)
+	roles := cr.Spec.ForProvider.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+
+	resp, err := c.client.PostServiceAccounts(ctx, orchardclient.PostServiceAccountsJSONRequestBody{
+		Name:  &saName,
+		Roles: &roles,
+	})
+	if err != nil {
+		return managed.ExternalCreation{}, errors.Wrap(err, errCreateServiceAccount)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return managed.ExternalCreation{}, errors.Errorf("unexpected status code creating service account: %d", resp.StatusCode)
+	}
+
+	// Orchard echoes the account back with its generated token. Publish it
+	// right away; subsequent observations keep the secret in sync.
+	var sa orchardclient.ServiceAccount
+	if err := json.NewDecoder(resp.Body).Decode(&sa); err != nil {
+		return managed.ExternalCreation{}, nil //nolint:nilerr // The token is published on the next observation.
+	}
+
+	details, err := connectionDetails(&sa)
+	if err != nil {
+		return managed.ExternalCreation{}, err
+	}
+
+	return managed.ExternalCreation{ConnectionDetails: details}, nil
+}
+
+func (c *external) Update(ctx context.Context, mg resource.Managed) (managed.ExternalUpdate, error) {
+	cr, ok := mg.(*v1alpha1.ServiceAccount)
+	if !ok {
+		return managed.ExternalUpdate{}, errors.New(errNotServiceAccount)
+	}
+
+	saName := meta.GetExternalName(cr)
+	if saName == "" {
+		return managed.ExternalUpdate{}, errors.New("external name not set")
+	}
+
+	// Fetch the current account so the PUT preserves its token.
+	sa, found, err := c.get(ctx, saName)
+	if err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+	if !found {
+		return managed.ExternalUpdate{}, errors.Errorf("service account %q not found", saName)
+	}
+
+	roles := cr.Spec.ForProvider.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	sa.Name = &saName
+	sa.Roles = &roles
+
+	resp, err := c.client.PutServiceAccountsName(ctx, saName, *sa)
+	if err != nil {
+		return managed.ExternalUpdate{}, errors.Wrap(err, errUpdateServiceAccount)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return managed.ExternalUpdate{}, errors.Errorf("unexpected status code updating service account: %d", resp.StatusCode)
+	}
+
+	return managed.ExternalUpdate{}, nil
+}
+
+func (c *external) Delete(ctx context.Context, mg resource.Managed) (managed.ExternalDelete, error) {
+	cr, ok := mg.(*v1alpha1.ServiceAccount)
+	if !ok {
+		return managed.ExternalDelete{}, errors.New(errNotServiceAccount)
+	}
+
+	saName := meta.GetExternalName(cr)
+	if saName == "" {
+		// Nothing to delete
+		return managed.ExternalDelete{}, nil
+	}
+
+	cr.SetConditions(xpv1.Deleting())
+
+	resp, err := c.client.DeleteServiceAccountsName(ctx, saName)
+	if err != nil {
+		return managed.ExternalDelete{}, errors.Wrap(err, errDeleteServiceAccount)
+	}
+	defer resp.Body.Close()
+
+	// 404 is acceptable - service account is already deleted
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return managed.ExternalDelete{}, errors.Errorf("unexpected status code deleting service account: %d", resp.StatusCode)
+	}
+
+	return managed.ExternalDelete{}, nil
+}
+
+func (c *external) Disconnect(ctx context.Context) error {
+	return nil
+}
+
+// connectionDetails builds the connection secret for a service account. The
+// credentials key uses the same {"token": "..."} format that ProviderConfig
+// credentials are parsed from, so the secret can back another ProviderConfig.
+func connectionDetails(sa *orchardclient.ServiceAccount) (managed.ConnectionDetails, error) {
+	if sa.Token == nil || *sa.Token == "" {
+		return nil, nil
+	}
+
+	creds, err := json.Marshal(map[string]string{"token": *sa.Token})
+	if err != nil {
+		return nil, errors.Wrap(err, errBuildCredentials)
+	}
+
+	return managed.ConnectionDetails{
+		ConnectionDetailCredentials: creds,
+		ConnectionDetailToken:       []byte(*sa.Token),
+	}, nil
+}
+
+// rolesEqual reports whether two role lists contain the same roles,
+// ignoring order.
+func rolesEqual(desired, observed []string) bool {
+	if len(desired) != len(observed) {
+		return false
+	}
+
+	d := append([]string(nil), desired...)
+	o := append([]string(nil), observed...)
+	sort.Strings(d)
+	sort.Strings(o)
+
+	for i := range d {
+		if d[i] != o[i] {
+			return false
+		}
+	}
+	return true
+}
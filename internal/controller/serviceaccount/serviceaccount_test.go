@@ -0,0 +1,309 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serviceaccount
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1alpha1 "github.com/ravan/provider-orchard/apis/admin/v1alpha1"
+	orchardclient "github.com/ravan/provider-orchard/internal/clients/orchard"
+)
+
+// Mock HTTP client for testing
+type mockHTTPClient struct {
+	DoFunc func(req *http.Request) (*http.Response, error)
+}
+
+func (m *mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	return m.DoFunc(req)
+}
+
+// newMockOrchardClient creates an OrchardClient with a mock HTTP client for testing
+func newMockOrchardClient(httpClient *mockHTTPClient) *orchardclient.OrchardClient {
+	client, err := orchardclient.NewClientWithResponses(
+		"http://localhost:6120",
+		orchardclient.WithHTTPClient(httpClient),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return &orchardclient.OrchardClient{
+		ClientWithResponses: client,
+	}
+}
+
+func respondWith(status int, body any) *mockHTTPClient {
+	return &mockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			var b []byte
+			if body != nil {
+				b, _ = json.Marshal(body)
+			}
+			return &http.Response{
+				StatusCode: status,
+				Body:       io.NopCloser(bytes.NewBuffer(b)),
+			}, nil
+		},
+	}
+}
+
+func newServiceAccount(name string, roles ...string) *v1alpha1.ServiceAccount {
+	sa := &v1alpha1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Spec: v1alpha1.ServiceAccountSpec{
+			ForProvider: v1alpha1.ServiceAccountParameters{
+				Roles: roles,
+			},
+		},
+	}
+	meta.SetExternalName(sa, name)
+	return sa
+}
+
+func TestObserve(t *testing.T) {
+	saName := "team-a"
+	token := "secret-token"
+
+	details := managed.ConnectionDetails{
+		ConnectionDetailCredentials: []byte(`{"token":"secret-token"}`),
+		ConnectionDetailToken:       []byte(token),
+	}
+
+	type want struct {
+		o   managed.ExternalObservation
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		client *orchardclient.OrchardClient
+		mg     resource.Managed
+		want   want
+	}{
+		"NoExternalName": {
+			reason: "Should return ResourceExists=false if external name is not set",
+			mg:     &v1alpha1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: saName}},
+			want: want{
+				o: managed.ExternalObservation{ResourceExists: false},
+			},
+		},
+		"NotFound": {
+			reason: "Should return ResourceExists=false if the service account doesn't exist",
+			client: newMockOrchardClient(respondWith(http.StatusNotFound, nil)),
+			mg:     newServiceAccount(saName, "compute:read"),
+			want: want{
+				o: managed.ExternalObservation{ResourceExists: false},
+			},
+		},
+		"UpToDateInAnyOrder": {
+			reason: "Should treat roles as up to date regardless of order and publish the token",
+			client: newMockOrchardClient(respondWith(http.StatusOK, orchardclient.ServiceAccount{
+				Name:  &saName,
+				Roles: &[]string{"compute:write", "compute:read"},
+				Token: &token,
+			})),
+			mg: newServiceAccount(saName, "compute:read", "compute:write"),
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:    true,
+					ResourceUpToDate:  true,
+					ConnectionDetails: details,
+				},
+			},
+		},
+		"RolesDrifted": {
+			reason: "Should report drift when roles differ",
+			client: newMockOrchardClient(respondWith(http.StatusOK, orchardclient.ServiceAccount{
+				Name:  &saName,
+				Roles: &[]string{"compute:read", "admin:write"},
+				Token: &token,
+			})),
+			mg: newServiceAccount(saName, "compute:read"),
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:    true,
+					ResourceUpToDate:  false,
+					ConnectionDetails: details,
+				},
+			},
+		},
+		"UnexpectedStatus": {
+			reason: "Should return an error on an unexpected status code",
+			client: newMockOrchardClient(respondWith(http.StatusInternalServerError, nil)),
+			mg:     newServiceAccount(saName),
+			want: want{
+				err: errors.Errorf("unexpected status code: %d", http.StatusInternalServerError),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := &external{client: tc.client}
+			got, err := e.Observe(context.Background(), tc.mg)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.o, got); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	saName := "team-a"
+	token := "generated-token"
+
+	type want struct {
+		c   managed.ExternalCreation
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		client *orchardclient.OrchardClient
+		mg     resource.Managed
+		want   want
+	}{
+		"SuccessfulCreate": {
+			reason: "Should create the service account and publish the generated token",
+			client: newMockOrchardClient(respondWith(http.StatusCreated, orchardclient.ServiceAccount{
+				Name:  &saName,
+				Roles: &[]string{"compute:read"},
+				Token: &token,
+			})),
+			mg: newServiceAccount(saName, "compute:read"),
+			want: want{
+				c: managed.ExternalCreation{
+					ConnectionDetails: managed.ConnectionDetails{
+						ConnectionDetailCredentials: []byte(`{"token":"generated-token"}`),
+						ConnectionDetailToken:       []byte(token),
+					},
+				},
+			},
+		},
+		"Conflict": {
+			reason: "Should return an error if the service account already exists",
+			client: newMockOrchardClient(respondWith(http.StatusConflict, nil)),
+			mg:     newServiceAccount(saName, "compute:read"),
+			want: want{
+				err: errors.Errorf("unexpected status code creating service account: %d", http.StatusConflict),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := &external{client: tc.client}
+			got, err := e.Create(context.Background(), tc.mg)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Create(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.c, got); diff != "" {
+				t.Errorf("\n%s\ne.Create(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	saName := "team-a"
+	token := "secret-token"
+
+	var put orchardclient.ServiceAccount
+	client := newMockOrchardClient(&mockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			if req.Method == http.MethodPut {
+				_ = json.NewDecoder(req.Body).Decode(&put)
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString(""))}, nil
+			}
+			body, _ := json.Marshal(orchardclient.ServiceAccount{
+				Name:  &saName,
+				Roles: &[]string{"compute:read"},
+				Token: &token,
+			})
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBuffer(body))}, nil
+		},
+	})
+
+	e := &external{client: client}
+	if _, err := e.Update(context.Background(), newServiceAccount(saName, "compute:read", "compute:write")); err != nil {
+		t.Fatalf("e.Update(...): unexpected error: %v", err)
+	}
+
+	want := orchardclient.ServiceAccount{
+		Name:  &saName,
+		Roles: &[]string{"compute:read", "compute:write"},
+		Token: &token,
+	}
+	if diff := cmp.Diff(want, put); diff != "" {
+		t.Errorf("e.Update(...): PUT body should carry desired roles and preserve the token: -want, +got:\n%s\n", diff)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		client *orchardclient.OrchardClient
+		mg     resource.Managed
+		want   error
+	}{
+		"SuccessfulDelete": {
+			reason: "Should delete the service account",
+			client: newMockOrchardClient(respondWith(http.StatusOK, nil)),
+			mg:     newServiceAccount("team-a"),
+		},
+		"AlreadyDeleted": {
+			reason: "Should handle 404 gracefully (already deleted)",
+			client: newMockOrchardClient(respondWith(http.StatusNotFound, nil)),
+			mg:     newServiceAccount("team-a"),
+		},
+		"DeleteError": {
+			reason: "Should return error on unexpected status code",
+			client: newMockOrchardClient(respondWith(http.StatusInternalServerError, nil)),
+			mg:     newServiceAccount("team-a"),
+			want:   errors.Errorf("unexpected status code deleting service account: %d", http.StatusInternalServerError),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := &external{client: tc.client}
+			_, err := e.Delete(context.Background(), tc.mg)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Delete(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,457 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/ravan/provider-orchard/internal/ssh"
+)
+
+const (
+	errParseUserData = "cannot parse userData"
+	errUploadSuffix  = "cannot generate upload names"
+
+	// cloudConfigHeader is the first line of every cloud-config document
+	cloudConfigHeader = "#cloud-config"
+
+	// cloudConfigFileName is the name files written by a cloud-config
+	// document are uploaded under, in the guest's temporary directory,
+	// before they are moved into place. It is followed by a suffix unique
+	// to each run and the file's index.
+	cloudConfigFileName = "cloudinit-userdata-%s-%d"
+
+	// cloudConfigRunCmdName is the name the runcmd script is uploaded under,
+	// in the guest's temporary directory, with a suffix unique to each run
+	cloudConfigRunCmdName = "cloudinit-runcmd-%s.sh"
+
+	// cloudConfigRoot is the user commands that change the system run as
+	cloudConfigRoot = "root"
+
+	// defaultFilePermissions are the permissions of written files that do
+	// not set any, as in cloud-init
+	defaultFilePermissions = 0o644
+)
+
+// A cloudConfig is the subset of a #cloud-config document the provider
+// applies. See https://cloudinit.readthedocs.io/en/latest/reference/modules.html
+type cloudConfig struct {
+	Hostname          string               `json:"hostname,omitempty"`
+	WriteFiles        []cloudConfigFile    `json:"write_files,omitempty"`
+	Users             []cloudConfigUser    `json:"users,omitempty"`
+	SSHAuthorizedKeys []string             `json:"ssh_authorized_keys,omitempty"`
+	RunCmd            []cloudConfigCommand `json:"runcmd,omitempty"`
+}
+
+// supportedCloudConfigKeys are the top-level keys a cloudConfig applies
+var supportedCloudConfigKeys = []string{"hostname", "write_files", "users", "ssh_authorized_keys", "runcmd"}
+
+// A cloudConfigFile is an entry of write_files.
+type cloudConfigFile struct {
+	Path        string    `json:"path"`
+	Content     string    `json:"content,omitempty"`
+	Encoding    string    `json:"encoding,omitempty"`
+	Owner       string    `json:"owner,omitempty"`
+	Permissions *fileMode `json:"permissions,omitempty"`
+	Append      bool      `json:"append,omitempty"`
+	Defer       bool      `json:"defer,omitempty"`
+}
+
+// A fileMode is a file's permissions. Like cloud-init, it accepts an octal
+// string such as '0644' or an integer, which YAML reads 0644 as.
+type fileMode uint32
+
+// UnmarshalJSON reads a file mode from an octal string or an integer.
+func (m *fileMode) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var n uint32
+		if err := json.Unmarshal(b, &n); err != nil {
+			return errors.Errorf("invalid permissions %s", b)
+		}
+		*m = fileMode(n)
+		return nil
+	}
+	n, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return errors.Errorf("invalid permissions %q", s)
+	}
+	*m = fileMode(n)
+	return nil
+}
+
+// A cloudConfigUser is an entry of users. The entry "default" stands for the
+// SSH user, which already exists.
+type cloudConfigUser struct {
+	Name              string
+	SSHAuthorizedKeys []string
+	Sudo              []string
+}
+
+// UnmarshalJSON reads a user from its name or from an object. Like
+// cloud-init, sudo may be a single rule, a list of rules or false.
+func (u *cloudConfigUser) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &u.Name); err == nil {
+		return nil
+	}
+	var obj struct {
+		Name              string          `json:"name"`
+		SSHAuthorizedKeys []string        `json:"ssh_authorized_keys,omitempty"`
+		Sudo              json.RawMessage `json:"sudo,omitempty"`
+	}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return err
+	}
+	u.Name, u.SSHAuthorizedKeys = obj.Name, obj.SSHAuthorizedKeys
+	if len(obj.Sudo) == 0 {
+		return nil
+	}
+	var rule string
+	if err := json.Unmarshal(obj.Sudo, &rule); err == nil {
+		u.Sudo = []string{rule}
+		return nil
+	}
+	var disabled bool
+	if err := json.Unmarshal(obj.Sudo, &disabled); err == nil && !disabled {
+		return nil
+	}
+	if err := json.Unmarshal(obj.Sudo, &u.Sudo); err != nil {
+		return errors.Errorf("invalid sudo rules for user %q", u.Name)
+	}
+	return nil
+}
+
+// A cloudConfigCommand is an entry of runcmd. Like cloud-init, it accepts a
+// shell command or a list of arguments, which are quoted.
+type cloudConfigCommand string
+
+// UnmarshalJSON reads a command from a string or a list of arguments.
+func (c *cloudConfigCommand) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*c = cloudConfigCommand(s)
+		return nil
+	}
+	var args []string
+	if err := json.Unmarshal(b, &args); err != nil {
+		return errors.Errorf("invalid runcmd entry %s", b)
+	}
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	*c = cloudConfigCommand(strings.Join(quoted, " "))
+	return nil
+}
+
+// A cloudConfigAction is a single change a cloud-config document makes to
+// a VM: an optional file uploaded over SFTP, followed by a command.
+type cloudConfigAction struct {
+	// Description is logged before the action runs
+	Description string
+
+	// Upload is uploaded to UploadPath, if set
+	Upload      []byte
+	UploadPath  string
+	Permissions uint32
+
+	// Command runs once the upload completed
+	Command string
+
+	// RunAs is the user Command runs as, with sudo. Commands run as the SSH
+	// user if it is empty.
+	RunAs string
+}
+
+// parseCloudConfig parses a #cloud-config document. It also returns the
+// top-level keys it does not support, which are ignored.
+func parseCloudConfig(userData string) (*cloudConfig, []string, error) {
+	if !strings.HasPrefix(userData, cloudConfigHeader) {
+		return nil, nil, errors.Errorf("%s: must start with %s", errParseUserData, cloudConfigHeader)
+	}
+
+	keys := map[string]json.RawMessage{}
+	if err := yaml.Unmarshal([]byte(userData), &keys); err != nil {
+		return nil, nil, errors.Wrap(err, errParseUserData)
+	}
+	var unsupported []string
+	for k := range keys {
+		if !slices.Contains(supportedCloudConfigKeys, k) {
+			unsupported = append(unsupported, k)
+		}
+	}
+	slices.Sort(unsupported)
+
+	cfg := &cloudConfig{}
+	if err := yaml.Unmarshal([]byte(userData), cfg); err != nil {
+		return nil, nil, errors.Wrap(err, errParseUserData)
+	}
+	return cfg, unsupported, nil
+}
+
+// cloudConfigActions translates a cloud-config document into the actions
+// that apply it, in the order cloud-init applies its modules: hostname,
+// write_files, users, deferred write_files and finally runcmd. Commands that
+// change the system run as root, as cloud-init does. Uploads are named with
+// the supplied suffix, so other users of the VM cannot predict their paths.
+func cloudConfigActions(cfg *cloudConfig, p guestProfile, suffix string) ([]cloudConfigAction, error) {
+	var actions []cloudConfigAction
+	uploads := 0
+	upload := func(a cloudConfigAction, data []byte, perm uint32) cloudConfigAction {
+		a.Upload, a.UploadPath, a.Permissions = data, p.tempPath(fmt.Sprintf(cloudConfigFileName, suffix, uploads)), perm
+		uploads++
+		return a
+	}
+
+	if cfg.Hostname != "" {
+		actions = append(actions, cloudConfigAction{
+			Description: "hostname " + cfg.Hostname,
+			Command:     p.hostnameCommand(cfg.Hostname),
+			RunAs:       cloudConfigRoot,
+		})
+	}
+
+	writeFiles := func(deferred bool) error {
+		for _, f := range cfg.WriteFiles {
+			if f.Defer != deferred {
+				continue
+			}
+			if f.Path == "" {
+				return errors.New("write_files entry has no path")
+			}
+			content, err := decodeFileContent(f.Content, f.Encoding)
+			if err != nil {
+				return errors.Wrapf(err, "cannot decode content of %s", f.Path)
+			}
+			a := upload(cloudConfigAction{Description: "write_files " + f.Path, RunAs: cloudConfigRoot}, content, 0o600)
+			a.Command = writeFileCommand(a.UploadPath, f)
+			actions = append(actions, a)
+		}
+		return nil
+	}
+	if err := writeFiles(false); err != nil {
+		return nil, err
+	}
+
+	keys := cfg.SSHAuthorizedKeys
+	for _, u := range cfg.Users {
+		if u.Name == "" {
+			return nil, errors.New("users entry has no name")
+		}
+		if u.Name == "default" {
+			keys = append(keys, u.SSHAuthorizedKeys...)
+			continue
+		}
+		actions = append(actions, cloudConfigAction{Description: "users " + u.Name, Command: p.addUserCommand(u.Name), RunAs: cloudConfigRoot})
+		if len(u.Sudo) > 0 {
+			var rules strings.Builder
+			for _, r := range u.Sudo {
+				fmt.Fprintf(&rules, "%s %s\n", u.Name, r)
+			}
+			a := upload(cloudConfigAction{Description: "users " + u.Name + " sudo", RunAs: cloudConfigRoot}, []byte(rules.String()), 0o600)
+			a.Command = sudoersCommand(a.UploadPath, u.Name)
+			actions = append(actions, a)
+		}
+		if len(u.SSHAuthorizedKeys) > 0 {
+			// root switches to the user without a password, and removes the
+			// upload the user may not
+			a := upload(cloudConfigAction{Description: "users " + u.Name + " ssh_authorized_keys", RunAs: cloudConfigRoot}, authorizedKeysFile(u.SSHAuthorizedKeys), 0o644)
+			a.Command = withCleanup(fmt.Sprintf("sudo -H -u %s sh -c %s", shellQuote(u.Name), shellQuote(authorizeKeysCommand(a.UploadPath))), a.UploadPath)
+			actions = append(actions, a)
+		}
+	}
+	if len(keys) > 0 {
+		// Keys of the default user are authorized for the SSH user
+		a := upload(cloudConfigAction{Description: "ssh_authorized_keys"}, authorizedKeysFile(keys), 0o644)
+		a.Command = withCleanup(authorizeKeysCommand(a.UploadPath), a.UploadPath)
+		actions = append(actions, a)
+	}
+
+	if err := writeFiles(true); err != nil {
+		return nil, err
+	}
+
+	if len(cfg.RunCmd) > 0 {
+		runCmdPath := p.tempPath(fmt.Sprintf(cloudConfigRunCmdName, suffix))
+		var script strings.Builder
+		script.WriteString("#!/bin/sh\n")
+		for _, c := range cfg.RunCmd {
+			script.WriteString(string(c))
+			script.WriteString("\n")
+		}
+		actions = append(actions, cloudConfigAction{
+			Description: "runcmd",
+			Upload:      []byte(script.String()),
+			UploadPath:  runCmdPath,
+			Permissions: 0o755,
+			Command:     withCleanup(shellQuote(runCmdPath), runCmdPath),
+			RunAs:       cloudConfigRoot,
+		})
+	}
+	return actions, nil
+}
+
+// runCloudConfig applies a #cloud-config document to a VM over an open
+// session. It stops at the first action that fails, and returns the combined
+// output of the actions it ran, each preceded by its description.
+func runCloudConfig(ctx context.Context, session ssh.VMSession, userData string, p guestProfile) (*ssh.CommandResult, error) {
+	cfg, unsupported, err := parseCloudConfig(userData)
+	if err != nil {
+		return nil, err
+	}
+	suffix, err := ssh.UniqueSuffix()
+	if err != nil {
+		return nil, errors.Wrap(err, errUploadSuffix)
+	}
+	actions, err := cloudConfigActions(cfg, p, suffix)
+	if err != nil {
+		return nil, errors.Wrap(err, errParseUserData)
+	}
+
+	var stdout, stderr strings.Builder
+	if len(unsupported) > 0 {
+		fmt.Fprintf(&stdout, "ignoring unsupported cloud-config keys: %s\n", strings.Join(unsupported, ", "))
+	}
+	result := func(code int) *ssh.CommandResult {
+		return &ssh.CommandResult{ExitCode: code, Stdout: stdout.String(), Stderr: stderr.String()}
+	}
+
+	for _, a := range actions {
+		fmt.Fprintf(&stdout, "==> %s\n", a.Description)
+		if a.UploadPath != "" {
+			if err := session.UploadBytes(ctx, a.Upload, ssh.FileUploadOptions{
+				RemotePath:  a.UploadPath,
+				Permissions: a.Permissions,
+				CreateDirs:  true,
+			}); err != nil {
+				return result(0), errors.Wrapf(err, "cannot upload %s", a.Description)
+			}
+		}
+		var r *ssh.CommandResult
+		if a.RunAs != "" {
+			r, err = session.ExecuteCommandAs(ctx, a.Command, ssh.SudoOptions{User: a.RunAs, Escalation: p.escalation})
+		} else {
+			r, err = session.ExecuteCommand(ctx, a.Command)
+		}
+		if r != nil {
+			stdout.WriteString(r.Stdout)
+			stderr.WriteString(r.Stderr)
+		}
+		if err != nil {
+			return result(0), err
+		}
+		if r.ExitCode != 0 {
+			return result(r.ExitCode), nil
+		}
+	}
+	return result(0), nil
+}
+
+// decodeFileContent decodes the content of a write_files entry.
+func decodeFileContent(content, encoding string) ([]byte, error) {
+	switch strings.ToLower(encoding) {
+	case "", "text/plain":
+		return []byte(content), nil
+	case "b64", "base64":
+		return base64.StdEncoding.DecodeString(content)
+	case "gz", "gzip":
+		return gunzip([]byte(content))
+	case "gz+b64", "gz+base64", "gzip+b64", "gzip+base64":
+		b, err := base64.StdEncoding.DecodeString(content)
+		if err != nil {
+			return nil, err
+		}
+		return gunzip(b)
+	}
+	return nil, errors.Errorf("unsupported encoding %q", encoding)
+}
+
+// gunzip decompresses gzipped data.
+func gunzip(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close() //nolint:errcheck // Reading succeeded
+	return io.ReadAll(r)
+}
+
+// writeFileCommand moves an uploaded file into place, or appends it to the
+// existing file, then sets its owner and permissions. It must run as root.
+func writeFileCommand(uploadPath string, f cloudConfigFile) string {
+	mode := uint32(defaultFilePermissions)
+	if f.Permissions != nil {
+		mode = uint32(*f.Permissions)
+	}
+	dst, src := shellQuote(f.Path), shellQuote(uploadPath)
+
+	cmd := []string{"mkdir -p " + shellQuote(path.Dir(f.Path))}
+	if f.Append {
+		cmd = append(cmd, fmt.Sprintf("cat %s >> %s", src, dst))
+	} else {
+		cmd = append(cmd, fmt.Sprintf("cp %s %s", src, dst))
+	}
+	cmd = append(cmd, fmt.Sprintf("chmod %04o %s", mode, dst))
+	if f.Owner != "" {
+		cmd = append(cmd, fmt.Sprintf("chown %s %s", shellQuote(f.Owner), dst))
+	}
+	return withCleanup(strings.Join(cmd, " && "), uploadPath)
+}
+
+// sudoersCommand validates an uploaded sudoers file and installs it as the
+// user's drop-in. It must run as root.
+func sudoersCommand(uploadPath, name string) string {
+	src, dst := shellQuote(uploadPath), shellQuote("/etc/sudoers.d/"+name)
+	return withCleanup(fmt.Sprintf("visudo -cf %[1]s && mkdir -p /etc/sudoers.d && install -m 0440 %[1]s %[2]s", src, dst), uploadPath)
+}
+
+// authorizedKeysFile returns the SSH public keys to authorize, one per line.
+func authorizedKeysFile(keys []string) []byte {
+	return []byte(strings.Join(keys, "\n") + "\n")
+}
+
+// authorizeKeysCommand adds the keys of an uploaded file to the current
+// user's authorized keys, skipping keys that are already authorized.
+func authorizeKeysCommand(uploadPath string) string {
+	return "mkdir -p ~/.ssh && chmod 700 ~/.ssh && touch ~/.ssh/authorized_keys && " +
+		`while IFS= read -r key; do [ -z "$key" ] || grep -qxF "$key" ~/.ssh/authorized_keys || echo "$key" >> ~/.ssh/authorized_keys; done < ` +
+		shellQuote(uploadPath) + " && chmod 600 ~/.ssh/authorized_keys"
+}
+
+// withCleanup removes an uploaded file once a command ran, keeping the
+// command's exit code.
+func withCleanup(command, uploadPath string) string {
+	return command + "; status=$?; rm -f " + shellQuote(uploadPath) + "; exit $status"
+}
+
+// shellQuote quotes a string for use as a single shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vm
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	v1alpha1 "github.com/ravan/provider-orchard/apis/compute/v1alpha1"
+	"github.com/ravan/provider-orchard/internal/ssh"
+)
+
+// A guestProfile holds what the provider assumes about a VM's guest OS.
+type guestProfile struct {
+	// os is the guest OS, one of the v1alpha1.GuestOS constants
+	os string
+
+	// username and password are the SSH credentials of VMs that set none
+	username string
+	password string
+
+	// tempDir is where scripts and files are uploaded before they run or
+	// are moved into place
+	tempDir string
+
+	// shell runs scripts that have no shebang and set no interpreter
+	shell string
+
+	// escalation runs commands as root or another user, and decides how it
+	// is given the SSH password
+	escalation ssh.Escalation
+
+	// bootIDCommand prints a value that changes every time the VM boots
+	bootIDCommand string
+}
+
+// guestProfiles are the supported guest OS profiles. Linux scripts are
+// uploaded to /var/tmp, as /tmp is often a noexec tmpfs there. macOS sudo is
+// given the SSH password, as the admin user of macOS images may need one.
+// Linux images grant their default user passwordless sudo, like cloud-init
+// does, so sudo runs non-interactively and never receives the password.
+var guestProfiles = map[string]guestProfile{
+	v1alpha1.GuestOSMacOS: {
+		os:            v1alpha1.GuestOSMacOS,
+		username:      DefaultSSHUsername,
+		password:      DefaultSSHPassword,
+		tempDir:       "/tmp",
+		shell:         "/bin/zsh",
+		escalation:    ssh.Escalation{Command: "sudo -S -p '' -H -u %s --", Password: ssh.PasswordStdin},
+		bootIDCommand: "sysctl -n kern.boottime",
+	},
+	v1alpha1.GuestOSLinux: {
+		os:            v1alpha1.GuestOSLinux,
+		username:      DefaultSSHUsername,
+		password:      DefaultSSHPassword,
+		tempDir:       "/var/tmp",
+		shell:         "/bin/bash",
+		escalation:    ssh.Escalation{Command: "sudo -n -H -u %s --", Password: ssh.PasswordNone},
+		bootIDCommand: "cat /proc/sys/kernel/random/boot_id",
+	},
+}
+
+// guestProfileFor returns the profile of a guest OS. VMs that do not set one
+// are assumed to run macOS.
+func guestProfileFor(os string) guestProfile {
+	if p, ok := guestProfiles[os]; ok {
+		return p
+	}
+	return guestProfiles[v1alpha1.GuestOSMacOS]
+}
+
+// tempPath returns the path of a file in the profile's temporary directory.
+func (p guestProfile) tempPath(name string) string {
+	return path.Join(p.tempDir, name)
+}
+
+// stepScriptPath returns where the script of a provisioning step is
+// uploaded, followed by a suffix unique to each run.
+func (p guestProfile) stepScriptPath(index int) string {
+	return p.tempPath(fmt.Sprintf(stepScriptName, index))
+}
+
+// interpreter returns the interpreter a script runs with: the one it sets,
+// none if it starts with a shebang, or the profile's shell otherwise.
+func (p guestProfile) interpreter(script, interpreter string) string {
+	if interpreter != "" || strings.HasPrefix(script, "#!") {
+		return interpreter
+	}
+	return p.shell
+}
+
+// hostnameCommand sets the VM's hostname, with scutil on macOS and
+// hostnamectl on Linux. It must run as root.
+func (p guestProfile) hostnameCommand(hostname string) string {
+	h := shellQuote(hostname)
+	if p.os == v1alpha1.GuestOSLinux {
+		return "hostnamectl set-hostname " + h
+	}
+	short := shellQuote(strings.SplitN(hostname, ".", 2)[0])
+	return fmt.Sprintf("scutil --set HostName %[1]s && scutil --set LocalHostName %[2]s && scutil --set ComputerName %[1]s", h, short)
+}
+
+// addUserCommand creates a user unless it exists, with sysadminctl on macOS
+// and useradd on Linux. It must run as root.
+func (p guestProfile) addUserCommand(name string) string {
+	n := shellQuote(name)
+	if p.os == v1alpha1.GuestOSLinux {
+		return fmt.Sprintf("id -u %[1]s >/dev/null 2>&1 || useradd -m %[1]s", n)
+	}
+	return fmt.Sprintf("id -u %[1]s >/dev/null 2>&1 || { sysadminctl -addUser %[1]s && createhomedir -c -u %[1]s >/dev/null; }", n)
+}
+
+// rebootCommand reboots the VM. It must run as root.
+func (p guestProfile) rebootCommand() string {
+	return "shutdown -r now"
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	v1alpha1 "github.com/ravan/provider-orchard/apis/compute/v1alpha1"
+	"github.com/ravan/provider-orchard/internal/ssh"
+)
+
+const (
+	errWriteProvisioningLogs    = "cannot write provisioning logs"
+	errProvisioningLogsNotOwned = "ConfigMap %s/%s already exists and is not controlled by the VM; rename or delete it to keep provisioning logs"
+
+	// provisioningLogsSuffix is appended to a VM's name to name the ConfigMap
+	// holding its provisioning logs
+	provisioningLogsSuffix = "-provisioning-logs"
+
+	// maxStepLogLen caps the output kept per step, so the logs of many steps
+	// fit in a single ConfigMap. The end of the output is kept.
+	maxStepLogLen = 32 * 1024
+
+	// maxProvisioningLogsLen caps the logs kept across all steps, keeping the
+	// ConfigMap well below the 1MiB size limit of Kubernetes objects. Steps
+	// logged once the cap is reached keep only the end of their output.
+	maxProvisioningLogsLen = 512 * 1024
+
+	// maxLogTailLen caps the output reported in status messages
+	maxLogTailLen = 512
+)
+
+// invalidConfigMapKeyChars matches characters not allowed in ConfigMap keys
+var invalidConfigMapKeyChars = regexp.MustCompile(`[^-._a-zA-Z0-9]`)
+
+// provisioningLogsName returns the name of the ConfigMap holding a VM's
+// provisioning logs.
+func provisioningLogsName(cr *v1alpha1.VM) string {
+	return shortenVMName(cr.GetName(), validation.DNS1123SubdomainMaxLength-len(provisioningLogsSuffix)) + provisioningLogsSuffix
+}
+
+// stepLogKey returns the ConfigMap key holding a step's log.
+func stepLogKey(name string) string {
+	return invalidConfigMapKeyChars.ReplaceAllString(name, "_") + ".log"
+}
+
+// stepLog returns the log of a step's run: its stdout, followed by its
+// stderr and the error that stopped it, if any.
+func stepLog(result *ssh.CommandResult, err error) string {
+	var b strings.Builder
+	if result != nil {
+		b.WriteString(result.Stdout)
+		if result.Stderr != "" {
+			b.WriteString("\n--- stderr ---\n")
+			b.WriteString(result.Stderr)
+		}
+	}
+	if err != nil {
+		b.WriteString("\n--- error ---\n")
+		b.WriteString(err.Error())
+	}
+	return capLog(b.String(), maxStepLogLen)
+}
+
+// logTail returns the last part of a step's stderr, or of its stdout if it
+// wrote nothing to stderr.
+func logTail(result *ssh.CommandResult) string {
+	out := strings.TrimSpace(result.Stderr)
+	if out == "" {
+		out = strings.TrimSpace(result.Stdout)
+	}
+	if len(out) <= maxLogTailLen {
+		return out
+	}
+	return "..." + tail(out, maxLogTailLen-3)
+}
+
+// capLog keeps the last max bytes of a log, noting how much was dropped.
+func capLog(log string, max int) string {
+	if len(log) <= max {
+		return log
+	}
+	kept := tail(log, max)
+	return fmt.Sprintf("[%d bytes truncated]\n", len(log)-len(kept)) + kept
+}
+
+// tail returns at most the last max bytes of s, without splitting a rune.
+func tail(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	i := len(s) - max
+	for i < len(s) && !utf8.RuneStart(s[i]) {
+		i++
+	}
+	return s[i:]
+}
+
+// writeStepLog stores a step's log in the ConfigMap holding the VM's
+// provisioning logs, creating it if needed. The ConfigMap is owned by the VM,
+// so it is garbage collected with it. A ConfigMap of the same name that the
+// VM does not control is left alone.
+func writeStepLog(ctx context.Context, kube client.Client, cr *v1alpha1.VM, step, log string) error {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: cr.GetNamespace(), Name: provisioningLogsName(cr)}}
+	_, err := controllerutil.CreateOrUpdate(ctx, kube, cm, func() error {
+		if cm.GetResourceVersion() != "" && !metav1.IsControlledBy(cm, cr) {
+			return errors.Errorf(errProvisioningLogsNotOwned, cm.GetNamespace(), cm.GetName())
+		}
+		meta.AddOwnerReference(cm, meta.AsController(meta.TypedReferenceTo(cr, v1alpha1.VMGroupVersionKind)))
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		key := stepLogKey(step)
+		cm.Data[key] = capLog(log, max(maxProvisioningLogsLen-logsLen(cm.Data, key)-len(key), 0))
+		return nil
+	})
+	return errors.Wrap(err, errWriteProvisioningLogs)
+}
+
+// logsLen returns the size of the logs in a ConfigMap's data, except for the
+// log under the supplied key.
+func logsLen(data map[string]string, except string) int {
+	n := 0
+	for k, v := range data {
+		if k != except {
+			n += len(k) + len(v)
+		}
+	}
+	return n
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vm
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	v1alpha1 "github.com/ravan/provider-orchard/apis/compute/v1alpha1"
+)
+
+const (
+	errGetScriptOutputs   = "cannot get script outputs Secret"
+	errWriteScriptOutputs = "cannot write script outputs"
+	errOutputsNotOwned    = "Secret %s/%s already exists and is not controlled by the VM; rename or delete it to keep sensitive outputs"
+	errInvalidOutputLine  = "invalid output on line %d: expected key=value or key<<delimiter"
+	errInvalidOutputKey   = "invalid output key %q on line %d"
+	errUnterminatedOutput = "output %q is missing its closing delimiter %q"
+
+	// scriptOutputVar names the file scripts write their outputs to
+	scriptOutputVar = "ORCHARD_OUTPUT"
+
+	// scriptOutputsSuffix is appended to a VM's name to name the Secret
+	// holding its sensitive script outputs
+	scriptOutputsSuffix = "-script-outputs"
+)
+
+// parseOutputs parses the outputs a script wrote to $ORCHARD_OUTPUT. Like
+// GitHub's $GITHUB_OUTPUT, each output is either a key=value line, or a
+// key<<delimiter line followed by a multiline value and the delimiter on a
+// line of its own. Keys must be valid Secret keys.
+func parseOutputs(content string) (map[string]string, error) {
+	outputs := map[string]string{}
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if k, delim, found := strings.Cut(line, "<<"); found && (!ok || len(k) < len(key)) {
+			if len(validation.IsConfigMapKey(k)) > 0 {
+				return nil, errors.Errorf(errInvalidOutputKey, k, i+1)
+			}
+			if delim == "" {
+				return nil, errors.Errorf(errInvalidOutputLine, i+1)
+			}
+			end := slices.Index(lines[i+1:], delim)
+			if end < 0 {
+				return nil, errors.Errorf(errUnterminatedOutput, k, delim)
+			}
+			outputs[k] = strings.Join(lines[i+1:i+1+end], "\n")
+			i += end + 1
+			continue
+		}
+		if !ok {
+			return nil, errors.Errorf(errInvalidOutputLine, i+1)
+		}
+		if len(validation.IsConfigMapKey(key)) > 0 {
+			return nil, errors.Errorf(errInvalidOutputKey, key, i+1)
+		}
+		outputs[key] = value
+	}
+	return outputs, nil
+}
+
+// splitOutputs splits a script's outputs into those published to status and
+// those listed as sensitive.
+func splitOutputs(outputs map[string]string, sensitive []string) (plain, secret map[string]string) {
+	for k, v := range outputs {
+		if slices.Contains(sensitive, k) {
+			if secret == nil {
+				secret = map[string]string{}
+			}
+			secret[k] = v
+			continue
+		}
+		if plain == nil {
+			plain = map[string]string{}
+		}
+		plain[k] = v
+	}
+	return plain, secret
+}
+
+// mergeOutputs returns a VM's outputs updated with those of a provisioning
+// job, without the keys now listed as sensitive.
+func mergeOutputs(outputs, update map[string]string, sensitive []string) map[string]string {
+	merged := make(map[string]string, len(outputs)+len(update))
+	for k, v := range outputs {
+		merged[k] = v
+	}
+	for k, v := range update {
+		merged[k] = v
+	}
+	for _, k := range sensitive {
+		delete(merged, k)
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// scriptOutputsName returns the name of the Secret holding a VM's sensitive
+// script outputs.
+func scriptOutputsName(cr *v1alpha1.VM) string {
+	return shortenVMName(cr.GetName(), validation.DNS1123SubdomainMaxLength-len(scriptOutputsSuffix)) + scriptOutputsSuffix
+}
+
+// writeSensitiveOutputs stores a script's sensitive outputs in the Secret
+// holding the VM's sensitive outputs, creating it if needed. The Secret is
+// owned by the VM, so it is garbage collected with it. A Secret of the same
+// name that the VM does not control is left alone.
+func writeSensitiveOutputs(ctx context.Context, kube client.Client, cr *v1alpha1.VM, outputs map[string]string) error {
+	s := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: cr.GetNamespace(), Name: scriptOutputsName(cr)}}
+	_, err := controllerutil.CreateOrUpdate(ctx, kube, s, func() error {
+		if s.GetResourceVersion() != "" && !metav1.IsControlledBy(s, cr) {
+			return errors.Errorf(errOutputsNotOwned, s.GetNamespace(), s.GetName())
+		}
+		meta.AddOwnerReference(s, meta.AsController(meta.TypedReferenceTo(cr, v1alpha1.VMGroupVersionKind)))
+		if s.Data == nil {
+			s.Data = map[string][]byte{}
+		}
+		for k, v := range outputs {
+			s.Data[k] = []byte(v)
+		}
+		return nil
+	})
+	return errors.Wrap(err, errWriteScriptOutputs)
+}
+
+// readSensitiveOutputs returns the sensitive script outputs of a VM that are
+// still listed in its spec, to be published to its connection secret.
+func readSensitiveOutputs(ctx context.Context, kube client.Client, cr *v1alpha1.VM) (map[string][]byte, error) {
+	sensitive := cr.Spec.ForProvider.SensitiveOutputs
+	if len(sensitive) == 0 {
+		return nil, nil
+	}
+	s := &corev1.Secret{}
+	if err := kube.Get(ctx, types.NamespacedName{Namespace: cr.GetNamespace(), Name: scriptOutputsName(cr)}, s); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, errGetScriptOutputs)
+	}
+	if !metav1.IsControlledBy(s, cr) {
+		// Not ours, so none of its keys are the VM's outputs
+		return nil, nil
+	}
+	outputs := map[string][]byte{}
+	for _, k := range sensitive {
+		if v, ok := s.Data[k]; ok {
+			outputs[k] = v
+		}
+	}
+	return outputs, nil
+}
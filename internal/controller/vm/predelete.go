@@ -0,0 +1,118 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vm
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/event"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1alpha1 "github.com/ravan/provider-orchard/apis/compute/v1alpha1"
+)
+
+const (
+	// PreDeleteScriptStep is the name a VM's pre-delete script is reported
+	// under in its status and logs.
+	PreDeleteScriptStep = "preDeleteScript"
+
+	errPreDeleteScript = "pre-delete script failed"
+
+	// vmStatusRunning is the Orchard status of a running VM
+	vmStatusRunning = "running"
+
+	// defaultPreDeleteTimeout limits pre-delete scripts whose timeout the API
+	// server did not default
+	defaultPreDeleteTimeout = 5 * time.Minute
+)
+
+// preDelete runs a VM's pre-delete script before the VM is deleted, and
+// returns true once the VM may be deleted. The script runs in the background
+// like a provisioning step, so Delete returns without deleting the VM until
+// it finishes. A failed script blocks deletion, and the returned error says
+// why, if the script's failure policy is Block.
+func (c *external) preDelete(cr *v1alpha1.VM) (bool, error) {
+	s := cr.Spec.ForProvider.PreDeleteScript
+	if s == nil || s.ScriptContent == "" {
+		c.provisioner.Forget(cr)
+		return true, nil
+	}
+
+	job, ok := c.provisioner.Job(cr)
+	if !ok || !job.PreDelete {
+		// Stop provisioning the VM before running the script
+		c.provisioner.Forget(cr)
+		if cr.Status.AtProvider.Status != vmStatusRunning {
+			c.recorder.Event(cr, event.Normal(reasonPreDeleteScriptSkipped, fmt.Sprintf("Skipping the pre-delete script because VM %q is not running", meta.GetExternalName(cr))))
+			return true, nil
+		}
+
+		step := v1alpha1.VMProvisioningStep{
+			Name:            PreDeleteScriptStep,
+			ScriptContent:   s.ScriptContent,
+			Env:             s.Env,
+			Timeout:         s.Timeout,
+			VMScriptOptions: s.VMScriptOptions,
+		}
+		if step.Timeout == nil {
+			step.Timeout = &metav1.Duration{Duration: defaultPreDeleteTimeout}
+		}
+		st := v1alpha1.VMProvisioningStepStatus{Name: PreDeleteScriptStep, Status: CloudInitStatusPending}
+		c.provisioner.Run(cr, &provisionJob{
+			steps:      []v1alpha1.VMProvisioningStep{step},
+			statuses:   []v1alpha1.VMProvisioningStepStatus{st},
+			generation: cr.GetGeneration(),
+			config:     c.buildTunnelConfig(cr),
+			preDelete:  true,
+		})
+		cr.Status.AtProvider.PreDeleteScript = &st
+		return false, nil
+	}
+
+	st, ran := job.Results[PreDeleteScriptStep]
+	if ran {
+		cr.Status.AtProvider.PreDeleteScript = &st
+	}
+	if !job.Done {
+		return false, nil
+	}
+	c.provisioner.Forget(cr)
+
+	var err error
+	switch {
+	case job.Err != nil:
+		err = job.Err
+	case !ran || st.Status == CloudInitStatusPending:
+		err = errors.New("the script was interrupted")
+	case st.Status == CloudInitStatusFailed:
+		err = errors.New(st.Message)
+	}
+	if err == nil {
+		c.recorder.Event(cr, event.Normal(reasonPreDeleteScriptCompleted, fmt.Sprintf("Pre-delete script completed in %s", st.Duration.Duration)))
+		return true, nil
+	}
+
+	if s.OnFailure == v1alpha1.PreDeleteFailurePolicyBlock {
+		c.recorder.Event(cr, event.Warning(reasonPreDeleteScriptFailed, errors.Wrap(err, "Pre-delete script failed; keeping the VM to run it again")))
+		return false, errors.Wrap(err, errPreDeleteScript)
+	}
+	c.recorder.Event(cr, event.Warning(reasonPreDeleteScriptFailed, errors.Wrap(err, "Pre-delete script failed; deleting the VM anyway")))
+	return true, nil
+}
@@ -0,0 +1,375 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vm
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	v1alpha1 "github.com/ravan/provider-orchard/apis/compute/v1alpha1"
+	"github.com/ravan/provider-orchard/internal/ssh"
+)
+
+const (
+	// Probe defaults, used where the API server did not default a field
+	defaultProbePeriod           = 10 * time.Second
+	defaultProbeTimeout          = 5 * time.Second
+	defaultProbeFailureThreshold = 3
+	defaultProbeSuccessThreshold = 1
+
+	// maxProbeBodyLen caps how much of an HTTP probe's response is read
+	maxProbeBodyLen = 4 * 1024
+)
+
+// A probeSpec is everything a VM's probes depend on. The probes are
+// restarted when it changes.
+type probeSpec struct {
+	readiness *v1alpha1.VMProbe
+	liveness  *v1alpha1.VMProbe
+	config    ssh.TunnelConfig
+}
+
+// A probeState tracks the results of a single probe.
+type probeState struct {
+	status     string
+	successes  int32
+	failures   int32
+	transition time.Time
+	message    string
+}
+
+// A vmProbes is the set of probes running against a single VM.
+type vmProbes struct {
+	spec      probeSpec
+	cancel    context.CancelFunc
+	readiness *probeState
+	liveness  *probeState
+}
+
+// A prober periodically runs the readiness and liveness probes of
+// provisioned VMs in the background, and enqueues a VM whenever one of its
+// probes changes status, so Observe can report it.
+type prober struct {
+	newSession func(ctx context.Context, config ssh.TunnelConfig) (ssh.VMSession, error)
+	dial       func(ctx context.Context, config ssh.TunnelConfig, port int) (net.Conn, error)
+	events     chan event.GenericEvent
+
+	mu  sync.Mutex
+	ctx context.Context
+	vms map[types.NamespacedName]*vmProbes
+}
+
+// newProber returns a prober. Probes only run once it is started by the
+// controller manager.
+func newProber() *prober {
+	return &prober{
+		newSession: ssh.NewVMSession,
+		dial:       ssh.DialPort,
+		events:     make(chan event.GenericEvent),
+		vms:        map[types.NamespacedName]*vmProbes{},
+	}
+}
+
+// Start runs the prober until the supplied context is done. All probes stop
+// when it is.
+func (p *prober) Start(ctx context.Context) error {
+	p.mu.Lock()
+	p.ctx = ctx
+	p.mu.Unlock()
+
+	<-ctx.Done()
+	return nil
+}
+
+// Source returns the source that enqueues VMs whose probes changed status.
+func (p *prober) Source() source.Source {
+	return source.Channel(p.events, &handler.EnqueueRequestForObject{})
+}
+
+// Probe ensures a VM's probes are running with the supplied spec. Probes
+// running with an outdated spec start over.
+func (p *prober) Probe(cr *v1alpha1.VM, spec probeSpec) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ctx == nil || p.ctx.Err() != nil {
+		return
+	}
+
+	nn := types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()}
+	if old, ok := p.vms[nn]; ok {
+		if reflect.DeepEqual(old.spec, spec) {
+			return
+		}
+		old.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(p.ctx)
+	w := &vmProbes{spec: spec, cancel: cancel}
+	if spec.readiness != nil {
+		w.readiness = &probeState{status: v1alpha1.ProbeStatusUnknown}
+		go p.run(ctx, nn, spec.config, spec.readiness, w.readiness)
+	}
+	if spec.liveness != nil {
+		w.liveness = &probeState{status: v1alpha1.ProbeStatusUnknown}
+		go p.run(ctx, nn, spec.config, spec.liveness, w.liveness)
+	}
+	p.vms[nn] = w
+}
+
+// Stop stops probing a VM and forgets the results of its probes.
+func (p *prober) Stop(cr *v1alpha1.VM) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	nn := types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()}
+	if w, ok := p.vms[nn]; ok {
+		w.cancel()
+		delete(p.vms, nn)
+	}
+}
+
+// Results returns the status of a VM's readiness and liveness probes. The
+// status of a probe that is not running is nil.
+func (p *prober) Results(cr *v1alpha1.VM) (readiness, liveness *v1alpha1.VMProbeStatus) {
+	if p == nil {
+		return nil, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	w, ok := p.vms[types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()}]
+	if !ok {
+		return nil, nil
+	}
+	return w.readiness.probeStatus(), w.liveness.probeStatus()
+}
+
+// probeStatus returns the API representation of a probe's state.
+func (s *probeState) probeStatus() *v1alpha1.VMProbeStatus {
+	if s == nil {
+		return nil
+	}
+	st := &v1alpha1.VMProbeStatus{Status: s.status, ConsecutiveFailures: s.failures, Message: s.message}
+	if !s.transition.IsZero() {
+		st.LastTransitionTime = &metav1.Time{Time: s.transition}
+	}
+	return st
+}
+
+// run runs a probe every period until its context is done.
+func (p *prober) run(ctx context.Context, nn types.NamespacedName, config ssh.TunnelConfig, probe *v1alpha1.VMProbe, st *probeState) {
+	period := defaultProbePeriod
+	if probe.PeriodSeconds > 0 {
+		period = time.Duration(probe.PeriodSeconds) * time.Second
+	}
+
+	for {
+		err := p.check(ctx, config, probe)
+		if ctx.Err() != nil {
+			return
+		}
+		if p.record(probe, st, err) {
+			// Let Observe report the new status
+			enqueueVM(ctx, p.events, nn)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(period):
+		}
+	}
+}
+
+// record records the result of a probe, and returns true if it changed the
+// probe's status.
+func (p *prober) record(probe *v1alpha1.VMProbe, st *probeState, err error) bool {
+	failureThreshold, successThreshold := probe.FailureThreshold, probe.SuccessThreshold
+	if failureThreshold == 0 {
+		failureThreshold = defaultProbeFailureThreshold
+	}
+	if successThreshold == 0 {
+		successThreshold = defaultProbeSuccessThreshold
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := st.status
+	if err == nil {
+		st.successes++
+		st.failures = 0
+		st.message = ""
+		if st.successes >= successThreshold {
+			status = v1alpha1.ProbeStatusSuccess
+		}
+	} else {
+		st.failures++
+		st.successes = 0
+		st.message = truncateMessage(err.Error())
+		if st.failures >= failureThreshold {
+			status = v1alpha1.ProbeStatusFailure
+		}
+	}
+
+	if status == st.status {
+		return false
+	}
+	st.status = status
+	st.transition = time.Now()
+	return true
+}
+
+// check runs a probe once. It returns why the probe failed, if it did.
+func (p *prober) check(ctx context.Context, config ssh.TunnelConfig, probe *v1alpha1.VMProbe) error {
+	timeout := defaultProbeTimeout
+	if probe.TimeoutSeconds > 0 {
+		timeout = time.Duration(probe.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	switch {
+	case probe.Exec != nil:
+		session, err := p.newSession(ctx, config)
+		if err != nil {
+			return err
+		}
+		defer session.Close()
+		result, err := session.ExecuteCommand(ctx, probe.Exec.Command)
+		if err != nil {
+			return err
+		}
+		if result.ExitCode != 0 {
+			return errors.Errorf("command exited with code %d: %s", result.ExitCode, logTail(result))
+		}
+		return nil
+	case probe.TCPSocket != nil:
+		conn, err := p.dial(ctx, config, int(probe.TCPSocket.Port))
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case probe.HTTPGet != nil:
+		return p.checkHTTP(ctx, config, probe.HTTPGet)
+	}
+	return errors.New("probe has no action")
+}
+
+// checkHTTP sends an HTTP probe's request through Orchard's port-forward
+// endpoint. Certificates are not verified, as VMs rarely have one for the
+// name Orchard knows them by.
+func (p *prober) checkHTTP(ctx context.Context, config ssh.TunnelConfig, action *v1alpha1.VMHTTPGetAction) error {
+	port := int(action.Port)
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return p.dial(ctx, config, port)
+		},
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true}, //nolint:gosec // Probes only check that the VM responds
+		DisableKeepAlives: true,
+	}}
+
+	scheme := "http"
+	if strings.EqualFold(action.Scheme, "HTTPS") {
+		scheme = "https"
+	}
+	path := action.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s://%s:%d%s", scheme, config.VMName, port, path), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxProbeBodyLen))
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return errors.Errorf("HTTP probe returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// handleProbes probes a provisioned VM and reports the results in its status
+// and Ready condition. The VM is only available while its readiness probe
+// succeeds and its liveness probe does not fail. A failed liveness probe
+// marks the VM for recreation if its liveness failure action asks for it.
+func (c *external) handleProbes(cr *v1alpha1.VM) {
+	params := &cr.Spec.ForProvider
+	if (params.ReadinessProbe == nil && params.LivenessProbe == nil) || cr.GetCondition(xpv1.TypeReady).Reason != xpv1.ReasonAvailable {
+		// Probes run once the VM is provisioned
+		c.prober.Stop(cr)
+		cr.Status.AtProvider.ReadinessProbe = nil
+		cr.Status.AtProvider.LivenessProbe = nil
+		return
+	}
+
+	c.prober.Probe(cr, probeSpec{readiness: params.ReadinessProbe, liveness: params.LivenessProbe, config: c.buildTunnelConfig(cr)})
+	readiness, liveness := c.prober.Results(cr)
+	cr.Status.AtProvider.ReadinessProbe = readiness
+	cr.Status.AtProvider.LivenessProbe = liveness
+
+	switch {
+	case liveness != nil && liveness.Status == v1alpha1.ProbeStatusFailure:
+		msg := "liveness probe failed: " + liveness.Message
+		if params.LivenessFailureAction == v1alpha1.LivenessFailureActionRecreate {
+			c.unhealthy = msg
+		}
+		cond := xpv1.Unavailable()
+		cond.Message = truncateMessage(msg)
+		cr.SetConditions(cond)
+	case readiness != nil && readiness.Status == v1alpha1.ProbeStatusFailure:
+		cond := xpv1.Unavailable()
+		cond.Message = truncateMessage("readiness probe failed: " + readiness.Message)
+		cr.SetConditions(cond)
+	case readiness != nil && readiness.Status == v1alpha1.ProbeStatusUnknown:
+		cond := xpv1.Creating()
+		cond.Message = "waiting for the readiness probe to succeed"
+		cr.SetConditions(cond)
+	}
+}
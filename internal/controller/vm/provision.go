@@ -0,0 +1,486 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1alpha1 "github.com/ravan/provider-orchard/apis/compute/v1alpha1"
+	"github.com/ravan/provider-orchard/internal/ssh"
+)
+
+const (
+	// StartupScriptStep is the name the startup script is reported under in
+	// the provisioning step status.
+	StartupScriptStep = "startupScript"
+
+	// UserDataStep is the name a VM's #cloud-config user data is reported
+	// under in the provisioning step status.
+	UserDataStep = "userData"
+
+	// RebootExitCode is the exit code a script exits with to complete its
+	// step and request a reboot of the VM.
+	RebootExitCode = 194
+
+	// rebootMarkerVar names the file a script creates to request a reboot
+	// once it exits successfully
+	rebootMarkerVar = "ORCHARD_REBOOT_MARKER"
+
+	// Reboot defaults
+	defaultRebootTimeout = 10 * time.Minute
+	rebootCommandTimeout = 30 * time.Second
+
+	// stepScriptName is the name the script of each step is uploaded under,
+	// in the guest's temporary directory, followed by a suffix unique to
+	// each run
+	stepScriptName = "cloudinit-step-%d"
+
+	// Length of the content hash recorded for each step
+	stepHashLength = 16
+
+	// Retry policy defaults
+	defaultMaxAttempts     = 3
+	defaultRetryBackoff    = 10 * time.Second
+	defaultMaxRetryBackoff = 5 * time.Minute
+)
+
+// provisioningSteps returns the steps to provision a VM with, starting with
+// its user data and its resolved startup script. The user data step's script
+// is the #cloud-config document. Steps without a retry policy use the VM's.
+func provisioningSteps(startup *v1alpha1.VMStartupScript, params *v1alpha1.VMParameters) []v1alpha1.VMProvisioningStep {
+	var steps []v1alpha1.VMProvisioningStep
+	if params.UserData != "" {
+		steps = append(steps, v1alpha1.VMProvisioningStep{
+			Name:          UserDataStep,
+			ScriptContent: params.UserData,
+		})
+	}
+	if s := startup; s != nil && s.ScriptContent != "" {
+		steps = append(steps, v1alpha1.VMProvisioningStep{
+			Name:            StartupScriptStep,
+			ScriptContent:   s.ScriptContent,
+			Env:             s.Env,
+			VMScriptOptions: s.VMScriptOptions,
+		})
+	}
+	steps = append(steps, params.ProvisioningSteps...)
+	for i := range steps {
+		if steps[i].RetryPolicy == nil {
+			steps[i].RetryPolicy = params.RetryPolicy
+		}
+	}
+	return steps
+}
+
+// syncStepStatuses returns a status for every step, in the order the steps
+// run. Steps keep the status they already had, so reordering or adding steps
+// does not rerun those that completed. Statuses of removed steps are dropped.
+func syncStepStatuses(steps []v1alpha1.VMProvisioningStep, observed []v1alpha1.VMProvisioningStepStatus) []v1alpha1.VMProvisioningStepStatus {
+	byName := make(map[string]v1alpha1.VMProvisioningStepStatus, len(observed))
+	for _, st := range observed {
+		byName[st.Name] = st
+	}
+
+	statuses := make([]v1alpha1.VMProvisioningStepStatus, 0, len(steps))
+	for _, step := range steps {
+		st, ok := byName[step.Name]
+		if !ok {
+			st = v1alpha1.VMProvisioningStepStatus{Name: step.Name, Status: CloudInitStatusPending}
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses
+}
+
+// stepHash returns the content hash of a step's script, env and the options
+// it runs with. Options that are not set leave the hash unchanged, so steps
+// that ran before options existed are not rerun.
+func stepHash(step v1alpha1.VMProvisioningStep) string {
+	// Maps are marshalled with sorted keys, so the hash is stable.
+	b, _ := json.Marshal(struct {
+		Script      string            `json:"script"`
+		Env         map[string]string `json:"env,omitempty"`
+		Interpreter string            `json:"interpreter,omitempty"`
+		WorkingDir  string            `json:"workingDir,omitempty"`
+		RunAs       string            `json:"runAs,omitempty"`
+	}{step.ScriptContent, step.Env, step.Interpreter, step.WorkingDir, step.RunAs})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])[:stepHashLength]
+}
+
+// changedSteps returns the indexes of steps that ran with a script or env
+// other than the current one.
+func changedSteps(steps []v1alpha1.VMProvisioningStep, statuses []v1alpha1.VMProvisioningStepStatus) []int {
+	var changed []int
+	for i, st := range statuses {
+		if stepDone(steps[i], st) && st.ContentHash != "" && st.ContentHash != stepHash(steps[i]) {
+			changed = append(changed, i)
+		}
+	}
+	return changed
+}
+
+// stepDone returns true if a step does not need to run.
+func stepDone(step v1alpha1.VMProvisioningStep, st v1alpha1.VMProvisioningStepStatus) bool {
+	return st.Status == CloudInitStatusCompleted || (st.Status == CloudInitStatusFailed && step.ContinueOnFailure)
+}
+
+// nextStep returns the index of the next step to run, or -1 if every step is
+// done or provisioning is blocked by a failed step. A failed step blocks
+// provisioning until its script, env or options change; changes to the rest
+// of the VM's spec leave it failed.
+func nextStep(steps []v1alpha1.VMProvisioningStep, statuses []v1alpha1.VMProvisioningStepStatus) int {
+	for i, st := range statuses {
+		if stepDone(steps[i], st) {
+			continue
+		}
+		if st.Status == CloudInitStatusFailed && st.ContentHash == stepHash(steps[i]) {
+			return -1
+		}
+		return i
+	}
+	return -1
+}
+
+// handleCloudInit records the progress of a VM's provisioning job, and starts
+// a job for the steps the VM has not completed yet. Steps run in the
+// background, so it never blocks on SSH.
+func (c *external) handleCloudInit(cr *v1alpha1.VM) error {
+	steps := provisioningSteps(c.startup, &cr.Spec.ForProvider)
+	if len(steps) == 0 {
+		// Nothing to provision - VM is available immediately
+		c.provisioner.Forget(cr)
+		cr.Status.AtProvider.ProvisioningSteps = nil
+		cr.SetConditions(xpv1.Available())
+		return nil
+	}
+
+	// VMs provisioned before steps existed only record the startup script's
+	// outcome. Carry it over instead of running the script again.
+	if len(cr.Status.AtProvider.ProvisioningSteps) == 0 && steps[0].Name == StartupScriptStep {
+		switch st := getCloudInitStatus(cr); st {
+		case CloudInitStatusCompleted, CloudInitStatusFailed:
+			cr.Status.AtProvider.ProvisioningSteps = []v1alpha1.VMProvisioningStepStatus{{
+				Name:               StartupScriptStep,
+				Status:             st,
+				Message:            cr.Status.AtProvider.CloudInitMessage,
+				ObservedGeneration: cr.GetGeneration(),
+			}}
+		}
+	}
+
+	if job, ok := c.provisioner.Job(cr); ok {
+		cr.Status.AtProvider.ProvisioningSteps = mergeStepResults(cr.Status.AtProvider.ProvisioningSteps, job.Results)
+		cr.Status.AtProvider.Outputs = mergeOutputs(cr.Status.AtProvider.Outputs, job.Outputs, cr.Spec.ForProvider.SensitiveOutputs)
+		if job.Logs != "" {
+			cr.Status.AtProvider.ProvisioningLogsRef = &corev1.LocalObjectReference{Name: job.Logs}
+		}
+		if !job.Done {
+			cr.Status.AtProvider.ProvisioningSteps = syncStepStatuses(steps, cr.Status.AtProvider.ProvisioningSteps)
+			setJobProgress(cr, job)
+			return nil
+		}
+		c.provisioner.Forget(cr)
+		if job.Err != nil {
+			// SSH not ready yet - return error to retry later
+			cr.Status.AtProvider.ProvisioningSteps = syncStepStatuses(steps, cr.Status.AtProvider.ProvisioningSteps)
+			setCloudInitStatus(cr, CloudInitStatusPending, "waiting for SSH")
+			cr.SetConditions(xpv1.Creating())
+			return job.Err
+		}
+	}
+
+	cr.Status.AtProvider.ProvisioningSteps = syncStepStatuses(steps, cr.Status.AtProvider.ProvisioningSteps)
+	statuses := cr.Status.AtProvider.ProvisioningSteps
+
+	// Steps that finished or failed before their hash was recorded are
+	// assumed to have run with their current content.
+	for i := range statuses {
+		finished := stepDone(steps[i], statuses[i]) || statuses[i].Status == CloudInitStatusFailed
+		if finished && statuses[i].ContentHash == "" {
+			statuses[i].ContentHash = stepHash(steps[i])
+		}
+	}
+
+	if changed := changedSteps(steps, statuses); len(changed) > 0 {
+		switch cr.Spec.ForProvider.ReprovisionPolicy {
+		case v1alpha1.ReprovisionPolicyIgnore:
+		case v1alpha1.ReprovisionPolicyRecreate:
+			// Update recreates the VM, which provisions it from scratch
+			for _, i := range changed {
+				c.reprovision = append(c.reprovision, fmt.Sprintf("provisioning step %q", steps[i].Name))
+			}
+			cr.SetConditions(xpv1.Creating())
+			return nil
+		default:
+			for _, i := range changed {
+				statuses[i].Status = CloudInitStatusPending
+				statuses[i].Attempts = 0
+				statuses[i].FirstAttemptTime = nil
+			}
+		}
+	}
+
+	next := nextStep(steps, statuses)
+	if next < 0 {
+		setProvisioningResult(cr, steps)
+		return nil
+	}
+
+	job := &provisionJob{
+		steps:      steps,
+		statuses:   make([]v1alpha1.VMProvisioningStepStatus, len(statuses)),
+		from:       next,
+		generation: cr.GetGeneration(),
+		config:     c.buildTunnelConfig(cr),
+	}
+	for i := range statuses {
+		statuses[i].DeepCopyInto(&job.statuses[i])
+	}
+	if c.provisioner.Run(cr, job) {
+		setCloudInitStatus(cr, CloudInitStatusPending, fmt.Sprintf("waiting to run step %q", steps[next].Name))
+	} else {
+		setCloudInitStatus(cr, CloudInitStatusPending, "waiting for the provisioner to start")
+	}
+	cr.SetConditions(xpv1.Creating())
+	return nil
+}
+
+// mergeStepResults returns the supplied step statuses, updated with the
+// results of a provisioning job.
+func mergeStepResults(statuses []v1alpha1.VMProvisioningStepStatus, results map[string]v1alpha1.VMProvisioningStepStatus) []v1alpha1.VMProvisioningStepStatus {
+	merged := make([]v1alpha1.VMProvisioningStepStatus, 0, len(statuses)+len(results))
+	seen := make(map[string]bool, len(statuses))
+	for _, st := range statuses {
+		if r, ok := results[st.Name]; ok {
+			st = r
+		}
+		seen[st.Name] = true
+		merged = append(merged, st)
+	}
+	for name, r := range results {
+		if !seen[name] {
+			merged = append(merged, r)
+		}
+	}
+	return merged
+}
+
+// setJobProgress reports a running provisioning job in the VM's cloud-init
+// status.
+func setJobProgress(cr *v1alpha1.VM, job jobStatus) {
+	switch {
+	case !job.Started:
+		setCloudInitStatus(cr, CloudInitStatusPending, "waiting for a free provisioning slot")
+	case job.Current == "":
+		setCloudInitStatus(cr, CloudInitStatusRunning, "waiting for SSH")
+	case job.Rebooting:
+		setCloudInitStatus(cr, CloudInitStatusRunning, fmt.Sprintf("waiting for the VM to reboot after step %q", job.Current))
+	case job.Results[job.Current].Status == CloudInitStatusPending:
+		// The step failed and is waiting to be retried
+		setCloudInitStatus(cr, CloudInitStatusRunning, fmt.Sprintf("step %q %s", job.Current, job.Results[job.Current].Message))
+	default:
+		setCloudInitStatus(cr, CloudInitStatusRunning, fmt.Sprintf("running step %q", job.Current))
+	}
+	cr.SetConditions(xpv1.Creating())
+}
+
+// A stepRun is what a provisioning step's run left behind.
+type stepRun struct {
+	// log is the step's output
+	log string
+
+	// outputs are the outputs the step wrote, if it completed
+	outputs map[string]string
+
+	// reboot is true if the step completed and requested a reboot
+	reboot bool
+}
+
+// runStep runs a provisioning step's script and records its outcome. A step
+// that wrote outputs that cannot be parsed fails. A step that exits with
+// RebootExitCode or creates its reboot marker completes, and requests a
+// reboot. A step interrupted because its job was cancelled stays pending. A
+// step that could not reach the VM also stays pending, and the error is
+// returned; it does not count as an attempt.
+func runStep(ctx context.Context, session ssh.VMSession, index int, step v1alpha1.VMProvisioningStep, st *v1alpha1.VMProvisioningStepStatus, generation int64, p guestProfile) (stepRun, error) {
+	stepCtx := ctx
+	if step.Timeout != nil {
+		var cancel context.CancelFunc
+		stepCtx, cancel = context.WithTimeout(ctx, step.Timeout.Duration)
+		defer cancel()
+	}
+
+	start := time.Now()
+	result, err := execStep(stepCtx, session, index, step, p)
+	if ctx.Err() != nil {
+		st.Status = CloudInitStatusPending
+		return stepRun{}, nil
+	}
+	run := stepRun{log: stepLog(result, err)}
+	if transientSSHError(err) {
+		st.Status = CloudInitStatusPending
+		st.Message = truncateMessage(err.Error())
+		return run, err
+	}
+
+	if st.FirstAttemptTime == nil {
+		st.FirstAttemptTime = &metav1.Time{Time: start}
+	}
+	st.Attempts++
+	st.Duration = &metav1.Duration{Duration: time.Since(start).Round(time.Millisecond)}
+	st.ObservedGeneration = generation
+	st.ContentHash = stepHash(step)
+	st.ExitCode = nil
+	st.Message = ""
+
+	switch {
+	case errors.Is(err, ssh.ErrTimeout) && step.Timeout != nil:
+		st.Status = CloudInitStatusFailed
+		st.Message = fmt.Sprintf("timed out after %s", step.Timeout.Duration)
+		return run, nil
+	case err != nil:
+		st.Status = CloudInitStatusFailed
+		st.Message = truncateMessage(err.Error())
+		return run, nil
+	}
+
+	code := int32(result.ExitCode)
+	st.ExitCode = &code
+	run.reboot = result.ExitCode == RebootExitCode || (result.ExitCode == 0 && result.Marked)
+	if result.ExitCode != 0 && !run.reboot {
+		st.Status = CloudInitStatusFailed
+		st.Message = truncateMessage(fmt.Sprintf("exit code %d: %s", result.ExitCode, logTail(result)))
+		return run, nil
+	}
+
+	outputs, err := parseOutputs(result.Output)
+	if err != nil {
+		st.Status = CloudInitStatusFailed
+		st.Message = truncateMessage(err.Error())
+		run.reboot = false
+		return run, nil
+	}
+	st.Status = CloudInitStatusCompleted
+	if run.reboot {
+		st.Message = "requested a reboot"
+	}
+	run.outputs = outputs
+	return run, nil
+}
+
+// execStep runs a step on a VM with the supplied guest profile. The user
+// data step applies its #cloud-config document; any other step runs its
+// script, which may write outputs to $ORCHARD_OUTPUT and request a reboot
+// by creating $ORCHARD_REBOOT_MARKER.
+func execStep(ctx context.Context, session ssh.VMSession, index int, step v1alpha1.VMProvisioningStep, p guestProfile) (*ssh.CommandResult, error) {
+	if step.Name == UserDataStep {
+		return runCloudConfig(ctx, session, step.ScriptContent, p)
+	}
+	return session.ExecuteScript(ctx, step.ScriptContent, ssh.ScriptOptions{
+		Env:         step.Env,
+		Interpreter: p.interpreter(step.ScriptContent, step.Interpreter),
+		WorkingDir:  step.WorkingDir,
+		RunAs:       step.RunAs,
+		Escalation:  p.escalation,
+		PathPrefix:  p.stepScriptPath(index),
+		OutputVar:   scriptOutputVar,
+		MarkerVar:   rebootMarkerVar,
+	})
+}
+
+// retryDelay returns how long to wait before retrying a failed step, and
+// false if the step's retry policy does not allow another attempt.
+func retryDelay(policy *v1alpha1.VMRetryPolicy, st v1alpha1.VMProvisioningStepStatus, now time.Time) (time.Duration, bool) {
+	if policy == nil || st.Status != CloudInitStatusFailed {
+		return 0, false
+	}
+
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	if st.Attempts >= maxAttempts {
+		return 0, false
+	}
+
+	if len(policy.RetryableExitCodes) > 0 && (st.ExitCode == nil || !slices.Contains(policy.RetryableExitCodes, *st.ExitCode)) {
+		return 0, false
+	}
+
+	delay, maxDelay := defaultRetryBackoff, defaultMaxRetryBackoff
+	if policy.Backoff != nil {
+		delay = policy.Backoff.Duration
+	}
+	if policy.MaxBackoff != nil {
+		maxDelay = policy.MaxBackoff.Duration
+	}
+	for i := int32(1); i < st.Attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxDelay)
+
+	if policy.Deadline != nil && st.FirstAttemptTime != nil && now.Add(delay).After(st.FirstAttemptTime.Add(policy.Deadline.Duration)) {
+		return 0, false
+	}
+	return delay, true
+}
+
+// setProvisioningResult summarizes the step statuses in the VM's cloud-init
+// status and conditions.
+func setProvisioningResult(cr *v1alpha1.VM, steps []v1alpha1.VMProvisioningStep) {
+	var skipped []string
+	for i, st := range cr.Status.AtProvider.ProvisioningSteps {
+		switch {
+		case st.Status == CloudInitStatusCompleted:
+			continue
+		case st.Status == CloudInitStatusFailed && steps[i].ContinueOnFailure:
+			skipped = append(skipped, strconv.Quote(st.Name))
+		case st.Status == CloudInitStatusFailed:
+			message := fmt.Sprintf("step %q failed: %s", st.Name, st.Message)
+			setCloudInitStatus(cr, CloudInitStatusFailed, message)
+			cond := xpv1.Unavailable()
+			cond.Message = truncateMessage(message)
+			cr.SetConditions(cond)
+			return
+		default:
+			setCloudInitStatus(cr, CloudInitStatusPending, fmt.Sprintf("waiting to run step %q", st.Name))
+			cr.SetConditions(xpv1.Creating())
+			return
+		}
+	}
+
+	message := ""
+	if len(skipped) > 0 {
+		message = fmt.Sprintf("completed despite failed steps %s", strings.Join(skipped, ", "))
+	}
+	setCloudInitStatus(cr, CloudInitStatusCompleted, message)
+	cr.SetConditions(xpv1.Available())
+}
@@ -0,0 +1,454 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	xpevent "github.com/crossplane/crossplane-runtime/v2/pkg/event"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	v1alpha1 "github.com/ravan/provider-orchard/apis/compute/v1alpha1"
+	"github.com/ravan/provider-orchard/internal/ssh"
+)
+
+// rebootPollInterval is how often a rebooting VM is polled until it is back
+var rebootPollInterval = 5 * time.Second
+
+// A provisionJob runs a VM's provisioning steps in the background.
+type provisionJob struct {
+	steps      []v1alpha1.VMProvisioningStep
+	statuses   []v1alpha1.VMProvisioningStepStatus
+	from       int
+	generation int64
+	config     ssh.TunnelConfig
+	cancel     context.CancelFunc
+
+	// preDelete is true for jobs running a VM's pre-delete script
+	preDelete bool
+
+	// vm is a copy of the VM resource, used to own its logs and to record
+	// events
+	vm *v1alpha1.VM
+
+	// Progress, guarded by the provisioner's mutex
+	started   bool
+	current   string
+	rebooting bool
+	done      bool
+	err       error
+	logs      string
+	results   map[string]v1alpha1.VMProvisioningStepStatus
+	outputs   map[string]string
+}
+
+// A jobStatus is a snapshot of a provisioning job's progress.
+type jobStatus struct {
+	// Started is true once the job holds a provisioning slot
+	Started bool
+
+	// Current is the name of the step being run
+	Current string
+
+	// Rebooting is true while the job waits for the VM to come back after
+	// the current step requested a reboot
+	Rebooting bool
+
+	// Done is true once the job finished
+	Done bool
+
+	// Err is set if the job could not run, e.g. because SSH was not ready
+	Err error
+
+	// Logs is the name of the ConfigMap the job wrote step logs to, if any
+	Logs string
+
+	// Results are the statuses of the steps the job ran, by name
+	Results map[string]v1alpha1.VMProvisioningStepStatus
+
+	// Outputs are the outputs the job's steps wrote, except sensitive ones
+	Outputs map[string]string
+
+	// PreDelete is true for jobs running a VM's pre-delete script
+	PreDelete bool
+}
+
+// A provisioner runs provisioning jobs in the background, so reconciles never
+// block on SSH. At most one job runs per VM, and a limited number of jobs run
+// at once. The VM is enqueued whenever a step finishes, so Observe can record
+// the progress in its status. The output of every step is kept in a
+// ConfigMap, and events are recorded when steps start and finish.
+type provisioner struct {
+	kube       client.Client
+	recorder   xpevent.Recorder
+	newSession func(ctx context.Context, config ssh.TunnelConfig) (ssh.VMSession, error)
+	events     chan event.GenericEvent
+	slots      chan struct{}
+
+	mu   sync.Mutex
+	ctx  context.Context
+	jobs map[types.NamespacedName]*provisionJob
+}
+
+// newProvisioner returns a provisioner that runs up to the supplied number of
+// jobs at once. Jobs are only run once it is started by the controller
+// manager.
+func newProvisioner(kube client.Client, recorder xpevent.Recorder, maxJobs int) *provisioner {
+	return &provisioner{
+		kube:       kube,
+		recorder:   recorder,
+		newSession: ssh.NewVMSession,
+		events:     make(chan event.GenericEvent),
+		slots:      make(chan struct{}, max(maxJobs, 1)),
+		jobs:       map[types.NamespacedName]*provisionJob{},
+	}
+}
+
+// Start runs the provisioner until the supplied context is done. Running
+// jobs are cancelled when it is.
+func (p *provisioner) Start(ctx context.Context) error {
+	p.mu.Lock()
+	p.ctx = ctx
+	p.mu.Unlock()
+
+	<-ctx.Done()
+	return nil
+}
+
+// Source returns the source that enqueues VMs whose jobs made progress.
+func (p *provisioner) Source() source.Source {
+	return source.Channel(p.events, &handler.EnqueueRequestForObject{})
+}
+
+// Run starts running the supplied steps of a VM from the supplied index on.
+// It returns false if the provisioner is not running yet.
+func (p *provisioner) Run(cr *v1alpha1.VM, job *provisionJob) bool {
+	if p == nil {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ctx == nil || p.ctx.Err() != nil {
+		return false
+	}
+
+	nn := types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()}
+	if old, ok := p.jobs[nn]; ok {
+		old.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(p.ctx)
+	job.cancel = cancel
+	job.vm = cr.DeepCopy()
+	job.results = map[string]v1alpha1.VMProvisioningStepStatus{}
+	p.jobs[nn] = job
+	go p.run(ctx, nn, job)
+	return true
+}
+
+// Job returns the progress of a VM's provisioning job, if it has one.
+func (p *provisioner) Job(cr *v1alpha1.VM) (jobStatus, bool) {
+	if p == nil {
+		return jobStatus{}, false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	job, ok := p.jobs[types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()}]
+	if !ok {
+		return jobStatus{}, false
+	}
+	results := make(map[string]v1alpha1.VMProvisioningStepStatus, len(job.results))
+	for name, st := range job.results {
+		results[name] = *st.DeepCopy()
+	}
+	outputs := make(map[string]string, len(job.outputs))
+	for k, v := range job.outputs {
+		outputs[k] = v
+	}
+	return jobStatus{Started: job.started, Current: job.current, Rebooting: job.rebooting, Done: job.done, Err: job.err, Logs: job.logs, Results: results, Outputs: outputs, PreDelete: job.preDelete}, true
+}
+
+// Forget cancels a VM's provisioning job, if it has one, and forgets it.
+func (p *provisioner) Forget(cr *v1alpha1.VM) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	nn := types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()}
+	if job, ok := p.jobs[nn]; ok {
+		job.cancel()
+		delete(p.jobs, nn)
+	}
+}
+
+// run waits for a provisioning slot, then runs a job's steps over a single
+// SSH session. The job stops at the first failed step, unless that step may
+// be continued past, or when it is cancelled.
+func (p *provisioner) run(ctx context.Context, nn types.NamespacedName, job *provisionJob) {
+	defer p.finish(ctx, nn, job)
+
+	select {
+	case p.slots <- struct{}{}:
+		defer func() { <-p.slots }()
+	case <-ctx.Done():
+		return
+	}
+	p.update(nn, job, func() { job.started = true })
+
+	if err := sshReady(ctx, p.newSession, job.config); err != nil {
+		p.update(nn, job, func() { job.err = errors.Wrap(err, errSSHNotReady) })
+		return
+	}
+
+	session, err := p.newSession(ctx, job.config)
+	if err != nil {
+		p.update(nn, job, func() { job.err = errors.Wrap(err, errSSHNotReady) })
+		return
+	}
+	// The session is replaced whenever the VM reboots
+	defer func() { _ = session.Close() }()
+
+	for i := job.from; i < len(job.steps); i++ {
+		step, st := job.steps[i], *job.statuses[i].DeepCopy()
+		if stepDone(step, st) {
+			continue
+		}
+
+		// A step that failed starts a new run. Pending and running steps
+		// resume theirs, e.g. after the VM could not be reached.
+		if st.Status == CloudInitStatusFailed {
+			st.Attempts = 0
+			st.FirstAttemptTime = nil
+		}
+
+		cont, reboot := p.runStepWithRetries(ctx, nn, job, session, i, &st)
+		if !cont {
+			return
+		}
+		if reboot && !job.preDelete {
+			rebooted, err := p.reboot(ctx, nn, job, session, step)
+			if err != nil {
+				p.update(nn, job, func() { job.err = errors.Wrap(err, errRebootVM) })
+				return
+			}
+			session = rebooted
+		}
+
+		// Let Observe record the progress
+		enqueueVM(ctx, p.events, nn)
+	}
+}
+
+// runStepWithRetries runs a step until it completes, or until it fails and
+// its retry policy allows no further attempt. It returns false if the job
+// must stop: because it was cancelled, the VM could not be reached, or the
+// step failed and may not be continued past. It also returns true if the
+// step completed and requested a reboot.
+func (p *provisioner) runStepWithRetries(ctx context.Context, nn types.NamespacedName, job *provisionJob, session ssh.VMSession, i int, st *v1alpha1.VMProvisioningStepStatus) (bool, bool) {
+	step := job.steps[i]
+	for {
+		p.update(nn, job, func() {
+			job.current = step.Name
+			running := *st
+			running.Status = CloudInitStatusRunning
+			job.results[step.Name] = running
+		})
+		p.recorder.Event(job.vm, xpevent.Normal(reasonProvisioningStepStarted, fmt.Sprintf("Running provisioning step %q", step.Name)))
+
+		run, err := runStep(ctx, session, i, step, st, job.generation, guestProfileFor(job.vm.Spec.ForProvider.GuestOS))
+		if ctx.Err() != nil {
+			p.update(nn, job, func() { job.results[step.Name] = *st })
+			return false, false
+		}
+		if err != nil {
+			// The step resumes once Observe finds the VM reachable again
+			p.update(nn, job, func() {
+				job.results[step.Name] = *st
+				job.err = errors.Wrap(err, errSSHNotReady)
+			})
+			return false, false
+		}
+
+		p.recordOutputs(ctx, nn, job, run.outputs)
+
+		delay, retry := retryDelay(step.RetryPolicy, *st, time.Now())
+		if retry {
+			st.Status = CloudInitStatusPending
+			st.Message = truncateMessage(fmt.Sprintf("attempt %d failed, retrying in %s: %s", st.Attempts, delay, st.Message))
+		}
+		p.finishStep(ctx, nn, job, *st, run.log)
+		if !retry {
+			return st.Status != CloudInitStatusFailed || step.ContinueOnFailure, run.reboot
+		}
+
+		enqueueVM(ctx, p.events, nn)
+		select {
+		case <-ctx.Done():
+			return false, false
+		case <-time.After(delay):
+		}
+	}
+}
+
+// finishStep records a step's outcome and log, and an event reporting it.
+// Steps whose log cannot be written still count as finished.
+func (p *provisioner) finishStep(ctx context.Context, nn types.NamespacedName, job *provisionJob, st v1alpha1.VMProvisioningStepStatus, log string) {
+	logs := ""
+	if err := writeStepLog(ctx, p.kube, job.vm, st.Name, log); err != nil {
+		p.recorder.Event(job.vm, xpevent.Warning(reasonProvisioningLogs, err))
+	} else {
+		logs = provisioningLogsName(job.vm)
+	}
+	p.update(nn, job, func() {
+		job.results[st.Name] = st
+		if logs != "" {
+			job.logs = logs
+		}
+	})
+
+	switch st.Status {
+	case CloudInitStatusFailed:
+		p.recorder.Event(job.vm, xpevent.Warning(reasonProvisioningStepFailed, errors.Errorf("Provisioning step %q failed: %s", st.Name, st.Message)))
+		return
+	case CloudInitStatusPending:
+		p.recorder.Event(job.vm, xpevent.Warning(reasonProvisioningStepRetry, errors.Errorf("Provisioning step %q %s", st.Name, st.Message)))
+		return
+	}
+	p.recorder.Event(job.vm, xpevent.Normal(reasonProvisioningStepCompleted, fmt.Sprintf("Provisioning step %q completed in %s", st.Name, st.Duration.Duration)))
+}
+
+// reboot reboots a VM after a step requested it, and returns a session to
+// the VM once it is back. The VM counts as back once SSH is ready and its
+// boot ID changed, so a VM that is still shutting down is not mistaken for
+// one that rebooted.
+func (p *provisioner) reboot(ctx context.Context, nn types.NamespacedName, job *provisionJob, session ssh.VMSession, step v1alpha1.VMProvisioningStep) (ssh.VMSession, error) {
+	profile := guestProfileFor(job.vm.Spec.ForProvider.GuestOS)
+	bootID, err := readBootID(ctx, session, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	p.update(nn, job, func() { job.rebooting = true })
+	defer p.update(nn, job, func() { job.rebooting = false })
+	p.recorder.Event(job.vm, xpevent.Normal(reasonProvisioningReboot, fmt.Sprintf("Rebooting the VM as requested by provisioning step %q", step.Name)))
+	enqueueVM(ctx, p.events, nn)
+
+	// The connection usually drops before the reboot command returns
+	rebootCtx, cancel := context.WithTimeout(ctx, rebootCommandTimeout)
+	_, _ = session.ExecuteCommandAs(rebootCtx, profile.rebootCommand(), ssh.SudoOptions{Escalation: profile.escalation})
+	cancel()
+	_ = session.Close()
+
+	timeout := defaultRebootTimeout
+	if t := job.vm.Spec.ForProvider.RebootTimeout; t != nil {
+		timeout = t.Duration
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return waitForReboot(waitCtx, p.newSession, job.config, profile, bootID)
+}
+
+// waitForReboot polls a VM until SSH is ready and its boot ID differs from
+// the supplied one, then returns a session to it.
+func waitForReboot(ctx context.Context, newSession func(context.Context, ssh.TunnelConfig) (ssh.VMSession, error), config ssh.TunnelConfig, p guestProfile, bootID string) (ssh.VMSession, error) {
+	for {
+		if sshReady(ctx, newSession, config) == nil {
+			if session, err := newSession(ctx, config); err == nil {
+				if id, err := readBootID(ctx, session, p); err == nil && id != bootID {
+					return session, nil
+				}
+				_ = session.Close()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrap(ssh.ErrTimeout, "VM did not come back after rebooting")
+		case <-time.After(rebootPollInterval):
+		}
+	}
+}
+
+// readBootID returns a value that changes every time the VM boots.
+func readBootID(ctx context.Context, session ssh.VMSession, p guestProfile) (string, error) {
+	result, err := session.ExecuteCommand(ctx, p.bootIDCommand)
+	if err != nil {
+		return "", err
+	}
+	if result.ExitCode != 0 {
+		return "", errors.Errorf("cannot read boot ID: exit code %d: %s", result.ExitCode, logTail(result))
+	}
+	return strings.TrimSpace(result.Stdout), nil
+}
+
+// recordOutputs records the outputs a step wrote. Sensitive outputs are
+// stored in a Secret, to be published to the VM's connection secret.
+func (p *provisioner) recordOutputs(ctx context.Context, nn types.NamespacedName, job *provisionJob, outputs map[string]string) {
+	plain, secret := splitOutputs(outputs, job.vm.Spec.ForProvider.SensitiveOutputs)
+	if len(secret) > 0 {
+		if err := writeSensitiveOutputs(ctx, p.kube, job.vm, secret); err != nil {
+			p.recorder.Event(job.vm, xpevent.Warning(reasonScriptOutputs, err))
+		}
+	}
+	if len(plain) == 0 {
+		return
+	}
+	p.update(nn, job, func() {
+		if job.outputs == nil {
+			job.outputs = map[string]string{}
+		}
+		for k, v := range plain {
+			job.outputs[k] = v
+		}
+	})
+}
+
+// update changes a job's progress, unless the job was replaced.
+func (p *provisioner) update(nn types.NamespacedName, job *provisionJob, fn func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.jobs[nn] == job {
+		fn()
+	}
+}
+
+// finish marks a job done and enqueues its VM, so Observe reports the
+// outcome without waiting for the next poll.
+func (p *provisioner) finish(ctx context.Context, nn types.NamespacedName, job *provisionJob) {
+	p.update(nn, job, func() {
+		job.done = true
+		job.current = ""
+	})
+	enqueueVM(ctx, p.events, nn)
+}
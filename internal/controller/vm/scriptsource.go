@@ -0,0 +1,212 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vm
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1alpha1 "github.com/ravan/provider-orchard/apis/compute/v1alpha1"
+)
+
+const (
+	errGetScriptConfigMap = "cannot get startup script ConfigMap"
+	errGetScriptSecret    = "cannot get startup script Secret"
+	errScriptKeyNotFound  = "key %q not found in %s %s/%s"
+)
+
+// envVarName matches valid environment variable names
+var envVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// resolveStartupScript returns a VM's startup script with its script and env
+// read from the ConfigMaps and Secrets it references. They are read on every
+// reconcile, so a change to a referenced object changes the startup script's
+// content hash and re-provisions the VM like a change to its spec would.
+func resolveStartupScript(ctx context.Context, kube client.Client, cr *v1alpha1.VM) (*v1alpha1.VMStartupScript, error) {
+	s := cr.Spec.ForProvider.StartupScript
+	if s == nil {
+		return nil, nil
+	}
+	ns := cr.GetNamespace()
+	resolved := &v1alpha1.VMStartupScript{ScriptContent: s.ScriptContent, VMScriptOptions: s.VMScriptOptions}
+
+	if s.ScriptFrom != nil {
+		v, _, err := sourceValue(ctx, kube, ns, *s.ScriptFrom)
+		if err != nil {
+			return nil, err
+		}
+		resolved.ScriptContent = v
+	}
+
+	env := map[string]string{}
+	for _, src := range s.EnvFrom {
+		data, err := sourceData(ctx, kube, ns, src)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range data {
+			if envVarName.MatchString(src.Prefix + k) {
+				env[src.Prefix+k] = v
+			}
+		}
+	}
+	for k, v := range s.Env {
+		env[k] = v
+	}
+	for _, ev := range s.EnvVars {
+		v, ok, err := sourceValue(ctx, kube, ns, ev.ValueFrom)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			env[ev.Name] = v
+		}
+	}
+	if len(env) > 0 {
+		resolved.Env = env
+	}
+	return resolved, nil
+}
+
+// sourceValue returns the value of the ConfigMap or Secret key selected by a
+// value source. It returns false if an optional key does not exist.
+func sourceValue(ctx context.Context, kube client.Client, namespace string, src v1alpha1.VMValueSource) (string, bool, error) {
+	switch {
+	case src.ConfigMapKeyRef != nil:
+		ref := src.ConfigMapKeyRef
+		cm := &corev1.ConfigMap{}
+		if err := kube.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, cm); err != nil {
+			if kerrors.IsNotFound(err) && ptrValue(ref.Optional) {
+				return "", false, nil
+			}
+			return "", false, errors.Wrap(err, errGetScriptConfigMap)
+		}
+		if v, ok := cm.Data[ref.Key]; ok {
+			return v, true, nil
+		}
+		if v, ok := cm.BinaryData[ref.Key]; ok {
+			return string(v), true, nil
+		}
+		if ptrValue(ref.Optional) {
+			return "", false, nil
+		}
+		return "", false, errors.Errorf(errScriptKeyNotFound, ref.Key, "ConfigMap", namespace, ref.Name)
+	case src.SecretKeyRef != nil:
+		ref := src.SecretKeyRef
+		s := &corev1.Secret{}
+		if err := kube.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, s); err != nil {
+			if kerrors.IsNotFound(err) && ptrValue(ref.Optional) {
+				return "", false, nil
+			}
+			return "", false, errors.Wrap(err, errGetScriptSecret)
+		}
+		if v, ok := s.Data[ref.Key]; ok {
+			return string(v), true, nil
+		}
+		if ptrValue(ref.Optional) {
+			return "", false, nil
+		}
+		return "", false, errors.Errorf(errScriptKeyNotFound, ref.Key, "Secret", namespace, ref.Name)
+	}
+	return "", false, nil
+}
+
+// sourceData returns every key of the ConfigMap or Secret an env source
+// references. Optional objects that do not exist have no keys.
+func sourceData(ctx context.Context, kube client.Client, namespace string, src corev1.EnvFromSource) (map[string]string, error) {
+	data := map[string]string{}
+	switch {
+	case src.ConfigMapRef != nil:
+		cm := &corev1.ConfigMap{}
+		if err := kube.Get(ctx, types.NamespacedName{Namespace: namespace, Name: src.ConfigMapRef.Name}, cm); err != nil {
+			if kerrors.IsNotFound(err) && ptrValue(src.ConfigMapRef.Optional) {
+				return data, nil
+			}
+			return nil, errors.Wrap(err, errGetScriptConfigMap)
+		}
+		for k, v := range cm.BinaryData {
+			data[k] = string(v)
+		}
+		for k, v := range cm.Data {
+			data[k] = v
+		}
+	case src.SecretRef != nil:
+		s := &corev1.Secret{}
+		if err := kube.Get(ctx, types.NamespacedName{Namespace: namespace, Name: src.SecretRef.Name}, s); err != nil {
+			if kerrors.IsNotFound(err) && ptrValue(src.SecretRef.Optional) {
+				return data, nil
+			}
+			return nil, errors.Wrap(err, errGetScriptSecret)
+		}
+		for k, v := range s.Data {
+			data[k] = string(v)
+		}
+	}
+	return data, nil
+}
+
+// scriptSecretNames returns the names of the Secrets a startup script
+// references.
+func scriptSecretNames(s *v1alpha1.VMStartupScript) []string {
+	if s == nil {
+		return nil
+	}
+	var names []string
+	if s.ScriptFrom != nil && s.ScriptFrom.SecretKeyRef != nil {
+		names = append(names, s.ScriptFrom.SecretKeyRef.Name)
+	}
+	for _, src := range s.EnvFrom {
+		if src.SecretRef != nil {
+			names = append(names, src.SecretRef.Name)
+		}
+	}
+	for _, ev := range s.EnvVars {
+		if ev.ValueFrom.SecretKeyRef != nil {
+			names = append(names, ev.ValueFrom.SecretKeyRef.Name)
+		}
+	}
+	return names
+}
+
+// scriptConfigMapNames returns the names of the ConfigMaps a startup script
+// references.
+func scriptConfigMapNames(s *v1alpha1.VMStartupScript) []string {
+	if s == nil {
+		return nil
+	}
+	var names []string
+	if s.ScriptFrom != nil && s.ScriptFrom.ConfigMapKeyRef != nil {
+		names = append(names, s.ScriptFrom.ConfigMapKeyRef.Name)
+	}
+	for _, src := range s.EnvFrom {
+		if src.ConfigMapRef != nil {
+			names = append(names, src.ConfigMapRef.Name)
+		}
+	}
+	for _, ev := range s.EnvVars {
+		if ev.ValueFrom.ConfigMapKeyRef != nil {
+			names = append(names, ev.ValueFrom.ConfigMapKeyRef.Name)
+		}
+	}
+	return names
+}
@@ -17,19 +17,34 @@ limitations under the License.
 package vm
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/crossplane/crossplane-runtime/v2/pkg/feature"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
 
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/rand"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlevent "sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/controller"
@@ -48,18 +63,21 @@ import (
 const (
 	errNotVM        = "managed resource is not a VM custom resource"
 	errTrackPCUsage = "cannot track ProviderConfig usage"
-	errGetPC        = "cannot get ProviderConfig"
-	errGetCPC       = "cannot get ClusterProviderConfig"
-	errGetCreds     = "cannot get credentials"
-
-	errNewClient       = "cannot create new Orchard client"
-	errGetVM           = "cannot get VM"
-	errCreateVM        = "cannot create VM"
-	errUpdateVM        = "cannot update VM"
-	errDeleteVM        = "cannot delete VM"
-	errParseToken      = "cannot parse token from credentials"
+
+	errNewClient        = "cannot create new Orchard client"
+	errGetVM            = "cannot get VM"
+	errCreateVM         = "cannot create VM"
+	errUpdateVM         = "cannot update VM"
+	errDeleteVM         = "cannot delete VM"
 	errExecuteCloudInit = "cannot execute cloud-init script"
-	errSSHNotReady     = "SSH not ready"
+	errGetSSHSecret     = "cannot get SSH credentials secret"
+	errIndexSecretRefs  = "cannot index VMs by referenced secret"
+	errIndexConfigMaps  = "cannot index VMs by referenced ConfigMap"
+	errVMConflict       = "VM %q already exists in Orchard (%s); set the %s annotation to \"true\" to adopt it"
+	errVMStillDeleting  = "waiting for Orchard to delete VM %q before creating it again"
+	errChangeRejected   = "changes to %s cannot be applied to a running VM and updateStrategy is Reject"
+	errSSHNotReady      = "SSH not ready"
+	errRebootVM         = "cannot reboot VM"
 
 	// Cloud-init status values
 	CloudInitStatusPending   = "pending"
@@ -73,6 +91,44 @@ const (
 
 	// Maximum length for condition messages (for readability)
 	maxConditionMessageLen = 1024
+
+	// Orchard VM names must be valid DNS labels
+	maxVMNameLength = 63
+
+	// Length of the hash suffix of shortened VM names and of the random
+	// suffix of generated ones
+	vmNameHashLength     = 8
+	vmNameGenerateLength = 5
+
+	reasonAdoptVM    event.Reason = "AdoptVM"
+	reasonRecreateVM event.Reason = "RecreateVM"
+
+	reasonProvisioningStepStarted   event.Reason = "ProvisioningStepStarted"
+	reasonProvisioningStepCompleted event.Reason = "ProvisioningStepCompleted"
+	reasonProvisioningStepFailed    event.Reason = "ProvisioningStepFailed"
+	reasonProvisioningStepRetry     event.Reason = "ProvisioningStepRetry"
+	reasonProvisioningReboot        event.Reason = "ProvisioningReboot"
+	reasonProvisioningLogs          event.Reason = "CannotWriteProvisioningLogs"
+	reasonScriptOutputs             event.Reason = "CannotWriteScriptOutputs"
+
+	reasonPreDeleteScriptCompleted event.Reason = "PreDeleteScriptCompleted"
+	reasonPreDeleteScriptFailed    event.Reason = "PreDeleteScriptFailed"
+	reasonPreDeleteScriptSkipped   event.Reason = "PreDeleteScriptSkipped"
+
+	// secretRefIndex indexes VMs by the names of the Secrets they reference
+	secretRefIndex = "spec.forProvider.secretRefs"
+
+	// configMapRefIndex indexes VMs by the names of the ConfigMaps they
+	// reference
+	configMapRefIndex = "spec.forProvider.configMapRefs"
+
+	// ConnectionDetailWorker is the connection secret key holding the name of
+	// the worker running the VM.
+	ConnectionDetailWorker = "worker"
+
+	// ConnectionDetailPortForwardURL is the connection secret key holding the
+	// WebSocket URL that forwards to the VM's SSH port through Orchard.
+	ConnectionDetailPortForwardURL = "portForwardURL"
 )
 
 // SetupGated adds a controller that reconciles VM managed resources with safe-start support.
@@ -87,15 +143,44 @@ func SetupGated(mgr ctrl.Manager, o controller.Options) error {
 
 func Setup(mgr ctrl.Manager, o controller.Options) error {
 	name := managed.ControllerName(v1alpha1.VMGroupKind)
+	recorder := event.NewAPIRecorder(mgr.GetEventRecorderFor(name))
+
+	// Stream VM changes from Orchard so they are observed between polls
+	watches := newWatchManager(o.Logger.WithValues("controller", name))
+	if err := mgr.Add(watches); err != nil {
+		return errors.Wrap(err, "cannot register VM watch manager")
+	}
+
+	// Run provisioning steps in the background, so they never hold a
+	// reconcile worker. At most as many jobs as reconcile workers run at once.
+	provisioner := newProvisioner(mgr.GetClient(), recorder, o.MaxConcurrentReconciles)
+	if err := mgr.Add(provisioner); err != nil {
+		return errors.Wrap(err, "cannot register VM provisioner")
+	}
+
+	// Probe provisioned VMs in the background
+	prober := newProber()
+	if err := mgr.Add(prober); err != nil {
+		return errors.Wrap(err, "cannot register VM prober")
+	}
 
 	opts := []managed.ReconcilerOption{
 		managed.WithExternalConnector(&connector{
-			kube:  mgr.GetClient(),
-			usage: resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
+			kube:            mgr.GetClient(),
+			usage:           resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
+			recorder:        recorder,
+			policiesEnabled: o.Features.Enabled(feature.EnableBetaManagementPolicies),
+			watches:         watches,
+			provisioner:     provisioner,
+			prober:          prober,
 		}),
+		// The external name is only set by Create or by a user adopting an
+		// existing VM, so it must not default to the resource's name.
+		managed.WithInitializers(),
 		managed.WithLogger(o.Logger.WithValues("controller", name)),
 		managed.WithPollInterval(o.PollInterval),
-		managed.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		managed.WithPollIntervalHook(watches.PollInterval),
+		managed.WithRecorder(recorder),
 	}
 
 	if o.Features.Enabled(feature.EnableBetaManagementPolicies) {
@@ -121,19 +206,106 @@ func Setup(mgr ctrl.Manager, o controller.Options) error {
 
 	r := managed.NewReconciler(mgr, resource.ManagedKind(v1alpha1.VMGroupVersionKind), opts...)
 
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &v1alpha1.VM{}, secretRefIndex, indexSecretRefs); err != nil {
+		return errors.Wrap(err, errIndexSecretRefs)
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &v1alpha1.VM{}, configMapRefIndex, indexConfigMapRefs); err != nil {
+		return errors.Wrap(err, errIndexConfigMaps)
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(name).
 		WithOptions(o.ForControllerRuntime()).
-		WithEventFilter(resource.DesiredStateChanged()).
-		For(&v1alpha1.VM{}).
+		For(&v1alpha1.VM{}, builder.WithPredicates(resource.DesiredStateChanged())).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(vmsReferencing(mgr.GetClient(), secretRefIndex)),
+			builder.WithPredicates(referencedDataChanged(mgr.GetClient(), secretRefIndex))).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(vmsReferencing(mgr.GetClient(), configMapRefIndex)),
+			builder.WithPredicates(referencedDataChanged(mgr.GetClient(), configMapRefIndex))).
+		Watches(&v1alpha1.VM{}, stopOnDelete(watches, prober, provisioner)).
+		WatchesRawSource(watches.Source()).
+		WatchesRawSource(provisioner.Source()).
+		WatchesRawSource(prober.Source()).
 		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
 }
 
+// indexSecretRefs returns the names of the Secrets a VM references.
+func indexSecretRefs(o client.Object) []string {
+	cr, ok := o.(*v1alpha1.VM)
+	if !ok {
+		return nil
+	}
+	var names []string
+	for _, ref := range []*xpv1.LocalSecretKeySelector{cr.Spec.ForProvider.UsernameSecretRef, cr.Spec.ForProvider.PasswordSecretRef} {
+		if ref != nil {
+			names = append(names, ref.Name)
+		}
+	}
+	return append(names, scriptSecretNames(cr.Spec.ForProvider.StartupScript)...)
+}
+
+// indexConfigMapRefs returns the names of the ConfigMaps a VM references.
+func indexConfigMapRefs(o client.Object) []string {
+	cr, ok := o.(*v1alpha1.VM)
+	if !ok {
+		return nil
+	}
+	return scriptConfigMapNames(cr.Spec.ForProvider.StartupScript)
+}
+
+// vmsReferencing enqueues the VMs that reference a Secret or ConfigMap by the
+// supplied index, so rotated credentials and changed startup scripts are
+// picked up without waiting for the next poll.
+func vmsReferencing(kube client.Client, index string) handler.MapFunc {
+	return func(ctx context.Context, o client.Object) []reconcile.Request {
+		vms := &v1alpha1.VMList{}
+		if err := kube.List(ctx, vms, client.InNamespace(o.GetNamespace()), client.MatchingFields{index: o.GetName()}); err != nil {
+			return nil
+		}
+		reqs := make([]reconcile.Request, 0, len(vms.Items))
+		for _, vm := range vms.Items {
+			reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: vm.GetNamespace(), Name: vm.GetName()}})
+		}
+		return reqs
+	}
+}
+
+// referencedDataChanged filters the events of Secrets and ConfigMaps down to
+// those a VM references by the supplied index. Updates only pass if they
+// change the object's data, not e.g. its labels or annotations.
+func referencedDataChanged(kube client.Reader, index string) predicate.Predicate {
+	referenced := predicate.NewPredicateFuncs(func(o client.Object) bool {
+		vms := &v1alpha1.VMList{}
+		if err := kube.List(context.Background(), vms, client.InNamespace(o.GetNamespace()), client.MatchingFields{index: o.GetName()}); err != nil {
+			return false
+		}
+		return len(vms.Items) > 0
+	})
+	dataChanged := predicate.Funcs{
+		UpdateFunc: func(e ctrlevent.UpdateEvent) bool {
+			switch o := e.ObjectOld.(type) {
+			case *corev1.Secret:
+				n, ok := e.ObjectNew.(*corev1.Secret)
+				return !ok || !maps.EqualFunc(o.Data, n.Data, bytes.Equal) || !maps.Equal(o.StringData, n.StringData)
+			case *corev1.ConfigMap:
+				n, ok := e.ObjectNew.(*corev1.ConfigMap)
+				return !ok || !maps.Equal(o.Data, n.Data) || !maps.EqualFunc(o.BinaryData, n.BinaryData, bytes.Equal)
+			}
+			return true
+		},
+	}
+	return predicate.And(dataChanged, referenced)
+}
+
 // A connector is expected to produce an ExternalClient when its Connect method
 // is called.
 type connector struct {
-	kube  client.Client
-	usage *resource.ProviderConfigUsageTracker
+	kube            client.Client
+	usage           *resource.ProviderConfigUsageTracker
+	recorder        event.Recorder
+	policiesEnabled bool
+	watches         *watchManager
+	provisioner     *provisioner
+	prober          *prober
 }
 
 // Connect typically produces an ExternalClient by:
@@ -151,84 +323,140 @@ func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.E
 		return nil, errors.Wrap(err, errTrackPCUsage)
 	}
 
-	// Get provider config and base URL
-	m := mg.(resource.ModernManaged)
-	cd, baseURL, err := c.getProviderConfigAndBaseURL(ctx, m)
+	cfg, err := orchardclient.GetConfig(ctx, c.kube, cr)
 	if err != nil {
 		return nil, err
 	}
 
-	// Extract credentials
-	data, err := resource.CommonCredentialExtractor(ctx, cd.Source, c.kube, cd.CommonCredentialSelectors)
+	// Create Orchard client
+	orchardClient, err := orchardclient.NewOrchardClient(cfg)
 	if err != nil {
-		return nil, errors.Wrap(err, errGetCreds)
+		return nil, errors.Wrap(err, errNewClient)
 	}
 
-	// Extract token from credentials
-	token, err := extractToken(data)
-	if err != nil {
-		return nil, err
+	// Credentials that cannot be read fail Observe rather than Connect, so
+	// a VM being deleted still reaches Delete.
+	creds, credsErr := resolveSSHCredentials(ctx, c.kube, cr)
+
+	// The startup script is only needed to provision a VM, which VMs being
+	// deleted are not. The objects it references may already be gone.
+	var startupScript *v1alpha1.VMStartupScript
+	if !meta.WasDeleted(cr) {
+		if startupScript, err = resolveStartupScript(ctx, c.kube, cr); err != nil {
+			return nil, err
+		}
 	}
 
-	// Create Orchard client
-	orchardClient, err := orchardclient.NewOrchardClient(orchardclient.OrchardConfig{
-		BaseURL: baseURL,
-		Token:   token,
-	})
+	outputs, err := readSensitiveOutputs(ctx, c.kube, cr)
 	if err != nil {
-		return nil, errors.Wrap(err, errNewClient)
+		return nil, err
 	}
 
 	return &external{
-		client:  orchardClient,
-		baseURL: baseURL,
-		token:   token,
+		client:       orchardClient,
+		baseURL:      orchardClient.GetBaseURL(),
+		token:        cfg.Token,
+		nameStrategy: cfg.VMNameStrategy,
+		creds:        creds,
+		credsErr:     credsErr,
+		startup:      startupScript,
+		outputs:      outputs,
+		recorder:     c.recorder,
+		policies:     managed.NewManagementPoliciesResolver(c.policiesEnabled, cr.GetManagementPolicies()),
+		config:       cfg,
+		watches:      c.watches,
+		provisioner:  c.provisioner,
+		prober:       c.prober,
 	}, nil
 }
 
-// getProviderConfigAndBaseURL retrieves provider credentials and base URL from ProviderConfig or ClusterProviderConfig
-func (c *connector) getProviderConfigAndBaseURL(ctx context.Context, m resource.ModernManaged) (apisv1alpha1.ProviderCredentials, string, error) {
-	ref := m.GetProviderConfigReference()
+// sshCredentials are the SSH username and password of a VM, taken from its
+// spec or from the Secrets it references. Nil fields are left to defaults.
+type sshCredentials struct {
+	Username *string
+	Password *string
+}
 
-	switch ref.Kind {
-	case "ProviderConfig":
-		pc := &apisv1alpha1.ProviderConfig{}
-		if err := c.kube.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: m.GetNamespace()}, pc); err != nil {
-			return apisv1alpha1.ProviderCredentials{}, "", errors.Wrap(err, errGetPC)
+// resolveSSHCredentials reads the SSH credentials of a VM. Secret references
+// take precedence over plain values and are read on every reconcile, so
+// rotated Secrets take effect immediately. Secrets of a VM being deleted may
+// already be gone, e.g. when its namespace is deleted; the guest profile's
+// defaults are used instead.
+func resolveSSHCredentials(ctx context.Context, kube client.Client, cr *v1alpha1.VM) (sshCredentials, error) {
+	params := &cr.Spec.ForProvider
+	creds := sshCredentials{Username: params.Username, Password: params.Password}
+
+	resolve := func(ref *xpv1.LocalSecretKeySelector, v **string) error {
+		if ref == nil {
+			return nil
 		}
-		return pc.Spec.Credentials, pc.Spec.BaseURL, nil
-	case "ClusterProviderConfig":
-		cpc := &apisv1alpha1.ClusterProviderConfig{}
-		if err := c.kube.Get(ctx, types.NamespacedName{Name: ref.Name}, cpc); err != nil {
-			return apisv1alpha1.ProviderCredentials{}, "", errors.Wrap(err, errGetCPC)
+		s, err := secretValue(ctx, kube, cr.GetNamespace(), ref)
+		switch {
+		case kerrors.IsNotFound(err) && meta.WasDeleted(cr):
+			*v = nil
+		case err != nil:
+			return err
+		default:
+			*v = &s
 		}
-		return cpc.Spec.Credentials, cpc.Spec.BaseURL, nil
-	default:
-		return apisv1alpha1.ProviderCredentials{}, "", errors.Errorf("unsupported provider config kind: %s", ref.Kind)
+		return nil
+	}
+	if err := resolve(params.UsernameSecretRef, &creds.Username); err != nil {
+		return sshCredentials{}, err
+	}
+	if err := resolve(params.PasswordSecretRef, &creds.Password); err != nil {
+		return sshCredentials{}, err
 	}
+	return creds, nil
 }
 
-// extractToken parses and extracts the token from credential data
-func extractToken(data []byte) (string, error) {
-	var creds map[string]string
-	if err := json.Unmarshal(data, &creds); err != nil {
-		return "", errors.Wrap(err, errParseToken)
+// secretValue returns the value of a key of a Secret in the supplied namespace.
+func secretValue(ctx context.Context, kube client.Client, namespace string, ref *xpv1.LocalSecretKeySelector) (string, error) {
+	s := &corev1.Secret{}
+	if err := kube.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, s); err != nil {
+		return "", errors.Wrap(err, errGetSSHSecret)
 	}
-
-	token, ok := creds["token"]
+	v, ok := s.Data[ref.Key]
 	if !ok {
-		return "", errors.New(errParseToken)
+		return "", errors.Errorf("%s: key %q not found in secret %s/%s", errGetSSHSecret, ref.Key, namespace, ref.Name)
 	}
-
-	return token, nil
+	return string(v), nil
 }
 
 // An ExternalClient observes, then either creates, updates, or deletes an
 // external resource to ensure it reflects the managed resource's desired state.
 type external struct {
-	client  *orchardclient.OrchardClient
-	baseURL string // Orchard base URL for SSH tunnel
-	token   string // Bearer token for SSH tunnel
+	client       *orchardclient.OrchardClient
+	baseURL      string // Orchard base URL for SSH tunnel
+	token        string // Bearer token for SSH tunnel
+	nameStrategy string // ProviderConfig's strategy for naming new VMs
+	creds        sshCredentials
+	credsErr     error                     // Why creds could not be read, if they could not
+	startup      *v1alpha1.VMStartupScript // Startup script, read from any referenced objects
+	outputs      map[string][]byte         // Sensitive script outputs, read from their Secret
+	recorder     event.Recorder
+	policies     managed.ManagementPoliciesChecker
+
+	// diff is the difference between spec and Orchard found by Observe
+	diff []fieldDiff
+
+	// reprovision lists the changed provisioning steps that require the VM
+	// to be recreated, as found by Observe
+	reprovision []string
+
+	// unhealthy is why the VM must be recreated after its liveness probe
+	// failed, as found by Observe
+	unhealthy string
+
+	// config is used to watch the VM for changes; watches may be nil
+	config  orchardclient.OrchardConfig
+	watches *watchManager
+
+	// provisioner runs provisioning steps in the background
+	provisioner *provisioner
+
+	// prober runs the VM's probes in the background
+	prober *prober
 }
 
 func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
@@ -237,10 +465,18 @@ func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.Ex
 		return managed.ExternalObservation{}, errors.New(errNotVM)
 	}
 
+	// Without its credentials a VM can be neither created, updated nor
+	// provisioned. VMs being deleted fall back to the guest profile's
+	// defaults, so they are deleted anyway.
+	if c.credsErr != nil && !meta.WasDeleted(cr) {
+		cr.SetConditions(v1alpha1.CredentialsUnavailable(c.credsErr.Error()))
+		return managed.ExternalObservation{}, c.credsErr
+	}
+
 	// Use the VM name as the external name (unique identifier in Orchard)
 	vmName := meta.GetExternalName(cr)
 	if vmName == "" {
-		// If no external name is set, the VM hasn't been created yet
+		// If no external name is set, the VM hasn't been created or adopted yet
 		return managed.ExternalObservation{ResourceExists: false}, nil
 	}
 
@@ -258,6 +494,8 @@ func (c *external) handleObserveResponse(ctx context.Context, resp *http.Respons
 	switch resp.StatusCode {
 	case http.StatusNotFound:
 		// VM doesn't exist
+		c.watches.Stop(cr)
+		c.prober.Stop(cr)
 		return managed.ExternalObservation{ResourceExists: false}, nil
 	case http.StatusOK:
 		// VM exists, parse response
@@ -267,11 +505,38 @@ func (c *external) handleObserveResponse(ctx context.Context, resp *http.Respons
 		}
 		defer resp.Body.Close()
 
+		// Stream further changes instead of waiting for the next poll. VMs
+		// being deleted need neither streams nor probes. Those deleted without
+		// reaching Observe, such as orphaned VMs, are handled by stopOnDelete.
+		if meta.WasDeleted(cr) {
+			c.watches.Stop(cr)
+			c.prober.Stop(cr)
+		} else {
+			c.watches.Watch(cr, c.config, vmName)
+		}
+
+		// Orchard is still deleting the old VM of a VM being recreated. Its
+		// watch stream reports once it is gone.
+		if recreatePending(cr) && !meta.WasDeleted(cr) {
+			return managed.ExternalObservation{}, errors.Errorf(errVMStillDeleting, vmName)
+		}
+
+		// A VM we did not create and have not observed before was adopted
+		// through a pre-set external name.
+		adopted := cr.Status.AtProvider.Status == "" && meta.GetExternalCreateSucceeded(cr).IsZero()
+
 		// Update observation fields
 		updateVMStatus(cr, &vm)
 
-		// Fetch IP address if VM is running
-		if stringValue(vm.Status) == "running" {
+		// Record the defaults Orchard applied to fields the spec leaves unset
+		lateInitialized := false
+		if c.policies == nil || c.policies.ShouldLateInitialize() {
+			lateInitialized = lateInitialize(&cr.Spec.ForProvider, &vm)
+		}
+
+		// Fetch IP address if VM is running. VMs being deleted are neither
+		// provisioned nor probed.
+		if stringValue(vm.Status) == vmStatusRunning && !meta.WasDeleted(cr) {
 			if ipResp, err := c.client.GetVmsNameIp(ctx, vmName, nil); err == nil && ipResp.StatusCode == http.StatusOK {
 				var ip orchardclient.IP
 				if err := json.NewDecoder(ipResp.Body).Decode(&ip); err == nil {
@@ -284,7 +549,7 @@ func (c *external) handleObserveResponse(ctx context.Context, resp *http.Respons
 
 			// Handle cloud-init execution if VM is running with IP
 			if cr.Status.AtProvider.IPAddress != "" {
-				if err := c.handleCloudInit(ctx, cr); err != nil {
+				if err := c.handleCloudInit(cr); err != nil {
 					// Transient error (SSH not ready) - will retry on next reconcile
 					return managed.ExternalObservation{
 						ResourceExists:   true,
@@ -296,13 +561,30 @@ func (c *external) handleObserveResponse(ctx context.Context, resp *http.Respons
 				cr.SetConditions(xpv1.Creating())
 			}
 		}
+		if !meta.WasDeleted(cr) {
+			c.handleProbes(cr)
+		}
 
 		// Check if resource is up to date
-		upToDate := isVMUpToDate(&cr.Spec.ForProvider, &vm)
+		diff := append(vmDiff(&cr.Spec.ForProvider, &vm), credentialsDiff(c.creds, &vm)...)
+		upToDate := len(diff) == 0 && len(c.reprovision) == 0 && c.unhealthy == ""
+		c.diff = diff
+
+		// The spec is applied once it matches and the worker caught up with it
+		if upToDate && ptrValue(vm.Generation) == ptrValue(vm.ObservedGeneration) {
+			cr.SetConditions(v1alpha1.SpecApplied())
+		}
+
+		// Report once how an adopted VM differs from forProvider
+		if adopted && !upToDate {
+			c.recorder.Event(cr, event.Normal(reasonAdoptVM, adoptionMessage(vmName, diff)))
+		}
 
 		return managed.ExternalObservation{
-			ResourceExists:   true,
-			ResourceUpToDate: upToDate,
+			ResourceExists:          true,
+			ResourceUpToDate:        upToDate,
+			ResourceLateInitialized: lateInitialized,
+			ConnectionDetails:       c.connectionDetails(cr),
 		}, nil
 	default:
 		return managed.ExternalObservation{}, errors.Errorf("unexpected status code: %d", resp.StatusCode)
@@ -350,12 +632,41 @@ func (c *external) Create(ctx context.Context, mg resource.Managed) (managed.Ext
 		return managed.ExternalCreation{}, errors.New(errNotVM)
 	}
 
-	// Use the Kubernetes resource name as the VM name
-	vmName := cr.Name
+	// Derive the Orchard name from the resource's metadata, unless a name was
+	// already chosen. Once set, the external name is never derived again, so
+	// VMs created under a previous strategy keep their names and recreated
+	// VMs reuse theirs.
+	vmName := meta.GetExternalName(cr)
+	if vmName == "" {
+		vmName = vmNameFor(cr, c.nameStrategy)
+	}
+
+	resp, err := c.postVM(ctx, cr, vmName)
+	if err != nil {
+		return managed.ExternalCreation{}, errors.Wrap(err, errCreateVM)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusConflict {
+		// The old VM of a VM being recreated is not gone yet
+		if recreatePending(cr) {
+			return managed.ExternalCreation{}, errors.Errorf(errVMStillDeleting, vmName)
+		}
+		return c.adopt(ctx, cr, vmName)
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return managed.ExternalCreation{}, errors.Errorf("unexpected status code creating VM: %d", resp.StatusCode)
+	}
+
 	meta.SetExternalName(cr, vmName)
+	return managed.ExternalCreation{ConnectionDetails: c.connectionDetails(cr)}, nil
+}
 
+// postVM creates a VM with the supplied name in Orchard.
+func (c *external) postVM(ctx context.Context, cr *v1alpha1.VM, vmName string) (*http.Response, error) {
 	// Build VM spec from parameters
-	vmSpec := buildVMSpec(&cr.Spec.ForProvider)
+	vmSpec := buildVMSpec(&cr.Spec.ForProvider, c.creds)
 
 	// Create VM via Orchard API
 	// NOTE: StartupScript is intentionally NOT included - it causes VM crashes.
@@ -390,18 +701,35 @@ func (c *external) Create(ctx context.Context, mg resource.Managed) (managed.Ext
 		createReq.RestartPolicy = &policy
 	}
 
-	resp, err := c.client.PostVms(ctx, createReq)
+	return c.client.PostVms(ctx, createReq)
+}
 
+// adopt takes over an existing Orchard VM whose name collided with ours, but
+// only if the VM opted in with the adopt annotation. Otherwise it returns an
+// error describing the VM that is in the way.
+func (c *external) adopt(ctx context.Context, cr *v1alpha1.VM, vmName string) (managed.ExternalCreation, error) {
+	resp, err := c.client.GetVmsName(ctx, vmName, nil)
 	if err != nil {
-		return managed.ExternalCreation{}, errors.Wrap(err, errCreateVM)
+		return managed.ExternalCreation{}, errors.Wrap(err, errGetVM)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusConflict {
-		return managed.ExternalCreation{}, errors.Errorf("unexpected status code creating VM: %d", resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		return managed.ExternalCreation{}, errors.Errorf("unexpected status code getting conflicting VM: %d", resp.StatusCode)
 	}
 
-	return managed.ExternalCreation{}, nil
+	var vm orchardclient.VM
+	if err := json.NewDecoder(resp.Body).Decode(&vm); err != nil {
+		return managed.ExternalCreation{}, errors.Wrap(err, "cannot decode VM response")
+	}
+
+	if cr.GetAnnotations()[v1alpha1.AnnotationAdopt] != "true" {
+		return managed.ExternalCreation{}, errors.Errorf(errVMConflict, vmName, describeVM(&vm), v1alpha1.AnnotationAdopt)
+	}
+
+	meta.SetExternalName(cr, vmName)
+	c.recorder.Event(cr, event.Normal(reasonAdoptVM, adoptionMessage(vmName, vmDiff(&cr.Spec.ForProvider, &vm))))
+	return managed.ExternalCreation{ConnectionDetails: c.connectionDetails(cr)}, nil
 }
 
 func (c *external) Update(ctx context.Context, mg resource.Managed) (managed.ExternalUpdate, error) {
@@ -415,8 +743,45 @@ func (c *external) Update(ctx context.Context, mg resource.Managed) (managed.Ext
 		return managed.ExternalUpdate{}, errors.New("external name not set")
 	}
 
+	// A VM whose liveness probe failed is replaced, which applies any
+	// change as well
+	if c.unhealthy != "" {
+		return c.recreate(ctx, cr, vmName, fmt.Sprintf("Recreating VM %q because its %s", vmName, c.unhealthy))
+	}
+
+	// Changed provisioning steps under the Recreate policy replace the VM,
+	// which applies any other change as well
+	if len(c.reprovision) > 0 {
+		return c.recreate(ctx, cr, vmName, recreateMessage(vmName, c.reprovision))
+	}
+
+	fields, class := disruptiveFields(c.diff)
+	if len(fields) > 0 {
+		switch cr.Spec.UpdateStrategy {
+		case v1alpha1.UpdateStrategyReject:
+			// Fail the update, so Synced reports that the spec is not applied
+			err := errors.Errorf(errChangeRejected, strings.Join(fields, ", "))
+			cr.SetConditions(v1alpha1.SpecChangeRejected(truncateMessage(fmt.Sprintf("%s: %s", err, joinDiff(c.diff)))))
+			return managed.ExternalUpdate{}, err
+		case v1alpha1.UpdateStrategyRecreate:
+			return c.recreate(ctx, cr, vmName, recreateMessage(vmName, fields))
+		default:
+			reason := "take effect once Orchard restarts the VM"
+			if class == fieldImmutable {
+				reason = "cannot change on an existing VM; use updateStrategy Recreate to apply them"
+			}
+			cr.SetConditions(v1alpha1.SpecRestartPending(truncateMessage(fmt.Sprintf("changes to %s %s", strings.Join(fields, ", "), reason))))
+
+			// None of the changes apply to the existing VM, so there is
+			// nothing to put
+			if !mutableDiff(c.diff) {
+				return managed.ExternalUpdate{}, nil
+			}
+		}
+	}
+
 	// Build VM spec from parameters
-	vmSpec := buildVMSpec(&cr.Spec.ForProvider)
+	vmSpec := buildVMSpec(&cr.Spec.ForProvider, c.creds)
 
 	// Update VM via Orchard API
 	resp, err := c.client.PutVmsName(ctx, vmName, nil, *vmSpec)
@@ -432,6 +797,49 @@ func (c *external) Update(ctx context.Context, mg resource.Managed) (managed.Ext
 	return managed.ExternalUpdate{}, nil
 }
 
+// recreateMessage explains that a VM is recreated to apply changes to the
+// supplied fields.
+func recreateMessage(vmName string, fields []string) string {
+	return fmt.Sprintf("Recreating VM %q to apply changes to %s", vmName, strings.Join(fields, ", "))
+}
+
+// recreate deletes the Orchard VM to create it again under the same name,
+// e.g. to apply changes that cannot be made in place. The supplied message
+// explains why. Orchard deletes VMs asynchronously, so the new VM is created
+// by Create once Observe no longer finds the old one.
+func (c *external) recreate(ctx context.Context, cr *v1alpha1.VM, vmName string, msg string) (managed.ExternalUpdate, error) {
+	if !recreatePending(cr) {
+		c.provisioner.Forget(cr)
+		c.prober.Stop(cr)
+		c.recorder.Event(cr, event.Normal(reasonRecreateVM, msg))
+		cr.SetConditions(v1alpha1.SpecRecreating(msg))
+
+		// The new VM gets a new IP and must be provisioned again. The logs
+		// and outputs of the old one are kept until then.
+		obs := cr.Status.AtProvider
+		cr.Status.AtProvider = v1alpha1.VMObservation{ProvisioningLogsRef: obs.ProvisioningLogsRef, Outputs: obs.Outputs}
+	}
+
+	resp, err := c.client.DeleteVmsName(ctx, vmName, nil)
+	if err != nil {
+		return managed.ExternalUpdate{}, errors.Wrap(err, errDeleteVM)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return managed.ExternalUpdate{}, errors.Errorf("unexpected status code deleting VM: %d", resp.StatusCode)
+	}
+	return managed.ExternalUpdate{}, nil
+}
+
+// recreatePending returns true while a VM being recreated was deleted but not
+// created again yet. Until then, any VM Orchard reports under its name is the
+// old one, which Orchard is still deleting.
+func recreatePending(cr *v1alpha1.VM) bool {
+	c := cr.GetCondition(v1alpha1.TypeSpecApplied)
+	return c.Reason == v1alpha1.ReasonRecreating && meta.GetExternalCreateSucceeded(cr).Before(c.LastTransitionTime.Time)
+}
+
 func (c *external) Delete(ctx context.Context, mg resource.Managed) (managed.ExternalDelete, error) {
 	cr, ok := mg.(*v1alpha1.VM)
 	if !ok {
@@ -444,6 +852,15 @@ func (c *external) Delete(ctx context.Context, mg resource.Managed) (managed.Ext
 		return managed.ExternalDelete{}, nil
 	}
 
+	c.watches.Stop(cr)
+	c.prober.Stop(cr)
+
+	// Give the VM a chance to clean up first. Delete is called again once
+	// the pre-delete script finished.
+	if ok, err := c.preDelete(cr); !ok {
+		return managed.ExternalDelete{}, err
+	}
+
 	// Delete VM via Orchard API
 	resp, err := c.client.DeleteVmsName(ctx, vmName, nil)
 	if err != nil {
@@ -465,7 +882,7 @@ func (c *external) Disconnect(ctx context.Context) error {
 
 // Helper functions
 
-func buildVMSpec(params *v1alpha1.VMParameters) *orchardclient.VMSpec {
+func buildVMSpec(params *v1alpha1.VMParameters, creds sshCredentials) *orchardclient.VMSpec {
 	spec := &orchardclient.VMSpec{
 		Image: &params.Image,
 	}
@@ -489,8 +906,8 @@ func buildVMSpec(params *v1alpha1.VMParameters) *orchardclient.VMSpec {
 	// Instead, cloud-init scripts are executed via SSH after the VM is running.
 	// See handleCloudInit() and executeCloudInit() for the SSH-based implementation.
 
-	spec.Username = params.Username
-	spec.Password = params.Password
+	spec.Username = creds.Username
+	spec.Password = creds.Password
 	spec.Headless = params.Headless
 	spec.NetBridged = params.NetBridged
 	spec.NetSoftnet = params.NetSoftnet
@@ -547,26 +964,421 @@ func buildVMSpec(params *v1alpha1.VMParameters) *orchardclient.VMSpec {
 }
 
 func isVMUpToDate(params *v1alpha1.VMParameters, vm *orchardclient.VM) bool {
-	// Compare key fields to determine if update is needed
-	// This is a simplified comparison - you may want to add more fields
+	return len(vmDiff(params, vm)) == 0
+}
 
-	if vm.Image != nil && *vm.Image != params.Image {
-		return false
+// vmDiff lists the fields of an Orchard VM that differ from the desired
+// parameters, formatted as "field: observed -> desired". Optional scalar
+// parameters that are unset are left to Orchard and never reported. Unset
+// booleans, lists and maps on the Orchard side count as false or empty, and
+// lists are compared without regard to order.
+func vmDiff(params *v1alpha1.VMParameters, vm *orchardclient.VM) []fieldDiff {
+	var diff []fieldDiff
+	add := func(field string, observed, desired any) {
+		diff = append(diff, fieldDiff{field: field, message: fmt.Sprintf("%s: %v -> %v", field, observed, desired)})
 	}
 
-	if params.CPU != nil && vm.Cpu != nil && *vm.Cpu != float32(*params.CPU) {
-		return false
+	if observed := ptrValue(vm.Image); observed != params.Image {
+		add("image", strconv.Quote(observed), strconv.Quote(params.Image))
 	}
 
-	if params.Memory != nil && vm.Memory != nil && *vm.Memory != float32(*params.Memory) {
-		return false
+	for _, f := range []struct {
+		name     string
+		desired  *int32
+		observed *float32
+	}{
+		{"cpu", params.CPU, vm.Cpu},
+		{"memory", params.Memory, vm.Memory},
+		{"diskSize", params.DiskSize, vm.DiskSize},
+	} {
+		if f.desired != nil && (f.observed == nil || *f.observed != float32(*f.desired)) {
+			add(f.name, formatFloat(f.observed), *f.desired)
+		}
 	}
 
-	// Add more comparisons as needed
+	// Orchard still reports softnet through the deprecated net-softnet field
+	// for VMs created by older clients.
+	netSoftnet := ptrValue(vm.NetSoftnet) || ptrValue(vm.NetSoftnetDeprecated)
+	for _, f := range []struct {
+		name     string
+		desired  *bool
+		observed bool
+	}{
+		{"headless", params.Headless, ptrValue(vm.Headless)},
+		{"netSoftnet", params.NetSoftnet, netSoftnet},
+		{"nested", params.Nested, ptrValue(vm.Nested)},
+		{"suspendable", params.Suspendable, ptrValue(vm.Suspendable)},
+	} {
+		if f.desired != nil && *f.desired != f.observed {
+			add(f.name, f.observed, *f.desired)
+		}
+	}
+
+	if params.NetBridged != nil && *params.NetBridged != ptrValue(vm.NetBridged) {
+		add("netBridged", strconv.Quote(ptrValue(vm.NetBridged)), strconv.Quote(*params.NetBridged))
+	}
+
+	if params.ImagePullPolicy != nil && *params.ImagePullPolicy != string(ptrValue(vm.ImagePullPolicy)) {
+		add("imagePullPolicy", strconv.Quote(string(ptrValue(vm.ImagePullPolicy))), strconv.Quote(*params.ImagePullPolicy))
+	}
+
+	if params.RestartPolicy != nil && *params.RestartPolicy != string(ptrValue(vm.RestartPolicy)) {
+		add("restartPolicy", strconv.Quote(string(ptrValue(vm.RestartPolicy))), strconv.Quote(*params.RestartPolicy))
+	}
+
+	if observed := ptrValue(vm.NetSoftnetAllow); !sameStrings(observed, params.NetSoftnetAllow) {
+		add("netSoftnetAllow", observed, params.NetSoftnetAllow)
+	}
 
+	if observed := ptrValue(vm.NetSoftnetBlock); !sameStrings(observed, params.NetSoftnetBlock) {
+		add("netSoftnetBlock", observed, params.NetSoftnetBlock)
+	}
+
+	if observed, desired := observedHostDirs(vm), desiredHostDirs(params); !sameStrings(observed, desired) {
+		add("hostDirs", observed, desired)
+	}
+
+	if observed := ptrValue(vm.Labels); !containsMap(observed, params.Labels) {
+		add("labels", observed, params.Labels)
+	}
+
+	if observed := ptrValue(vm.Resources); !containsMap(observed, params.Resources) {
+		add("resources", observed, params.Resources)
+	}
+
+	return diff
+}
+
+// lateInitialize copies the values Orchard reports for fields the parameters
+// leave unset, and reports whether it changed anything. Image is required and
+// SSH credentials are never copied into the spec.
+func lateInitialize(params *v1alpha1.VMParameters, vm *orchardclient.VM) bool {
+	changed := false
+
+	for _, f := range []struct {
+		desired  **int32
+		observed *float32
+	}{
+		{&params.CPU, vm.Cpu},
+		{&params.Memory, vm.Memory},
+		{&params.DiskSize, vm.DiskSize},
+	} {
+		if *f.desired == nil && f.observed != nil {
+			v := int32(*f.observed)
+			*f.desired = &v
+			changed = true
+		}
+	}
+
+	for _, f := range []struct {
+		desired  **bool
+		observed *bool
+	}{
+		{&params.Headless, vm.Headless},
+		{&params.NetSoftnet, vm.NetSoftnet},
+		{&params.Nested, vm.Nested},
+		{&params.Suspendable, vm.Suspendable},
+	} {
+		if *f.desired == nil && f.observed != nil {
+			v := *f.observed
+			*f.desired = &v
+			changed = true
+		}
+	}
+
+	if params.NetBridged == nil && vm.NetBridged != nil && *vm.NetBridged != "" {
+		v := *vm.NetBridged
+		params.NetBridged = &v
+		changed = true
+	}
+
+	if params.ImagePullPolicy == nil && vm.ImagePullPolicy != nil {
+		v := string(*vm.ImagePullPolicy)
+		params.ImagePullPolicy = &v
+		changed = true
+	}
+
+	if params.RestartPolicy == nil && vm.RestartPolicy != nil {
+		v := string(*vm.RestartPolicy)
+		params.RestartPolicy = &v
+		changed = true
+	}
+
+	if params.NetSoftnetAllow == nil && len(ptrValue(vm.NetSoftnetAllow)) > 0 {
+		params.NetSoftnetAllow = slices.Clone(*vm.NetSoftnetAllow)
+		changed = true
+	}
+
+	if params.NetSoftnetBlock == nil && len(ptrValue(vm.NetSoftnetBlock)) > 0 {
+		params.NetSoftnetBlock = slices.Clone(*vm.NetSoftnetBlock)
+		changed = true
+	}
+
+	if params.HostDirs == nil && len(ptrValue(vm.HostDirs)) > 0 {
+		for _, hd := range *vm.HostDirs {
+			params.HostDirs = append(params.HostDirs, v1alpha1.VMHostDir{
+				Name: ptrValue(hd.Name),
+				Path: ptrValue(hd.Path),
+				Ro:   hd.Ro,
+			})
+		}
+		changed = true
+	}
+
+	if params.Labels == nil && len(ptrValue(vm.Labels)) > 0 {
+		params.Labels = maps.Clone(*vm.Labels)
+		changed = true
+	}
+
+	if params.Resources == nil && len(ptrValue(vm.Resources)) > 0 {
+		params.Resources = maps.Clone(*vm.Resources)
+		changed = true
+	}
+
+	return changed
+}
+
+// ptrValue returns the value v points to, or the zero value if v is nil.
+func ptrValue[T any](v *T) T {
+	if v == nil {
+		var zero T
+		return zero
+	}
+	return *v
+}
+
+// formatFloat formats an observed number for diffs, or <nil> if unset.
+func formatFloat(f *float32) string {
+	if f == nil {
+		return "<nil>"
+	}
+	return strconv.FormatFloat(float64(*f), 'f', -1, 32)
+}
+
+// sameStrings reports whether two lists hold the same strings, regardless of
+// order. Nil and empty lists are equal.
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+// containsMap returns true if observed has every key of desired with the same
+// value. Keys only Orchard set, such as the default tart-vms resource, are
+// ignored, as is a nil desired map.
+func containsMap[V comparable](observed, desired map[string]V) bool {
+	for k, v := range desired {
+		if o, ok := observed[k]; !ok || o != v {
+			return false
+		}
+	}
 	return true
 }
 
+// desiredHostDirs formats the desired host directory mounts for comparison.
+func desiredHostDirs(params *v1alpha1.VMParameters) []string {
+	dirs := make([]string, 0, len(params.HostDirs))
+	for _, hd := range params.HostDirs {
+		dirs = append(dirs, formatHostDir(hd.Name, hd.Path, ptrValue(hd.Ro)))
+	}
+	return dirs
+}
+
+// observedHostDirs formats an Orchard VM's host directory mounts for comparison.
+func observedHostDirs(vm *orchardclient.VM) []string {
+	if vm.HostDirs == nil {
+		return nil
+	}
+	dirs := make([]string, 0, len(*vm.HostDirs))
+	for _, hd := range *vm.HostDirs {
+		dirs = append(dirs, formatHostDir(ptrValue(hd.Name), ptrValue(hd.Path), ptrValue(hd.Ro)))
+	}
+	return dirs
+}
+
+// formatHostDir formats a host directory mount as name:path[:ro].
+func formatHostDir(name, path string, ro bool) string {
+	if ro {
+		return name + ":" + path + ":ro"
+	}
+	return name + ":" + path
+}
+
+// vmNameFor derives the Orchard name of a new VM using the supplied strategy,
+// defaulting to namespaced names.
+func vmNameFor(cr *v1alpha1.VM, strategy string) string {
+	namespaced := func(name string) string {
+		if cr.GetNamespace() == "" {
+			return name
+		}
+		return cr.GetNamespace() + "-" + name
+	}
+
+	switch strategy {
+	case apisv1alpha1.VMNameStrategyName:
+		return shortenVMName(cr.GetName(), maxVMNameLength)
+	case apisv1alpha1.VMNameStrategyGenerateName:
+		prefix := cr.GetGenerateName()
+		if prefix == "" {
+			prefix = cr.GetName() + "-"
+		}
+		return shortenVMName(namespaced(prefix), maxVMNameLength-vmNameGenerateLength) + rand.String(vmNameGenerateLength)
+	default:
+		return namespacedVMName(cr.GetNamespace(), cr.GetName())
+	}
+}
+
+// namespacedVMName returns <namespace>-<name>-<hash>. Joining the namespace and
+// name with a hyphen alone is ambiguous, e.g. for team-a/b and team/a-b, so a
+// hash of <namespace>/<name> keeps their names apart. Long names are
+// truncated before the hash.
+func namespacedVMName(namespace, name string) string {
+	if namespace == "" {
+		return shortenVMName(name, maxVMNameLength)
+	}
+	sum := sha256.Sum256([]byte(namespace + "/" + name))
+	suffix := hex.EncodeToString(sum[:])[:vmNameHashLength]
+	prefix := namespace + "-" + name
+	if max := maxVMNameLength - vmNameHashLength - 1; len(prefix) > max {
+		prefix = strings.TrimRight(prefix[:max], "-.")
+	}
+	return prefix + "-" + suffix
+}
+
+// shortenVMName truncates names longer than max and appends a hash of the
+// full name, so distinct long names stay distinct.
+func shortenVMName(name string, max int) string {
+	if len(name) <= max {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	suffix := hex.EncodeToString(sum[:])[:vmNameHashLength]
+	return strings.TrimRight(name[:max-vmNameHashLength-1], "-.") + "-" + suffix
+}
+
+// credentialsDiff reports SSH credentials that differ from those Orchard has
+// for a VM, e.g. after a referenced Secret was rotated. Values are omitted.
+func credentialsDiff(creds sshCredentials, vm *orchardclient.VM) []fieldDiff {
+	var diff []fieldDiff
+	if creds.Username != nil && vm.Username != nil && *creds.Username != *vm.Username {
+		diff = append(diff, fieldDiff{field: "username", message: "username changed"})
+	}
+	if creds.Password != nil && vm.Password != nil && *creds.Password != *vm.Password {
+		diff = append(diff, fieldDiff{field: "password", message: "password changed"})
+	}
+	return diff
+}
+
+// A fieldDiff describes a VM field whose value in Orchard differs from the
+// spec.
+type fieldDiff struct {
+	field   string
+	message string
+}
+
+// A fieldClass describes how a change to a VM field can be applied.
+type fieldClass int
+
+// Field classes, in increasing order of disruption.
+const (
+	// fieldInPlace changes apply to a running VM.
+	fieldInPlace fieldClass = iota
+
+	// fieldRestart changes apply once the VM restarts.
+	fieldRestart
+
+	// fieldImmutable changes cannot apply to an existing VM.
+	fieldImmutable
+)
+
+// fieldClasses classifies the VM fields that cannot be changed in place.
+// Fields not listed here change in place.
+var fieldClasses = map[string]fieldClass{
+	"image":           fieldImmutable,
+	"diskSize":        fieldImmutable,
+	"labels":          fieldImmutable,
+	"resources":       fieldImmutable,
+	"cpu":             fieldRestart,
+	"memory":          fieldRestart,
+	"headless":        fieldRestart,
+	"nested":          fieldRestart,
+	"suspendable":     fieldRestart,
+	"netBridged":      fieldRestart,
+	"netSoftnet":      fieldRestart,
+	"netSoftnetAllow": fieldRestart,
+	"netSoftnetBlock": fieldRestart,
+	"hostDirs":        fieldRestart,
+}
+
+// disruptiveFields returns the fields of a diff that cannot change in place,
+// and the most disruptive class among them.
+func disruptiveFields(diff []fieldDiff) ([]string, fieldClass) {
+	var fields []string
+	worst := fieldInPlace
+	for _, d := range diff {
+		class := fieldClasses[d.field]
+		if class == fieldInPlace {
+			continue
+		}
+		fields = append(fields, d.field)
+		worst = max(worst, class)
+	}
+	return fields, worst
+}
+
+// mutableDiff returns true if any field of a diff can change on an existing
+// VM, in place or once it restarts.
+func mutableDiff(diff []fieldDiff) bool {
+	for _, d := range diff {
+		if fieldClasses[d.field] != fieldImmutable {
+			return true
+		}
+	}
+	return false
+}
+
+// joinDiff formats a diff for messages.
+func joinDiff(diff []fieldDiff) string {
+	msgs := make([]string, 0, len(diff))
+	for _, d := range diff {
+		msgs = append(msgs, d.message)
+	}
+	return strings.Join(msgs, ", ")
+}
+
+// describeVM summarizes an Orchard VM for error messages.
+func describeVM(vm *orchardclient.VM) string {
+	parts := []string{}
+	if vm.Image != nil {
+		parts = append(parts, "image "+*vm.Image)
+	}
+	if vm.Status != nil {
+		parts = append(parts, "status "+string(*vm.Status))
+	}
+	if vm.Worker != nil && *vm.Worker != "" {
+		parts = append(parts, "worker "+*vm.Worker)
+	}
+	if vm.Cpu != nil {
+		parts = append(parts, fmt.Sprintf("cpu %v", *vm.Cpu))
+	}
+	if vm.Memory != nil {
+		parts = append(parts, fmt.Sprintf("memory %v", *vm.Memory))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// adoptionMessage describes an adopted VM's differences from forProvider.
+func adoptionMessage(vmName string, diff []fieldDiff) string {
+	if len(diff) == 0 {
+		return fmt.Sprintf("Adopted existing Orchard VM %q, which matches forProvider", vmName)
+	}
+	return truncateMessage(fmt.Sprintf("Adopted existing Orchard VM %q; forProvider will overwrite %s", vmName, joinDiff(diff)))
+}
+
 func stringValue(s *orchardclient.VMStatus) string {
 	if s == nil {
 		return ""
@@ -593,16 +1405,16 @@ func truncateMessage(msg string) string {
 	return msg[:maxConditionMessageLen-3] + "..."
 }
 
-// getSSHCredentials returns SSH username and password from spec or defaults
-func getSSHCredentials(params *v1alpha1.VMParameters) (string, string) {
-	username := DefaultSSHUsername
-	password := DefaultSSHPassword
+// getSSHCredentials returns SSH username and password from resolved credentials or the guest profile's defaults
+func getSSHCredentials(creds sshCredentials, p guestProfile) (string, string) {
+	username := p.username
+	password := p.password
 
-	if params.Username != nil && *params.Username != "" {
-		username = *params.Username
+	if creds.Username != nil && *creds.Username != "" {
+		username = *creds.Username
 	}
-	if params.Password != nil && *params.Password != "" {
-		password = *params.Password
+	if creds.Password != nil && *creds.Password != "" {
+		password = *creds.Password
 	}
 
 	return username, password
@@ -610,7 +1422,7 @@ func getSSHCredentials(params *v1alpha1.VMParameters) (string, string) {
 
 // buildTunnelConfig creates SSH tunnel configuration from CR and client
 func (c *external) buildTunnelConfig(cr *v1alpha1.VM) ssh.TunnelConfig {
-	username, password := getSSHCredentials(&cr.Spec.ForProvider)
+	username, password := getSSHCredentials(c.creds, guestProfileFor(cr.Spec.ForProvider.GuestOS))
 
 	return ssh.TunnelConfig{
 		OrchardBaseURL: c.baseURL,
@@ -624,89 +1436,59 @@ func (c *external) buildTunnelConfig(cr *v1alpha1.VM) ssh.TunnelConfig {
 	}
 }
 
-// isSSHReady tests if SSH connection is available by running a simple command
-func (c *external) isSSHReady(ctx context.Context, cr *v1alpha1.VM) bool {
+// connectionDetails returns the details needed to reach a VM over SSH. The
+// endpoint is omitted until Orchard reports an IP, and the details are
+// republished on every observation so they follow IP changes.
+func (c *external) connectionDetails(cr *v1alpha1.VM) managed.ConnectionDetails {
 	config := c.buildTunnelConfig(cr)
 
-	session, err := ssh.NewVMSession(ctx, config)
-	if err != nil {
-		return false
+	details := managed.ConnectionDetails{
+		xpv1.ResourceCredentialsSecretUserKey:     []byte(config.SSHUsername),
+		xpv1.ResourceCredentialsSecretPasswordKey: []byte(config.SSHPassword),
+		xpv1.ResourceCredentialsSecretPortKey:     []byte(strconv.Itoa(config.SSHPort)),
 	}
-	defer session.Close()
-
-	// Run a simple command to verify SSH works
-	result, err := session.ExecuteCommand(ctx, "ls -al")
-	return err == nil && result.ExitCode == 0
-}
-
-// handleCloudInit checks if cloud-init is needed and handles execution
-func (c *external) handleCloudInit(ctx context.Context, cr *v1alpha1.VM) error {
-	// Check if there's a startup script to execute
-	if cr.Spec.ForProvider.StartupScript == nil || cr.Spec.ForProvider.StartupScript.ScriptContent == "" {
-		// No startup script - VM is available immediately
-		cr.SetConditions(xpv1.Available())
-		return nil
+	if ip := cr.Status.AtProvider.IPAddress; ip != "" {
+		details[xpv1.ResourceCredentialsSecretEndpointKey] = []byte(ip)
 	}
-
-	// Check cloud-init status annotation
-	status := getCloudInitStatus(cr)
-
-	switch status {
-	case CloudInitStatusCompleted:
-		cr.SetConditions(xpv1.Available())
-		return nil
-	case CloudInitStatusFailed:
-		cond := xpv1.Unavailable()
-		cond.Message = truncateMessage(cr.Status.AtProvider.CloudInitMessage)
-		cr.SetConditions(cond)
-		return nil
-	case CloudInitStatusRunning:
-		cr.SetConditions(xpv1.Creating())
-		return nil
-	default:
-		// Empty or "pending" - execute cloud-init
-		return c.executeCloudInit(ctx, cr)
+	if worker := cr.Status.AtProvider.Worker; worker != "" {
+		details[ConnectionDetailWorker] = []byte(worker)
 	}
+	if c.baseURL != "" {
+		if url, err := ssh.PortForwardURL(config); err == nil {
+			details[ConnectionDetailPortForwardURL] = []byte(url)
+		}
+	}
+	// Sensitive script outputs never replace the details above
+	for k, v := range c.outputs {
+		if _, ok := details[k]; !ok {
+			details[k] = v
+		}
+	}
+	return details
 }
 
-// executeCloudInit runs the startup script via SSH
-func (c *external) executeCloudInit(ctx context.Context, cr *v1alpha1.VM) error {
-	// Set status to running
-	setCloudInitStatus(cr, CloudInitStatusRunning, "")
-
-	// Check SSH readiness first
-	if !c.isSSHReady(ctx, cr) {
-		// SSH not ready yet - return error to retry later
-		setCloudInitStatus(cr, CloudInitStatusPending, "waiting for SSH")
-		return errors.New(errSSHNotReady)
+// sshReady tests if SSH connection is available by running a simple command.
+// It returns why the VM cannot be reached if it is not.
+func sshReady(ctx context.Context, newSession func(context.Context, ssh.TunnelConfig) (ssh.VMSession, error), config ssh.TunnelConfig) error {
+	session, err := newSession(ctx, config)
+	if err != nil {
+		return err
 	}
+	defer session.Close()
 
-	config := c.buildTunnelConfig(cr)
-	script := cr.Spec.ForProvider.StartupScript.ScriptContent
-	env := cr.Spec.ForProvider.StartupScript.Env
-
-	// Execute the script via SSH
-	result, err := ssh.UploadAndRunScript(ctx, config, script, env)
+	// Run a simple command to verify SSH works
+	result, err := session.ExecuteCommand(ctx, "ls -al")
 	if err != nil {
-		message := fmt.Sprintf("cloud-init failed: %s", err.Error())
-		setCloudInitStatus(cr, CloudInitStatusFailed, message)
-		cond := xpv1.Unavailable()
-		cond.Message = truncateMessage(message)
-		cr.SetConditions(cond)
-		return nil // Don't return error - we've handled it by setting status
+		return err
 	}
-
 	if result.ExitCode != 0 {
-		message := fmt.Sprintf("exit code %d: %s", result.ExitCode, result.Stderr)
-		setCloudInitStatus(cr, CloudInitStatusFailed, message)
-		cond := xpv1.Unavailable()
-		cond.Message = truncateMessage(message)
-		cr.SetConditions(cond)
-		return nil // Don't return error - we've handled it by setting status
+		return errors.Wrapf(ssh.ErrVMNotReady, "readiness check exited with code %d", result.ExitCode)
 	}
-
-	// Success
-	setCloudInitStatus(cr, CloudInitStatusCompleted, "")
-	cr.SetConditions(xpv1.Available())
 	return nil
 }
+
+// transientSSHError returns true if an error means the VM could not be
+// reached, rather than that a script failed.
+func transientSSHError(err error) bool {
+	return errors.Is(err, ssh.ErrConnectionFailed) || errors.Is(err, ssh.ErrVMNotReady)
+}
@@ -20,24 +20,42 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/crossplane/crossplane-runtime/v2/pkg/event"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/logging"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/reconciler/managed"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/test"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlevent "sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
 	v1alpha1 "github.com/ravan/provider-orchard/apis/compute/v1alpha1"
 	apisv1alpha1 "github.com/ravan/provider-orchard/apis/v1alpha1"
 	orchardclient "github.com/ravan/provider-orchard/internal/clients/orchard"
-	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/ravan/provider-orchard/internal/ssh"
 )
 
 // Mock HTTP client for testing
@@ -180,13 +198,13 @@ func TestConnect(t *testing.T) {
 				}(),
 			},
 			want: want{
-				err: errors.New(errParseToken),
+				err: errors.New("cannot parse token from credentials"),
 			},
 		},
 		"GetProviderConfigError": {
 			reason: "Should return error if ProviderConfig cannot be fetched",
 			kube: &test.MockClient{
-				MockGet: test.NewMockGetFn(errBoom),
+				MockGet:          test.NewMockGetFn(errBoom),
 				MockStatusUpdate: test.NewMockSubResourceUpdateFn(nil),
 			},
 			args: args{
@@ -209,6 +227,118 @@ func TestConnect(t *testing.T) {
 				err: errors.Wrap(errors.Wrap(errors.Wrap(errBoom, "cannot get object"), "cannot apply ProviderConfigUsage"), errTrackPCUsage),
 			},
 		},
+		"DeletedVMWithoutCredentialsSecret": {
+			reason: "Should connect a VM being deleted whose SSH credentials Secret is already gone",
+			kube: &test.MockClient{
+				MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+					switch o := obj.(type) {
+					case *apisv1alpha1.ProviderConfig:
+						o.Spec.Credentials = apisv1alpha1.ProviderCredentials{
+							Source: xpv1.CredentialsSourceSecret,
+							CommonCredentialSelectors: xpv1.CommonCredentialSelectors{
+								SecretRef: &xpv1.SecretKeySelector{
+									SecretReference: xpv1.SecretReference{
+										Name:      "test-secret",
+										Namespace: "default",
+									},
+									Key: "credentials",
+								},
+							},
+						}
+						o.Spec.BaseURL = testBaseURL
+					case *corev1.Secret:
+						if key.Name != "test-secret" {
+							return kerrors.NewNotFound(corev1.Resource("secrets"), key.Name)
+						}
+						o.Data = map[string][]byte{
+							"credentials": []byte(`{"token":"` + testToken + `"}`),
+						}
+					}
+					return nil
+				},
+				MockStatusUpdate: test.NewMockSubResourceUpdateFn(nil),
+			},
+			args: args{
+				ctx: context.Background(),
+				mg: func() resource.Managed {
+					vm := &v1alpha1.VM{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:              "test-vm",
+							Namespace:         "default",
+							DeletionTimestamp: &metav1.Time{Time: time.Now()},
+						},
+						Spec: v1alpha1.VMSpec{ForProvider: v1alpha1.VMParameters{
+							PasswordSecretRef: &xpv1.LocalSecretKeySelector{LocalSecretReference: xpv1.LocalSecretReference{Name: "vm-ssh"}, Key: "pass"},
+						}},
+					}
+					vm.SetProviderConfigReference(&xpv1.ProviderConfigReference{
+						Name: "test-config",
+						Kind: "ProviderConfig",
+					})
+					return vm
+				}(),
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"DeletedVMWithoutStartupScriptSources": {
+			reason: "Should connect a VM being deleted whose startup script references objects that are already gone",
+			kube: &test.MockClient{
+				MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+					switch o := obj.(type) {
+					case *apisv1alpha1.ProviderConfig:
+						o.Spec.Credentials = apisv1alpha1.ProviderCredentials{
+							Source: xpv1.CredentialsSourceSecret,
+							CommonCredentialSelectors: xpv1.CommonCredentialSelectors{
+								SecretRef: &xpv1.SecretKeySelector{
+									SecretReference: xpv1.SecretReference{
+										Name:      "test-secret",
+										Namespace: "default",
+									},
+									Key: "credentials",
+								},
+							},
+						}
+						o.Spec.BaseURL = testBaseURL
+					case *corev1.Secret:
+						o.Data = map[string][]byte{
+							"credentials": []byte(`{"token":"` + testToken + `"}`),
+						}
+					case *corev1.ConfigMap:
+						return kerrors.NewNotFound(corev1.Resource("configmaps"), key.Name)
+					}
+					return nil
+				},
+				MockStatusUpdate: test.NewMockSubResourceUpdateFn(nil),
+			},
+			args: args{
+				ctx: context.Background(),
+				mg: func() resource.Managed {
+					vm := &v1alpha1.VM{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:              "test-vm",
+							Namespace:         "default",
+							DeletionTimestamp: &metav1.Time{Time: time.Now()},
+						},
+						Spec: v1alpha1.VMSpec{ForProvider: v1alpha1.VMParameters{
+							StartupScript: &v1alpha1.VMStartupScript{
+								ScriptFrom: &v1alpha1.VMValueSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "scripts"}, Key: "setup.sh"}},
+								EnvFrom:    []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "env"}}}},
+							},
+						}},
+					}
+					vm.SetProviderConfigReference(&xpv1.ProviderConfigReference{
+						Name: "test-config",
+						Kind: "ProviderConfig",
+					})
+					return vm
+				}(),
+			},
+			want: want{
+				err: nil,
+			},
+		},
 	}
 
 	for name, tc := range cases {
@@ -234,7 +364,10 @@ func TestObserve(t *testing.T) {
 	vmObservedGeneration := float32(1)
 
 	type fields struct {
-		client *orchardclient.OrchardClient
+		client   *orchardclient.OrchardClient
+		baseURL  string
+		creds    sshCredentials
+		credsErr error
 	}
 
 	type args struct {
@@ -268,6 +401,51 @@ func TestObserve(t *testing.T) {
 				err: nil,
 			},
 		},
+		"CredentialsUnavailable": {
+			reason: "Should return an error if the VM's SSH credentials cannot be read",
+			fields: fields{credsErr: errors.New("boom")},
+			args: args{
+				ctx: context.Background(),
+				mg: &v1alpha1.VM{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: vmName,
+					},
+				},
+			},
+			want: want{
+				err: errors.New("boom"),
+			},
+		},
+		"CredentialsUnavailableWhileDeleting": {
+			reason: "Should observe a VM being deleted even if its SSH credentials cannot be read",
+			fields: fields{
+				credsErr: errors.New("boom"),
+				client: newMockOrchardClient(&mockHTTPClient{
+					DoFunc: func(req *http.Request) (*http.Response, error) {
+						return &http.Response{
+							StatusCode: http.StatusNotFound,
+							Body:       io.NopCloser(bytes.NewBufferString("")),
+						}, nil
+					},
+				}),
+			},
+			args: args{
+				ctx: context.Background(),
+				mg: func() resource.Managed {
+					vm := &v1alpha1.VM{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:              vmName,
+							DeletionTimestamp: &metav1.Time{Time: time.Now()},
+						},
+					}
+					meta.SetExternalName(vm, vmName)
+					return vm
+				}(),
+			},
+			want: want{
+				o: managed.ExternalObservation{ResourceExists: false},
+			},
+		},
 		"VMNotFound": {
 			reason: "Should return ResourceExists=false if VM doesn't exist in Orchard",
 			fields: fields{
@@ -338,6 +516,12 @@ func TestObserve(t *testing.T) {
 				o: managed.ExternalObservation{
 					ResourceExists:   true,
 					ResourceUpToDate: true,
+					ConnectionDetails: managed.ConnectionDetails{
+						xpv1.ResourceCredentialsSecretUserKey:     []byte(DefaultSSHUsername),
+						xpv1.ResourceCredentialsSecretPasswordKey: []byte(DefaultSSHPassword),
+						xpv1.ResourceCredentialsSecretPortKey:     []byte("22"),
+						ConnectionDetailWorker:                    []byte(vmWorker),
+					},
 				},
 				err: nil,
 			},
@@ -384,6 +568,67 @@ func TestObserve(t *testing.T) {
 				o: managed.ExternalObservation{
 					ResourceExists:   true,
 					ResourceUpToDate: false,
+					ConnectionDetails: managed.ConnectionDetails{
+						xpv1.ResourceCredentialsSecretUserKey:     []byte(DefaultSSHUsername),
+						xpv1.ResourceCredentialsSecretPasswordKey: []byte(DefaultSSHPassword),
+						xpv1.ResourceCredentialsSecretPortKey:     []byte("22"),
+						ConnectionDetailWorker:                    []byte(vmWorker),
+					},
+				},
+				err: nil,
+			},
+		},
+		"PublishesConnectionDetails": {
+			reason: "Should publish the IP, SSH credentials, worker and port-forward URL of a running VM",
+			fields: fields{
+				baseURL: "http://orchard.example.com:6120/v1",
+				creds:   sshCredentials{Username: ptr("runner"), Password: ptr("s3cret")},
+				client: newMockOrchardClient(&mockHTTPClient{
+					DoFunc: func(req *http.Request) (*http.Response, error) {
+						var body []byte
+						if strings.HasSuffix(req.URL.Path, "/ip") {
+							body, _ = json.Marshal(orchardclient.IP{Ip: ptr("192.168.64.7")})
+						} else {
+							body, _ = json.Marshal(orchardclient.VM{Image: &vmImage, Status: &vmStatus, Worker: &vmWorker})
+						}
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Body:       io.NopCloser(bytes.NewBuffer(body)),
+						}, nil
+					},
+				}),
+			},
+			args: args{
+				ctx: context.Background(),
+				mg: func() resource.Managed {
+					vm := &v1alpha1.VM{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: vmName,
+						},
+						Spec: v1alpha1.VMSpec{
+							ForProvider: v1alpha1.VMParameters{
+								Image:    vmImage,
+								Username: ptr("runner"),
+								Password: ptr("s3cret"),
+							},
+						},
+					}
+					meta.SetExternalName(vm, vmName)
+					return vm
+				}(),
+			},
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:   true,
+					ResourceUpToDate: true,
+					ConnectionDetails: managed.ConnectionDetails{
+						xpv1.ResourceCredentialsSecretEndpointKey: []byte("192.168.64.7"),
+						xpv1.ResourceCredentialsSecretUserKey:     []byte("runner"),
+						xpv1.ResourceCredentialsSecretPasswordKey: []byte("s3cret"),
+						xpv1.ResourceCredentialsSecretPortKey:     []byte("22"),
+						ConnectionDetailWorker:                    []byte(vmWorker),
+						ConnectionDetailPortForwardURL:            []byte("ws://orchard.example.com:6120/v1/vms/test-vm/port-forward?port=22&wait=30"),
+					},
 				},
 				err: nil,
 			},
@@ -392,7 +637,7 @@ func TestObserve(t *testing.T) {
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			e := &external{client: tc.fields.client}
+			e := &external{client: tc.fields.client, baseURL: tc.fields.baseURL, creds: tc.fields.creds, credsErr: tc.fields.credsErr, recorder: event.NewNopRecorder()}
 			got, err := e.Observe(tc.args.ctx, tc.args.mg)
 			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
 				t.Errorf("\n%s\ne.Observe(...): -want error, +got error:\n%s\n", tc.reason, diff)
@@ -404,9 +649,66 @@ func TestObserve(t *testing.T) {
 	}
 }
 
+func TestObserveAdoption(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		vm     func(cr *v1alpha1.VM)
+		want   []event.Reason
+	}{
+		"FirstObservation": {
+			reason: "Should report how a VM adopted through a pre-set external name differs from forProvider",
+			vm:     func(_ *v1alpha1.VM) {},
+			want:   []event.Reason{reasonAdoptVM},
+		},
+		"AlreadyObserved": {
+			reason: "Should not report an adopted VM again once it was observed",
+			vm:     func(cr *v1alpha1.VM) { cr.Status.AtProvider.Status = "pending" },
+			want:   []event.Reason{},
+		},
+		"CreatedByProvider": {
+			reason: "Should not report a VM the provider created as adopted",
+			vm:     func(cr *v1alpha1.VM) { meta.SetExternalCreateSucceeded(cr, time.Now()) },
+			want:   []event.Reason{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			client := newMockOrchardClient(&mockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(bytes.NewBufferString(`{"name":"test-vm","image":"ubuntu:24.04","status":"pending"}`)),
+					}, nil
+				},
+			})
+			cr := &v1alpha1.VM{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-vm"},
+				Spec:       v1alpha1.VMSpec{ForProvider: v1alpha1.VMParameters{Image: "ubuntu:22.04"}},
+			}
+			meta.SetExternalName(cr, "test-vm")
+			tc.vm(cr)
+
+			recorder := &recordingRecorder{}
+			e := &external{client: client, recorder: recorder}
+			if _, err := e.Observe(context.Background(), cr); err != nil {
+				t.Fatalf("\n%s\ne.Observe(...): %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, recorder.reasons()); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want events, +got events:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
 func TestCreate(t *testing.T) {
 	vmName := "test-vm"
 	vmImage := "ubuntu:22.04"
+	details := managed.ConnectionDetails{
+		xpv1.ResourceCredentialsSecretUserKey:     []byte(DefaultSSHUsername),
+		xpv1.ResourceCredentialsSecretPasswordKey: []byte(DefaultSSHPassword),
+		xpv1.ResourceCredentialsSecretPortKey:     []byte("22"),
+	}
 
 	type fields struct {
 		client *orchardclient.OrchardClient
@@ -454,18 +756,27 @@ func TestCreate(t *testing.T) {
 				},
 			},
 			want: want{
-				c:   managed.ExternalCreation{},
+				c:   managed.ExternalCreation{ConnectionDetails: details},
 				err: nil,
 			},
 		},
-		"ConflictHandled": {
-			reason: "Should handle 409 conflict gracefully",
+		"ConflictRejected": {
+			reason: "Should refuse to take over an existing VM that was not opted into adoption",
 			fields: fields{
 				client: newMockOrchardClient(&mockHTTPClient{
 					DoFunc: func(req *http.Request) (*http.Response, error) {
+						if req.Method == http.MethodPost {
+							return &http.Response{
+								StatusCode: http.StatusConflict,
+								Body:       io.NopCloser(bytes.NewBufferString("")),
+							}, nil
+						}
+						status := orchardclient.VMStatus("running")
+						worker := "mac-mini-1"
+						body, _ := json.Marshal(orchardclient.VM{Image: &vmImage, Status: &status, Worker: &worker})
 						return &http.Response{
-							StatusCode: http.StatusConflict,
-							Body:       io.NopCloser(bytes.NewBufferString("")),
+							StatusCode: http.StatusOK,
+							Body:       io.NopCloser(bytes.NewBuffer(body)),
 						}, nil
 					},
 				}),
@@ -485,6 +796,44 @@ func TestCreate(t *testing.T) {
 			},
 			want: want{
 				c:   managed.ExternalCreation{},
+				err: errors.Errorf(errVMConflict, vmName, "image ubuntu:22.04, status running, worker mac-mini-1", v1alpha1.AnnotationAdopt),
+			},
+		},
+		"ConflictAdopted": {
+			reason: "Should adopt an existing VM when the adopt annotation is set",
+			fields: fields{
+				client: newMockOrchardClient(&mockHTTPClient{
+					DoFunc: func(req *http.Request) (*http.Response, error) {
+						if req.Method == http.MethodPost {
+							return &http.Response{
+								StatusCode: http.StatusConflict,
+								Body:       io.NopCloser(bytes.NewBufferString("")),
+							}, nil
+						}
+						body, _ := json.Marshal(orchardclient.VM{Image: &vmImage})
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Body:       io.NopCloser(bytes.NewBuffer(body)),
+						}, nil
+					},
+				}),
+			},
+			args: args{
+				ctx: context.Background(),
+				mg: &v1alpha1.VM{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        vmName,
+						Annotations: map[string]string{v1alpha1.AnnotationAdopt: "true"},
+					},
+					Spec: v1alpha1.VMSpec{
+						ForProvider: v1alpha1.VMParameters{
+							Image: vmImage,
+						},
+					},
+				},
+			},
+			want: want{
+				c:   managed.ExternalCreation{ConnectionDetails: details},
 				err: nil,
 			},
 		},
@@ -522,7 +871,7 @@ func TestCreate(t *testing.T) {
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			e := &external{client: tc.fields.client}
+			e := &external{client: tc.fields.client, recorder: event.NewNopRecorder()}
 			got, err := e.Create(tc.args.ctx, tc.args.mg)
 			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
 				t.Errorf("\n%s\ne.Create(...): -want error, +got error:\n%s\n", tc.reason, diff)
@@ -650,7 +999,7 @@ func TestUpdate(t *testing.T) {
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			e := &external{client: tc.fields.client}
+			e := &external{client: tc.fields.client, recorder: event.NewNopRecorder()}
 			got, err := e.Update(tc.args.ctx, tc.args.mg)
 			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
 				t.Errorf("\n%s\ne.Update(...): -want error, +got error:\n%s\n", tc.reason, diff)
@@ -662,55 +1011,243 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
-func TestDelete(t *testing.T) {
+func TestUpdateStrategy(t *testing.T) {
 	vmName := "test-vm"
-	vmImage := "ubuntu:22.04"
-
-	type fields struct {
-		client *orchardclient.OrchardClient
-	}
-
-	type args struct {
-		ctx context.Context
-		mg  resource.Managed
-	}
+	imageDiff := []fieldDiff{{field: "image", message: `image: "ubuntu:20.04" -> "ubuntu:22.04"`}}
+	cpuDiff := []fieldDiff{{field: "cpu", message: "cpu: 2 -> 4"}}
+	policyDiff := []fieldDiff{{field: "restartPolicy", message: `restartPolicy: "Never" -> "OnFailure"`}}
 
 	type want struct {
-		err error
+		requests []string
+		reason   xpv1.ConditionReason
+		err      error
 	}
 
 	cases := map[string]struct {
-		reason string
-		fields fields
-		args   args
-		want   want
+		reason      string
+		strategy    string
+		diff        []fieldDiff
+		reprovision []string
+		unhealthy   string
+		want        want
 	}{
-		"NoExternalName": {
-			reason: "Should return nil if external name is not set (nothing to delete)",
-			args: args{
-				ctx: context.Background(),
-				mg: &v1alpha1.VM{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: vmName,
-					},
-					Spec: v1alpha1.VMSpec{
-						ForProvider: v1alpha1.VMParameters{
-							Image: vmImage,
-						},
-					},
-				},
-			},
-			want: want{
-				err: nil,
-			},
+		"InPlaceOnlyChange": {
+			reason:   "Should update fields that change in place regardless of strategy",
+			strategy: v1alpha1.UpdateStrategyReject,
+			diff:     policyDiff,
+			want:     want{requests: []string{"PUT /vms/test-vm"}},
 		},
-		"SuccessfulDelete": {
-			reason: "Should successfully delete VM",
-			fields: fields{
-				client: newMockOrchardClient(&mockHTTPClient{
-					DoFunc: func(req *http.Request) (*http.Response, error) {
-						return &http.Response{
-							StatusCode: http.StatusOK,
+		"InPlaceRestartPending": {
+			reason:   "Should update in place and report that a restart is pending",
+			strategy: v1alpha1.UpdateStrategyInPlace,
+			diff:     cpuDiff,
+			want:     want{requests: []string{"PUT /vms/test-vm"}, reason: v1alpha1.ReasonRestartPending},
+		},
+		"DefaultIsInPlace": {
+			reason: "Should update in place when no strategy is set",
+			diff:   cpuDiff,
+			want:   want{requests: []string{"PUT /vms/test-vm"}, reason: v1alpha1.ReasonRestartPending},
+		},
+		"InPlaceImmutableOnly": {
+			reason: "Should not update a VM in place when only fields that cannot change on it differ",
+			diff:   imageDiff,
+			want:   want{reason: v1alpha1.ReasonRestartPending},
+		},
+		"InPlaceImmutableAndMutable": {
+			reason:   "Should update the fields that can change in place alongside ones that cannot",
+			strategy: v1alpha1.UpdateStrategyInPlace,
+			diff:     append(imageDiff, policyDiff...),
+			want:     want{requests: []string{"PUT /vms/test-vm"}, reason: v1alpha1.ReasonRestartPending},
+		},
+		"Reject": {
+			reason:   "Should leave the VM untouched and report the rejected change",
+			strategy: v1alpha1.UpdateStrategyReject,
+			diff:     append(cpuDiff, policyDiff...),
+			want:     want{reason: v1alpha1.ReasonChangeRejected, err: errors.Errorf(errChangeRejected, "cpu")},
+		},
+		"Recreate": {
+			reason:   "Should delete the VM, so Create creates it again under the same name",
+			strategy: v1alpha1.UpdateStrategyRecreate,
+			diff:     imageDiff,
+			want:     want{requests: []string{"DELETE /vms/test-vm"}, reason: v1alpha1.ReasonRecreating},
+		},
+		"ReprovisionRecreates": {
+			reason:      "Should recreate the VM for changed provisioning steps regardless of strategy",
+			strategy:    v1alpha1.UpdateStrategyReject,
+			diff:        cpuDiff,
+			reprovision: []string{`provisioning step "a"`},
+			want:        want{requests: []string{"DELETE /vms/test-vm"}, reason: v1alpha1.ReasonRecreating},
+		},
+		"UnhealthyRecreates": {
+			reason:    "Should recreate a VM whose liveness probe failed regardless of strategy",
+			strategy:  v1alpha1.UpdateStrategyReject,
+			unhealthy: "liveness probe failed: connection refused",
+			want:      want{requests: []string{"DELETE /vms/test-vm"}, reason: v1alpha1.ReasonRecreating},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var requests []string
+			client := newMockOrchardClient(&mockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					requests = append(requests, req.Method+" "+req.URL.Path)
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString(""))}, nil
+				},
+			})
+			cr := &v1alpha1.VM{
+				ObjectMeta: metav1.ObjectMeta{Name: vmName},
+				Spec: v1alpha1.VMSpec{
+					ForProvider:    v1alpha1.VMParameters{Image: "ubuntu:22.04"},
+					UpdateStrategy: tc.strategy,
+				},
+				Status: v1alpha1.VMStatus{AtProvider: v1alpha1.VMObservation{IPAddress: "192.168.64.7"}},
+			}
+			meta.SetExternalName(cr, vmName)
+
+			e := &external{client: client, recorder: event.NewNopRecorder(), diff: tc.diff, reprovision: tc.reprovision, unhealthy: tc.unhealthy}
+			_, err := e.Update(context.Background(), cr)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Update(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.requests, requests); diff != "" {
+				t.Errorf("\n%s\ne.Update(...): -want requests, +got requests:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.reason, cr.GetCondition(v1alpha1.TypeSpecApplied).Reason); diff != "" {
+				t.Errorf("\n%s\ne.Update(...): -want SpecApplied reason, +got SpecApplied reason:\n%s\n", tc.reason, diff)
+			}
+			if tc.want.reason == v1alpha1.ReasonRecreating && cr.Status.AtProvider.IPAddress != "" {
+				t.Errorf("\n%s\ne.Update(...): want observation reset for the recreated VM, got IP %q", tc.reason, cr.Status.AtProvider.IPAddress)
+			}
+		})
+	}
+}
+
+func TestRecreate(t *testing.T) {
+	vmName := "test-vm"
+	var requests []string
+	status := http.StatusOK
+	client := newMockOrchardClient(&mockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			requests = append(requests, req.Method+" "+req.URL.Path)
+			body := ""
+			if req.Method == http.MethodGet {
+				body = `{"name":"test-vm","image":"ubuntu:20.04","status":"running"}`
+			}
+			return &http.Response{StatusCode: status, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+		},
+	})
+	cr := &v1alpha1.VM{
+		ObjectMeta: metav1.ObjectMeta{Name: vmName},
+		Spec: v1alpha1.VMSpec{
+			ForProvider:    v1alpha1.VMParameters{Image: "ubuntu:22.04"},
+			UpdateStrategy: v1alpha1.UpdateStrategyRecreate,
+		},
+		Status: v1alpha1.VMStatus{AtProvider: v1alpha1.VMObservation{
+			IPAddress:           "192.168.64.7",
+			ProvisioningLogsRef: &corev1.LocalObjectReference{Name: "test-vm-provisioning-logs"},
+			Outputs:             map[string]string{"runner_id": "1"},
+		}},
+	}
+	meta.SetExternalName(cr, vmName)
+	meta.SetExternalCreateSucceeded(cr, time.Now().Add(-time.Hour))
+	recorder := &recordingRecorder{}
+	e := &external{client: client, recorder: recorder, diff: []fieldDiff{{field: "image", message: "image changed"}}}
+
+	// Update deletes the old VM and keeps what outlives it
+	if _, err := e.Update(context.Background(), cr); err != nil {
+		t.Fatalf("e.Update(...): unexpected error: %v", err)
+	}
+	want := v1alpha1.VMObservation{
+		ProvisioningLogsRef: &corev1.LocalObjectReference{Name: "test-vm-provisioning-logs"},
+		Outputs:             map[string]string{"runner_id": "1"},
+	}
+	if diff := cmp.Diff(want, cr.Status.AtProvider); diff != "" {
+		t.Errorf("e.Update(...): -want observation, +got observation:\n%s\n", diff)
+	}
+
+	// Orchard still reports the old VM, which must be neither updated nor
+	// provisioned
+	if _, err := e.Observe(context.Background(), cr); err == nil {
+		t.Errorf("e.Observe(...): want an error while Orchard deletes the old VM")
+	}
+
+	// Updating again deletes the old VM again, without reporting it again
+	if _, err := e.Update(context.Background(), cr); err != nil {
+		t.Fatalf("e.Update(...): unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]event.Reason{reasonRecreateVM}, recorder.reasons()); diff != "" {
+		t.Errorf("e.Update(...): -want events, +got events:\n%s\n", diff)
+	}
+
+	// A conflict means the old VM is not gone yet, rather than that there is
+	// a VM to adopt
+	status = http.StatusConflict
+	if _, err := e.Create(context.Background(), cr); err == nil || err.Error() != fmt.Sprintf(errVMStillDeleting, vmName) {
+		t.Errorf("e.Create(...): want %q, got %v", fmt.Sprintf(errVMStillDeleting, vmName), err)
+	}
+
+	wantRequests := []string{"DELETE /vms/test-vm", "GET /vms/test-vm", "DELETE /vms/test-vm", "POST /vms"}
+	if diff := cmp.Diff(wantRequests, requests); diff != "" {
+		t.Errorf("-want requests, +got requests:\n%s\n", diff)
+	}
+
+	// Once created again, the VM is observed as usual
+	meta.SetExternalCreateSucceeded(cr, time.Now().Add(time.Second))
+	if recreatePending(cr) {
+		t.Errorf("recreatePending(...): want false once the VM was created again")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	vmName := "test-vm"
+	vmImage := "ubuntu:22.04"
+
+	type fields struct {
+		client *orchardclient.OrchardClient
+	}
+
+	type args struct {
+		ctx context.Context
+		mg  resource.Managed
+	}
+
+	type want struct {
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		fields fields
+		args   args
+		want   want
+	}{
+		"NoExternalName": {
+			reason: "Should return nil if external name is not set (nothing to delete)",
+			args: args{
+				ctx: context.Background(),
+				mg: &v1alpha1.VM{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: vmName,
+					},
+					Spec: v1alpha1.VMSpec{
+						ForProvider: v1alpha1.VMParameters{
+							Image: vmImage,
+						},
+					},
+				},
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"SuccessfulDelete": {
+			reason: "Should successfully delete VM",
+			fields: fields{
+				client: newMockOrchardClient(&mockHTTPClient{
+					DoFunc: func(req *http.Request) (*http.Response, error) {
+						return &http.Response{
+							StatusCode: http.StatusOK,
 							Body:       io.NopCloser(bytes.NewBufferString("")),
 						}, nil
 					},
@@ -807,7 +1344,7 @@ func TestDelete(t *testing.T) {
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			e := &external{client: tc.fields.client}
+			e := &external{client: tc.fields.client, recorder: event.NewNopRecorder()}
 			_, err := e.Delete(tc.args.ctx, tc.args.mg)
 			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
 				t.Errorf("\n%s\ne.Delete(...): -want error, +got error:\n%s\n", tc.reason, diff)
@@ -866,7 +1403,7 @@ func TestBuildVMSpec(t *testing.T) {
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			got := buildVMSpec(tc.params)
+			got := buildVMSpec(tc.params, sshCredentials{Username: tc.params.Username, Password: tc.params.Password})
 			if diff := cmp.Diff(tc.want.Image, got.Image); diff != "" {
 				t.Errorf("\n%s\nbuildVMSpec(...).Image: -want, +got:\n%s\n", tc.reason, diff)
 			}
@@ -952,6 +1489,289 @@ func TestIsVMUpToDate(t *testing.T) {
 			},
 			want: true,
 		},
+		"CPUUnsetIgnored": {
+			reason: "Should leave CPU to Orchard when it is not set",
+			params: &v1alpha1.VMParameters{
+				Image: vmImage,
+			},
+			vm: &orchardclient.VM{
+				Image: &vmImage,
+				Cpu:   ptr(float32(4)),
+			},
+			want: true,
+		},
+		"DiskSizeDiffers": {
+			reason: "Should return false when disk size differs",
+			params: &v1alpha1.VMParameters{
+				Image:    vmImage,
+				DiskSize: ptr(int32(100)),
+			},
+			vm: &orchardclient.VM{
+				Image:    &vmImage,
+				DiskSize: ptr(float32(50)),
+			},
+			want: false,
+		},
+		"DiskSizeNotReported": {
+			reason: "Should return false when a set disk size is not reported by Orchard",
+			params: &v1alpha1.VMParameters{
+				Image:    vmImage,
+				DiskSize: ptr(int32(100)),
+			},
+			vm: &orchardclient.VM{
+				Image: &vmImage,
+			},
+			want: false,
+		},
+		"HeadlessFalseMatchesUnset": {
+			reason: "Should treat a boolean Orchard omits as false",
+			params: &v1alpha1.VMParameters{
+				Image:       vmImage,
+				Headless:    ptr(false),
+				Nested:      ptr(false),
+				Suspendable: ptr(false),
+			},
+			vm: &orchardclient.VM{
+				Image: &vmImage,
+			},
+			want: true,
+		},
+		"HeadlessDiffers": {
+			reason: "Should return false when headless differs",
+			params: &v1alpha1.VMParameters{
+				Image:    vmImage,
+				Headless: ptr(true),
+			},
+			vm: &orchardclient.VM{
+				Image:    &vmImage,
+				Headless: ptr(false),
+			},
+			want: false,
+		},
+		"NestedDiffers": {
+			reason: "Should return false when nested virtualization differs",
+			params: &v1alpha1.VMParameters{
+				Image:  vmImage,
+				Nested: ptr(true),
+			},
+			vm: &orchardclient.VM{
+				Image: &vmImage,
+			},
+			want: false,
+		},
+		"SuspendableDiffers": {
+			reason: "Should return false when suspendable differs",
+			params: &v1alpha1.VMParameters{
+				Image:       vmImage,
+				Suspendable: ptr(false),
+			},
+			vm: &orchardclient.VM{
+				Image:       &vmImage,
+				Suspendable: ptr(true),
+			},
+			want: false,
+		},
+		"NetBridgedDiffers": {
+			reason: "Should return false when the bridged interface differs",
+			params: &v1alpha1.VMParameters{
+				Image:      vmImage,
+				NetBridged: ptr("en0"),
+			},
+			vm: &orchardclient.VM{
+				Image:      &vmImage,
+				NetBridged: ptr("en1"),
+			},
+			want: false,
+		},
+		"NetSoftnetDeprecatedField": {
+			reason: "Should accept softnet reported through the deprecated net-softnet field",
+			params: &v1alpha1.VMParameters{
+				Image:      vmImage,
+				NetSoftnet: ptr(true),
+			},
+			vm: &orchardclient.VM{
+				Image:                &vmImage,
+				NetSoftnetDeprecated: ptr(true),
+			},
+			want: true,
+		},
+		"SoftnetListsInAnyOrder": {
+			reason: "Should compare softnet allow and block lists without regard to order",
+			params: &v1alpha1.VMParameters{
+				Image:           vmImage,
+				NetSoftnetAllow: []string{"10.0.0.0/8", "192.168.0.0/16"},
+				NetSoftnetBlock: []string{"0.0.0.0/0"},
+			},
+			vm: &orchardclient.VM{
+				Image:           &vmImage,
+				NetSoftnetAllow: &[]string{"192.168.0.0/16", "10.0.0.0/8"},
+				NetSoftnetBlock: &[]string{"0.0.0.0/0"},
+			},
+			want: true,
+		},
+		"SoftnetAllowRemoved": {
+			reason: "Should return false when Orchard has a softnet allow entry the spec no longer has",
+			params: &v1alpha1.VMParameters{
+				Image:           vmImage,
+				NetSoftnetAllow: []string{"10.0.0.0/8"},
+			},
+			vm: &orchardclient.VM{
+				Image:           &vmImage,
+				NetSoftnetAllow: &[]string{"10.0.0.0/8", "192.168.0.0/16"},
+			},
+			want: false,
+		},
+		"EmptyListsMatchUnset": {
+			reason: "Should treat empty and unset lists and maps as equal",
+			params: &v1alpha1.VMParameters{
+				Image:           vmImage,
+				NetSoftnetBlock: []string{},
+				Labels:          map[string]string{},
+			},
+			vm: &orchardclient.VM{
+				Image:           &vmImage,
+				NetSoftnetAllow: &[]string{},
+				Resources:       &map[string]int{},
+			},
+			want: true,
+		},
+		"HostDirsInAnyOrder": {
+			reason: "Should compare host directories without regard to order, treating unset ro as false",
+			params: &v1alpha1.VMParameters{
+				Image: vmImage,
+				HostDirs: []v1alpha1.VMHostDir{
+					{Name: "cache", Path: "/opt/cache", Ro: ptr(true)},
+					{Name: "src", Path: "/Users/ci/src"},
+				},
+			},
+			vm: &orchardclient.VM{
+				Image: &vmImage,
+				HostDirs: &[]struct {
+					Name *string `json:"name,omitempty"`
+					Path *string `json:"path,omitempty"`
+					Ro   *bool   `json:"ro,omitempty"`
+				}{
+					{Name: ptr("src"), Path: ptr("/Users/ci/src"), Ro: ptr(false)},
+					{Name: ptr("cache"), Path: ptr("/opt/cache"), Ro: ptr(true)},
+				},
+			},
+			want: true,
+		},
+		"HostDirReadOnlyDiffers": {
+			reason: "Should return false when a host directory's ro flag differs",
+			params: &v1alpha1.VMParameters{
+				Image: vmImage,
+				HostDirs: []v1alpha1.VMHostDir{
+					{Name: "cache", Path: "/opt/cache"},
+				},
+			},
+			vm: &orchardclient.VM{
+				Image: &vmImage,
+				HostDirs: &[]struct {
+					Name *string `json:"name,omitempty"`
+					Path *string `json:"path,omitempty"`
+					Ro   *bool   `json:"ro,omitempty"`
+				}{
+					{Name: ptr("cache"), Path: ptr("/opt/cache"), Ro: ptr(true)},
+				},
+			},
+			want: false,
+		},
+		"LabelsMatch": {
+			reason: "Should return true when labels match",
+			params: &v1alpha1.VMParameters{
+				Image:  vmImage,
+				Labels: map[string]string{"arch": "arm64", "pool": "ci"},
+			},
+			vm: &orchardclient.VM{
+				Image:  &vmImage,
+				Labels: &map[string]string{"pool": "ci", "arch": "arm64"},
+			},
+			want: true,
+		},
+		"LabelsDiffer": {
+			reason: "Should return false when a label value differs",
+			params: &v1alpha1.VMParameters{
+				Image:  vmImage,
+				Labels: map[string]string{"pool": "ci"},
+			},
+			vm: &orchardclient.VM{
+				Image:  &vmImage,
+				Labels: &map[string]string{"pool": "release"},
+			},
+			want: false,
+		},
+		"ResourcesDiffer": {
+			reason: "Should return false when required resources differ",
+			params: &v1alpha1.VMParameters{
+				Image:     vmImage,
+				Resources: map[string]int{"org.cirruslabs.tart-vms": 1},
+			},
+			vm: &orchardclient.VM{
+				Image: &vmImage,
+			},
+			want: false,
+		},
+		"DefaultResourcesIgnored": {
+			reason: "Should ignore resources Orchard added that the spec does not set",
+			params: &v1alpha1.VMParameters{
+				Image:     vmImage,
+				Resources: map[string]int{"gpu": 1},
+			},
+			vm: &orchardclient.VM{
+				Image:     &vmImage,
+				Resources: &map[string]int{"gpu": 1, "org.cirruslabs.tart-vms": 1},
+			},
+			want: true,
+		},
+		"UnsetMapsIgnored": {
+			reason: "Should ignore labels and resources when the spec leaves them unset",
+			params: &v1alpha1.VMParameters{
+				Image: vmImage,
+			},
+			vm: &orchardclient.VM{
+				Image:     &vmImage,
+				Labels:    &map[string]string{"pool": "ci"},
+				Resources: &map[string]int{"org.cirruslabs.tart-vms": 1},
+			},
+			want: true,
+		},
+		"PoliciesUnsetIgnored": {
+			reason: "Should leave image pull and restart policies to Orchard when they are not set",
+			params: &v1alpha1.VMParameters{
+				Image: vmImage,
+			},
+			vm: &orchardclient.VM{
+				Image:           &vmImage,
+				ImagePullPolicy: ptr(orchardclient.VMImagePullPolicyIfNotPresent),
+				RestartPolicy:   ptr(orchardclient.VMRestartPolicyNever),
+			},
+			want: true,
+		},
+		"ImagePullPolicyDiffers": {
+			reason: "Should return false when the image pull policy differs",
+			params: &v1alpha1.VMParameters{
+				Image:           vmImage,
+				ImagePullPolicy: ptr("Always"),
+			},
+			vm: &orchardclient.VM{
+				Image:           &vmImage,
+				ImagePullPolicy: ptr(orchardclient.VMImagePullPolicyIfNotPresent),
+			},
+			want: false,
+		},
+		"RestartPolicyDiffers": {
+			reason: "Should return false when the restart policy differs",
+			params: &v1alpha1.VMParameters{
+				Image:         vmImage,
+				RestartPolicy: ptr("OnFailure"),
+			},
+			vm: &orchardclient.VM{
+				Image:         &vmImage,
+				RestartPolicy: ptr(orchardclient.VMRestartPolicyNever),
+			},
+			want: false,
+		},
 	}
 
 	for name, tc := range cases {
@@ -964,6 +1784,2792 @@ func TestIsVMUpToDate(t *testing.T) {
 	}
 }
 
-func ptr[T any](v T) *T {
-	return &v
+func TestLateInitialize(t *testing.T) {
+	vmImage := "ubuntu:22.04"
+
+	cases := map[string]struct {
+		reason  string
+		params  *v1alpha1.VMParameters
+		vm      *orchardclient.VM
+		want    *v1alpha1.VMParameters
+		changed bool
+	}{
+		"FillsOrchardDefaults": {
+			reason: "Should copy the values Orchard applied to unset fields",
+			params: &v1alpha1.VMParameters{Image: vmImage},
+			vm: &orchardclient.VM{
+				Image:           &vmImage,
+				Cpu:             ptr(float32(4)),
+				Memory:          ptr(float32(8192)),
+				DiskSize:        ptr(float32(50)),
+				Headless:        ptr(true),
+				ImagePullPolicy: ptr(orchardclient.VMImagePullPolicyIfNotPresent),
+				RestartPolicy:   ptr(orchardclient.VMRestartPolicyNever),
+				Labels:          &map[string]string{"pool": "ci"},
+				Username:        ptr("admin"),
+				Password:        ptr("admin"),
+			},
+			want: &v1alpha1.VMParameters{
+				Image:           vmImage,
+				CPU:             ptr(int32(4)),
+				Memory:          ptr(int32(8192)),
+				DiskSize:        ptr(int32(50)),
+				Headless:        ptr(true),
+				ImagePullPolicy: ptr("IfNotPresent"),
+				RestartPolicy:   ptr("Never"),
+				Labels:          map[string]string{"pool": "ci"},
+			},
+			changed: true,
+		},
+		"KeepsSetFields": {
+			reason: "Should never overwrite fields the spec sets",
+			params: &v1alpha1.VMParameters{
+				Image:         vmImage,
+				CPU:           ptr(int32(2)),
+				Headless:      ptr(false),
+				RestartPolicy: ptr("OnFailure"),
+			},
+			vm: &orchardclient.VM{
+				Image:         &vmImage,
+				Cpu:           ptr(float32(4)),
+				Headless:      ptr(true),
+				RestartPolicy: ptr(orchardclient.VMRestartPolicyNever),
+			},
+			want: &v1alpha1.VMParameters{
+				Image:         vmImage,
+				CPU:           ptr(int32(2)),
+				Headless:      ptr(false),
+				RestartPolicy: ptr("OnFailure"),
+			},
+			changed: false,
+		},
+		"KeepsEmptyLists": {
+			reason: "Should treat an explicitly empty list as set",
+			params: &v1alpha1.VMParameters{
+				Image:           vmImage,
+				NetSoftnetAllow: []string{},
+			},
+			vm: &orchardclient.VM{
+				Image:           &vmImage,
+				NetSoftnetAllow: &[]string{"10.0.0.0/8"},
+			},
+			want: &v1alpha1.VMParameters{
+				Image:           vmImage,
+				NetSoftnetAllow: []string{},
+			},
+			changed: false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			changed := lateInitialize(tc.params, tc.vm)
+			if diff := cmp.Diff(tc.want, tc.params); diff != "" {
+				t.Errorf("\n%s\nlateInitialize(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.changed, changed); diff != "" {
+				t.Errorf("\n%s\nlateInitialize(...): -want changed, +got changed:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestObserveLateInitializePolicy(t *testing.T) {
+	vmImage := "ubuntu:22.04"
+
+	cases := map[string]struct {
+		reason   string
+		policies xpv1.ManagementPolicies
+		want     bool
+	}{
+		"AllowedByDefault": {
+			reason:   "Should late-initialize with the default management policies",
+			policies: xpv1.ManagementPolicies{xpv1.ManagementActionAll},
+			want:     true,
+		},
+		"OptedOut": {
+			reason:   "Should not late-initialize when LateInitialize is not among the management policies",
+			policies: xpv1.ManagementPolicies{xpv1.ManagementActionObserve, xpv1.ManagementActionCreate, xpv1.ManagementActionUpdate, xpv1.ManagementActionDelete},
+			want:     false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			client := newMockOrchardClient(&mockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					body, _ := json.Marshal(orchardclient.VM{Image: &vmImage, DiskSize: ptr(float32(50))})
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBuffer(body))}, nil
+				},
+			})
+			cr := &v1alpha1.VM{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-vm"},
+				Spec:       v1alpha1.VMSpec{ForProvider: v1alpha1.VMParameters{Image: vmImage}},
+			}
+			cr.SetManagementPolicies(tc.policies)
+			meta.SetExternalName(cr, "test-vm")
+
+			e := &external{
+				client:   client,
+				recorder: event.NewNopRecorder(),
+				policies: managed.NewManagementPoliciesResolver(true, cr.GetManagementPolicies()),
+			}
+			got, err := e.Observe(context.Background(), cr)
+			if err != nil {
+				t.Fatalf("\n%s\ne.Observe(...): unexpected error: %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got.ResourceLateInitialized); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...).ResourceLateInitialized: -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want, cr.Spec.ForProvider.DiskSize != nil); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want diskSize set, +got diskSize set:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestResolveSSHCredentials(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	type want struct {
+		creds sshCredentials
+		err   error
+	}
+
+	cases := map[string]struct {
+		reason  string
+		kube    client.Client
+		params  v1alpha1.VMParameters
+		deleted bool
+		want    want
+	}{
+		"PlainValues": {
+			reason: "Should use the plain username and password when no secrets are referenced",
+			kube:   &test.MockClient{},
+			params: v1alpha1.VMParameters{Username: ptr("admin"), Password: ptr("admin")},
+			want:   want{creds: sshCredentials{Username: ptr("admin"), Password: ptr("admin")}},
+		},
+		"SecretRefsTakePrecedence": {
+			reason: "Should read the referenced secret keys in the VM's namespace instead of the plain values",
+			kube: &test.MockClient{
+				MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+					if key.Namespace != "team-a" || key.Name != "vm-ssh" {
+						return errBoom
+					}
+					obj.(*corev1.Secret).Data = map[string][]byte{"user": []byte("runner"), "pass": []byte("rotated")}
+					return nil
+				},
+			},
+			params: v1alpha1.VMParameters{
+				Password:          ptr("admin"),
+				UsernameSecretRef: &xpv1.LocalSecretKeySelector{LocalSecretReference: xpv1.LocalSecretReference{Name: "vm-ssh"}, Key: "user"},
+				PasswordSecretRef: &xpv1.LocalSecretKeySelector{LocalSecretReference: xpv1.LocalSecretReference{Name: "vm-ssh"}, Key: "pass"},
+			},
+			want: want{creds: sshCredentials{Username: ptr("runner"), Password: ptr("rotated")}},
+		},
+		"MissingKey": {
+			reason: "Should return an error when the referenced key does not exist",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(nil)},
+			params: v1alpha1.VMParameters{
+				PasswordSecretRef: &xpv1.LocalSecretKeySelector{LocalSecretReference: xpv1.LocalSecretReference{Name: "vm-ssh"}, Key: "pass"},
+			},
+			want: want{err: errors.Errorf("%s: key %q not found in secret %s/%s", errGetSSHSecret, "pass", "team-a", "vm-ssh")},
+		},
+		"GetSecretError": {
+			reason: "Should return an error when the referenced secret cannot be read",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+			params: v1alpha1.VMParameters{
+				UsernameSecretRef: &xpv1.LocalSecretKeySelector{LocalSecretReference: xpv1.LocalSecretReference{Name: "vm-ssh"}, Key: "user"},
+			},
+			want: want{err: errors.Wrap(errBoom, errGetSSHSecret)},
+		},
+		"SecretGone": {
+			reason: "Should return an error when the referenced secret does not exist",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(kerrors.NewNotFound(corev1.Resource("secrets"), "vm-ssh"))},
+			params: v1alpha1.VMParameters{
+				PasswordSecretRef: &xpv1.LocalSecretKeySelector{LocalSecretReference: xpv1.LocalSecretReference{Name: "vm-ssh"}, Key: "pass"},
+			},
+			want: want{err: errors.Wrap(kerrors.NewNotFound(corev1.Resource("secrets"), "vm-ssh"), errGetSSHSecret)},
+		},
+		"SecretGoneWhileDeleting": {
+			reason: "Should fall back to the guest profile's defaults when the secret of a VM being deleted is gone",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(kerrors.NewNotFound(corev1.Resource("secrets"), "vm-ssh"))},
+			params: v1alpha1.VMParameters{
+				Username:          ptr("runner"),
+				PasswordSecretRef: &xpv1.LocalSecretKeySelector{LocalSecretReference: xpv1.LocalSecretReference{Name: "vm-ssh"}, Key: "pass"},
+			},
+			deleted: true,
+			want:    want{creds: sshCredentials{Username: ptr("runner")}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := &v1alpha1.VM{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "build-1"},
+				Spec:       v1alpha1.VMSpec{ForProvider: tc.params},
+			}
+			if tc.deleted {
+				cr.SetDeletionTimestamp(&metav1.Time{Time: time.Now()})
+			}
+			got, err := resolveSSHCredentials(context.Background(), tc.kube, cr)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nresolveSSHCredentials(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.creds, got); diff != "" {
+				t.Errorf("\n%s\nresolveSSHCredentials(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestResolveStartupScript(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	objects := func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+		if key.Namespace != "team-a" {
+			return errBoom
+		}
+		switch o := obj.(type) {
+		case *corev1.ConfigMap:
+			if key.Name != "scripts" {
+				return kerrors.NewNotFound(corev1.Resource("configmaps"), key.Name)
+			}
+			o.Data = map[string]string{"setup.sh": "brew install go", "GOFLAGS": "-mod=mod", "not-a-var": "x"}
+		case *corev1.Secret:
+			if key.Name != "registry" {
+				return kerrors.NewNotFound(corev1.Resource("secrets"), key.Name)
+			}
+			o.Data = map[string][]byte{"token": []byte("s3cr3t")}
+		}
+		return nil
+	}
+	configMapKey := func(name, key string) *corev1.ConfigMapKeySelector {
+		return &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: name}, Key: key}
+	}
+	secretKey := func(name, key string) *corev1.SecretKeySelector {
+		return &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: name}, Key: key}
+	}
+
+	type want struct {
+		script *v1alpha1.VMStartupScript
+		err    error
+	}
+
+	cases := map[string]struct {
+		reason string
+		kube   client.Client
+		script *v1alpha1.VMStartupScript
+		want   want
+	}{
+		"NoStartupScript": {
+			reason: "Should resolve nothing when the VM has no startup script",
+			kube:   &test.MockClient{},
+			want:   want{script: nil},
+		},
+		"Inline": {
+			reason: "Should use inline script content and env as is",
+			kube:   &test.MockClient{},
+			script: &v1alpha1.VMStartupScript{ScriptContent: "echo hi", Env: map[string]string{"A": "1"}, VMScriptOptions: v1alpha1.VMScriptOptions{RunAs: "root"}},
+			want:   want{script: &v1alpha1.VMStartupScript{ScriptContent: "echo hi", Env: map[string]string{"A": "1"}, VMScriptOptions: v1alpha1.VMScriptOptions{RunAs: "root"}}},
+		},
+		"References": {
+			reason: "Should read the script and env from the referenced ConfigMaps and Secrets in the VM's namespace",
+			kube:   &test.MockClient{MockGet: objects},
+			script: &v1alpha1.VMStartupScript{
+				ScriptContent: "ignored",
+				ScriptFrom:    &v1alpha1.VMValueSource{ConfigMapKeyRef: configMapKey("scripts", "setup.sh")},
+				Env:           map[string]string{"GOFLAGS": "-v", "A": "1"},
+				EnvFrom: []corev1.EnvFromSource{
+					{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "scripts"}}},
+					{Prefix: "REGISTRY_", SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "registry"}}},
+				},
+				EnvVars: []v1alpha1.VMEnvVar{{Name: "A", ValueFrom: v1alpha1.VMValueSource{SecretKeyRef: secretKey("registry", "token")}}},
+			},
+			want: want{script: &v1alpha1.VMStartupScript{
+				ScriptContent: "brew install go",
+				Env: map[string]string{
+					"GOFLAGS":        "-v",
+					"REGISTRY_token": "s3cr3t",
+					"A":              "s3cr3t",
+				},
+			}},
+		},
+		"OptionalMissing": {
+			reason: "Should skip optional references to objects or keys that do not exist",
+			kube:   &test.MockClient{MockGet: objects},
+			script: &v1alpha1.VMStartupScript{
+				ScriptContent: "echo hi",
+				EnvFrom:       []corev1.EnvFromSource{{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "missing"}, Optional: ptr(true)}}},
+				EnvVars: []v1alpha1.VMEnvVar{{Name: "TOKEN", ValueFrom: v1alpha1.VMValueSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "registry"}, Key: "missing", Optional: ptr(true),
+				}}}},
+			},
+			want: want{script: &v1alpha1.VMStartupScript{ScriptContent: "echo hi"}},
+		},
+		"MissingKey": {
+			reason: "Should return an error when a referenced key does not exist",
+			kube:   &test.MockClient{MockGet: objects},
+			script: &v1alpha1.VMStartupScript{ScriptFrom: &v1alpha1.VMValueSource{SecretKeyRef: secretKey("registry", "script")}},
+			want:   want{err: errors.Errorf(errScriptKeyNotFound, "script", "Secret", "team-a", "registry")},
+		},
+		"GetConfigMapError": {
+			reason: "Should return an error when a referenced ConfigMap cannot be read",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+			script: &v1alpha1.VMStartupScript{ScriptFrom: &v1alpha1.VMValueSource{ConfigMapKeyRef: configMapKey("scripts", "setup.sh")}},
+			want:   want{err: errors.Wrap(errBoom, errGetScriptConfigMap)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := &v1alpha1.VM{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "build-1"},
+				Spec:       v1alpha1.VMSpec{ForProvider: v1alpha1.VMParameters{StartupScript: tc.script}},
+			}
+			got, err := resolveStartupScript(context.Background(), tc.kube, cr)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nresolveStartupScript(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.script, got); diff != "" {
+				t.Errorf("\n%s\nresolveStartupScript(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestVMNameFor(t *testing.T) {
+	long := strings.Repeat("a", 60)
+
+	cases := map[string]struct {
+		reason   string
+		cr       *v1alpha1.VM
+		strategy string
+		want     string
+	}{
+		"DefaultsToNamespacedName": {
+			reason: "Should prefix the name with the namespace when no strategy is configured",
+			cr:     &v1alpha1.VM{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "build-1"}},
+			want:   "team-a-build-1-7967d945",
+		},
+		"Name": {
+			reason:   "Should use the bare name with the Name strategy",
+			cr:       &v1alpha1.VM{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "build-1"}},
+			strategy: apisv1alpha1.VMNameStrategyName,
+			want:     "build-1",
+		},
+		"LongNameHashed": {
+			reason:   "Should shorten names over the limit with a hash of the full name",
+			cr:       &v1alpha1.VM{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: long}},
+			strategy: apisv1alpha1.VMNameStrategyNamespacedName,
+			want:     "team-a-" + long[:47] + "-9b0e6ba7",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := vmNameFor(tc.cr, tc.strategy)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nvmNameFor(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if len(got) > maxVMNameLength {
+				t.Errorf("\n%s\nvmNameFor(...): name %q exceeds %d characters", tc.reason, got, maxVMNameLength)
+			}
+		})
+	}
+}
+
+func TestVMNameForNamespaceBoundary(t *testing.T) {
+	a := vmNameFor(&v1alpha1.VM{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "b"}}, apisv1alpha1.VMNameStrategyNamespacedName)
+	b := vmNameFor(&v1alpha1.VM{ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "a-b"}}, apisv1alpha1.VMNameStrategyNamespacedName)
+	if a == b {
+		t.Errorf("vmNameFor(...): want distinct names for team-a/b and team/a-b, got %q twice", a)
+	}
+}
+
+func TestVMNameForGenerateName(t *testing.T) {
+	cr := &v1alpha1.VM{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", GenerateName: "build-", Name: "build-x7k2p"}}
+
+	a := vmNameFor(cr, apisv1alpha1.VMNameStrategyGenerateName)
+	b := vmNameFor(cr, apisv1alpha1.VMNameStrategyGenerateName)
+	if !strings.HasPrefix(a, "team-a-build-") || len(a) != len("team-a-build-")+vmNameGenerateLength {
+		t.Errorf("vmNameFor(...): want team-a-build- followed by %d random characters, got %q", vmNameGenerateLength, a)
+	}
+	if a == b {
+		t.Errorf("vmNameFor(...): want distinct generated names, got %q twice", a)
+	}
+}
+
+func TestShortenVMName(t *testing.T) {
+	a := shortenVMName(strings.Repeat("x", 70)+"a", maxVMNameLength)
+	b := shortenVMName(strings.Repeat("x", 70)+"b", maxVMNameLength)
+	if len(a) != maxVMNameLength || len(b) != maxVMNameLength {
+		t.Errorf("shortenVMName(...): want %d characters, got %d and %d", maxVMNameLength, len(a), len(b))
+	}
+	if a == b {
+		t.Errorf("shortenVMName(...): want distinct names for distinct inputs, got %q twice", a)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestWatchManager(t *testing.T) {
+	ndjson := func(types ...string) *http.Response {
+		var b bytes.Buffer
+		for _, typ := range types {
+			_ = json.NewEncoder(&b).Encode(watchEvent{Type: typ})
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(&b)}
+	}
+	status := func(code int) *http.Response {
+		return &http.Response{StatusCode: code, Body: io.NopCloser(bytes.NewBufferString(""))}
+	}
+
+	type want struct {
+		events   int
+		requests int
+		removed  bool
+	}
+
+	cases := map[string]struct {
+		reason    string
+		responses []*http.Response
+		want      want
+	}{
+		"StreamsChanges": {
+			reason:    "Should enqueue the VM for every notification and again when the stream drops, then reconnect",
+			responses: []*http.Response{ndjson("ADDED", "MODIFIED")},
+			want:      want{events: 3, requests: 2},
+		},
+		"VMDeleted": {
+			reason:    "Should stop watching once Orchard reports the VM deleted",
+			responses: []*http.Response{ndjson("MODIFIED", "DELETED")},
+			want:      want{events: 2, requests: 1, removed: true},
+		},
+		"VMNotFound": {
+			reason:    "Should stop watching a VM that does not exist",
+			responses: []*http.Response{status(http.StatusNotFound)},
+			want:      want{events: 1, requests: 1, removed: true},
+		},
+		"RetryAfterError": {
+			reason:    "Should fall back to polling and reopen the stream after an error",
+			responses: []*http.Response{status(http.StatusInternalServerError), ndjson("MODIFIED")},
+			want:      want{events: 3, requests: 3},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var requests atomic.Int32
+			client := newMockOrchardClient(&mockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					if req.URL.Query().Get("watch") != "true" {
+						t.Errorf("\n%s\nwant watch request, got %s", tc.reason, req.URL)
+					}
+					if n := int(requests.Add(1)); n <= len(tc.responses) {
+						return tc.responses[n-1], nil
+					}
+					// Hold the stream open until the watch is stopped
+					<-req.Context().Done()
+					return nil, req.Context().Err()
+				},
+			})
+
+			m := newWatchManager(logging.NewNopLogger())
+			m.minBackoff = time.Millisecond
+			m.ctx = ctx
+
+			cr := &v1alpha1.VM{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"}}
+			nn := types.NamespacedName{Namespace: "default", Name: "test"}
+			w := &vmWatch{vmName: "test-vm", cancel: cancel}
+			m.vms[nn] = w
+
+			done := make(chan struct{})
+			go func() {
+				m.run(ctx, client, nn, w)
+				close(done)
+			}()
+
+			for i := 0; i < tc.want.events; i++ {
+				select {
+				case e := <-m.events:
+					if e.Object.GetName() != cr.GetName() || e.Object.GetNamespace() != cr.GetNamespace() {
+						t.Errorf("\n%s\nm.run(...): enqueued %s/%s, want %s", tc.reason, e.Object.GetNamespace(), e.Object.GetName(), nn)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("\n%s\nm.run(...): got %d events, want %d", tc.reason, i, tc.want.events)
+				}
+			}
+
+			if !tc.want.removed {
+				// Wait for the reconnect before stopping the watch
+				deadline := time.Now().Add(5 * time.Second)
+				for int(requests.Load()) < tc.want.requests && time.Now().Before(deadline) {
+					time.Sleep(time.Millisecond)
+				}
+				cancel()
+			}
+			<-done
+
+			if diff := cmp.Diff(tc.want.requests, int(requests.Load())); diff != "" {
+				t.Errorf("\n%s\nm.run(...): -want requests, +got requests:\n%s\n", tc.reason, diff)
+			}
+			_, watched := m.vms[nn]
+			if tc.want.removed == watched {
+				t.Errorf("\n%s\nm.run(...): want removed %t, got watched %t", tc.reason, tc.want.removed, watched)
+			}
+		})
+	}
+}
+
+// retryLogger records the backoff of every dropped watch stream it logs.
+type retryLogger struct {
+	logging.Logger
+	mu      sync.Mutex
+	retries []time.Duration
+}
+
+func (l *retryLogger) Debug(msg string, keysAndValues ...any) {
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if d, ok := keysAndValues[i+1].(time.Duration); ok && keysAndValues[i] == "retry" {
+			l.mu.Lock()
+			l.retries = append(l.retries, d)
+			l.mu.Unlock()
+		}
+	}
+}
+
+func TestWatchBackoff(t *testing.T) {
+	ndjson := func() *http.Response {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString(`{"type":"MODIFIED"}` + "\n"))}
+	}
+	status := func(code int) *http.Response {
+		return &http.Response{StatusCode: code, Body: io.NopCloser(bytes.NewBufferString(""))}
+	}
+
+	cases := map[string]struct {
+		reason    string
+		responses []*http.Response
+		want      []time.Duration
+	}{
+		"StreamClosedTwice": {
+			reason:    "Should reopen streams Orchard closed after the minimum backoff",
+			responses: []*http.Response{ndjson(), ndjson()},
+			want:      []time.Duration{time.Millisecond, time.Millisecond},
+		},
+		"ResetAfterConnect": {
+			reason:    "Should back off exponentially while the stream fails, and reset the backoff once it connects",
+			responses: []*http.Response{status(http.StatusInternalServerError), status(http.StatusInternalServerError), ndjson(), ndjson()},
+			want:      []time.Duration{time.Millisecond, 2 * time.Millisecond, time.Millisecond, time.Millisecond},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var requests atomic.Int32
+			client := newMockOrchardClient(&mockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					if n := int(requests.Add(1)); n <= len(tc.responses) {
+						return tc.responses[n-1], nil
+					}
+					// Hold the stream open until the watch is stopped
+					<-req.Context().Done()
+					return nil, req.Context().Err()
+				},
+			})
+
+			log := &retryLogger{Logger: logging.NewNopLogger()}
+			m := newWatchManager(log)
+			m.minBackoff = time.Millisecond
+			m.maxBackoff = time.Hour
+			m.ctx = ctx
+
+			nn := types.NamespacedName{Namespace: "default", Name: "test"}
+			w := &vmWatch{vmName: "test-vm", cancel: cancel}
+			m.vms[nn] = w
+
+			done := make(chan struct{})
+			go func() {
+				m.run(ctx, client, nn, w)
+				close(done)
+			}()
+			go func() {
+				for {
+					select {
+					case <-m.events:
+					case <-done:
+						return
+					}
+				}
+			}()
+
+			deadline := time.Now().Add(5 * time.Second)
+			for int(requests.Load()) <= len(tc.responses) && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			cancel()
+			<-done
+
+			log.mu.Lock()
+			defer log.mu.Unlock()
+			if diff := cmp.Diff(tc.want, log.retries); diff != "" {
+				t.Errorf("\n%s\nm.run(...): -want backoff, +got backoff:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestWatchPollInterval(t *testing.T) {
+	m := newWatchManager(logging.NewNopLogger())
+	cr := &v1alpha1.VM{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"}}
+	w := &vmWatch{vmName: "test-vm", cancel: func() {}}
+	m.vms[types.NamespacedName{Namespace: "default", Name: "test"}] = w
+
+	if got := m.PollInterval(cr, time.Minute); got != time.Minute {
+		t.Errorf("PollInterval(...) while connecting: want %s, got %s", time.Minute, got)
+	}
+	w.streaming.Store(true)
+	if got := m.PollInterval(cr, time.Minute); got != watchResyncInterval {
+		t.Errorf("PollInterval(...) while streaming: want %s, got %s", watchResyncInterval, got)
+	}
+	if got := (*watchManager)(nil).PollInterval(cr, time.Minute); got != time.Minute {
+		t.Errorf("PollInterval(...) without watches: want %s, got %s", time.Minute, got)
+	}
+}
+
+// mockSession is an SSH session that runs scripts by path prefix.
+type mockSession struct {
+	// scripts maps script paths to the results of running them
+	scripts map[string]mockScript
+
+	// boots counts the VM's boots, if it may be rebooted. It is shared by
+	// every session to the VM.
+	boots *atomic.Int32
+
+	mu      sync.Mutex
+	ran     []string
+	options []ssh.ScriptOptions
+}
+
+func (s *mockSession) scriptsRun() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ran
+}
+
+type mockScript struct {
+	exitCode int
+	stdout   string
+	stderr   string
+	output   string
+	marked   bool
+	block    bool
+
+	// failures is how many runs exit with exitCode before the script
+	// succeeds; all runs do if it is zero
+	failures int
+
+	// err is returned instead of a result, e.g. a lost connection
+	err error
+}
+
+func (s *mockSession) ExecuteCommand(ctx context.Context, command string) (*ssh.CommandResult, error) {
+	if s.boots != nil {
+		switch command {
+		case guestProfileFor("").bootIDCommand:
+			return &ssh.CommandResult{Stdout: fmt.Sprintf("%d\n", s.boots.Load())}, nil
+		case guestProfileFor("").rebootCommand():
+			s.boots.Add(1)
+			return nil, errors.Wrap(ssh.ErrConnectionFailed, "connection reset")
+		}
+	}
+	fields := strings.Fields(command)
+	path := fields[len(fields)-1]
+	if !strings.HasPrefix(path, "/tmp/") {
+		// Readiness probe
+		return &ssh.CommandResult{}, nil
+	}
+	return s.run(ctx, path)
+}
+
+func (s *mockSession) ExecuteCommandAs(ctx context.Context, command string, _ ssh.SudoOptions) (*ssh.CommandResult, error) {
+	return s.ExecuteCommand(ctx, command)
+}
+
+func (s *mockSession) ExecuteScript(ctx context.Context, _ string, opts ssh.ScriptOptions) (*ssh.CommandResult, error) {
+	s.mu.Lock()
+	s.options = append(s.options, opts)
+	s.mu.Unlock()
+	return s.run(ctx, opts.PathPrefix)
+}
+
+func (s *mockSession) run(ctx context.Context, path string) (*ssh.CommandResult, error) {
+	s.mu.Lock()
+	runs := 0
+	for _, ran := range s.ran {
+		if ran == path {
+			runs++
+		}
+	}
+	s.ran = append(s.ran, path)
+	s.mu.Unlock()
+
+	script := s.scripts[path]
+	switch {
+	case script.block:
+		<-ctx.Done()
+		return nil, errors.Wrap(ssh.ErrTimeout, ctx.Err().Error())
+	case script.err != nil:
+		return nil, script.err
+	case script.failures > 0 && runs >= script.failures:
+		return &ssh.CommandResult{Stdout: script.stdout, Output: script.output, Marked: script.marked}, nil
+	}
+	return &ssh.CommandResult{ExitCode: script.exitCode, Stdout: script.stdout, Stderr: script.stderr, Output: script.output, Marked: script.marked}, nil
+}
+
+func (s *mockSession) UploadFile(_ context.Context, _ io.Reader, _ ssh.FileUploadOptions) error {
+	return nil
+}
+
+func (s *mockSession) UploadBytes(_ context.Context, _ []byte, _ ssh.FileUploadOptions) error {
+	return nil
+}
+
+func (s *mockSession) Close() error {
+	return nil
+}
+
+func TestHandleCloudInit(t *testing.T) {
+	step := func(name string) v1alpha1.VMProvisioningStep {
+		return v1alpha1.VMProvisioningStep{Name: name, ScriptContent: "echo " + name}
+	}
+	status := func(name, status string, exitCode *int32, generation int64) v1alpha1.VMProvisioningStepStatus {
+		st := v1alpha1.VMProvisioningStepStatus{Name: name, Status: status, ExitCode: exitCode, ObservedGeneration: generation}
+		if status != CloudInitStatusPending {
+			st.ContentHash = stepHash(step(name))
+			st.Attempts = 1
+		}
+		return st
+	}
+	hashOf := func(script string) string {
+		return stepHash(v1alpha1.VMProvisioningStep{ScriptContent: script})
+	}
+	withHash := func(st v1alpha1.VMProvisioningStepStatus, hash string) v1alpha1.VMProvisioningStepStatus {
+		st.ContentHash = hash
+		return st
+	}
+	legacy := func(st v1alpha1.VMProvisioningStepStatus) v1alpha1.VMProvisioningStepStatus {
+		// Steps carried over from before steps were tracked record no attempts
+		st.Attempts = 0
+		return st
+	}
+	path := func(i int) string { return guestProfileFor("").stepScriptPath(i) }
+	zero, one := ptr(int32(0)), ptr(int32(1))
+
+	type want struct {
+		ran         []string
+		steps       []v1alpha1.VMProvisioningStepStatus
+		cloudInit   string
+		ready       xpv1.ConditionReason
+		reprovision []string
+	}
+
+	cases := map[string]struct {
+		reason     string
+		params     v1alpha1.VMParameters
+		generation int64
+		observed   v1alpha1.VMObservation
+		scripts    map[string]mockScript
+		want       want
+	}{
+		"NoSteps": {
+			reason: "Should report a VM without provisioning steps as available",
+			want:   want{ready: xpv1.ReasonAvailable},
+		},
+		"RunsStepsInOrder": {
+			reason: "Should run the startup script and then each step in order",
+			params: v1alpha1.VMParameters{
+				StartupScript:     &v1alpha1.VMStartupScript{ScriptContent: "echo hi"},
+				ProvisioningSteps: []v1alpha1.VMProvisioningStep{step("a"), step("b")},
+			},
+			generation: 1,
+			want: want{
+				ran: []string{path(0), path(1), path(2)},
+				steps: []v1alpha1.VMProvisioningStepStatus{
+					withHash(status(StartupScriptStep, CloudInitStatusCompleted, zero, 1), hashOf("echo hi")),
+					status("a", CloudInitStatusCompleted, zero, 1),
+					status("b", CloudInitStatusCompleted, zero, 1),
+				},
+				cloudInit: CloudInitStatusCompleted,
+				ready:     xpv1.ReasonAvailable,
+			},
+		},
+		"AppliesUserDataFirst": {
+			reason: "Should apply the user data before the other steps",
+			params: v1alpha1.VMParameters{
+				UserData:          "#cloud-config\nruncmd:\n  - echo hi\n",
+				ProvisioningSteps: []v1alpha1.VMProvisioningStep{step("a")},
+			},
+			generation: 1,
+			want: want{
+				ran: []string{path(1)},
+				steps: []v1alpha1.VMProvisioningStepStatus{
+					withHash(status(UserDataStep, CloudInitStatusCompleted, zero, 1), hashOf("#cloud-config\nruncmd:\n  - echo hi\n")),
+					status("a", CloudInitStatusCompleted, zero, 1),
+				},
+				cloudInit: CloudInitStatusCompleted,
+				ready:     xpv1.ReasonAvailable,
+			},
+		},
+		"StopsAtFailedStep": {
+			reason: "Should not run the steps after a failed step",
+			params: v1alpha1.VMParameters{
+				ProvisioningSteps: []v1alpha1.VMProvisioningStep{step("a"), step("b"), step("c")},
+			},
+			generation: 1,
+			scripts:    map[string]mockScript{path(1): {exitCode: 1, stderr: "boom"}},
+			want: want{
+				ran: []string{path(0), path(1)},
+				steps: []v1alpha1.VMProvisioningStepStatus{
+					status("a", CloudInitStatusCompleted, zero, 1),
+					{Name: "b", Status: CloudInitStatusFailed, ExitCode: one, ObservedGeneration: 1, Message: "exit code 1: boom", ContentHash: stepHash(step("b")), Attempts: 1},
+					status("c", CloudInitStatusPending, nil, 0),
+				},
+				cloudInit: CloudInitStatusFailed,
+				ready:     xpv1.ReasonUnavailable,
+			},
+		},
+		"ContinueOnFailure": {
+			reason: "Should run the remaining steps after a failed step that allows continuing",
+			params: v1alpha1.VMParameters{
+				ProvisioningSteps: []v1alpha1.VMProvisioningStep{
+					{Name: "a", ScriptContent: "false", ContinueOnFailure: true},
+					step("b"),
+				},
+			},
+			generation: 1,
+			scripts:    map[string]mockScript{path(0): {exitCode: 1}},
+			want: want{
+				ran: []string{path(0), path(1)},
+				steps: []v1alpha1.VMProvisioningStepStatus{
+					{Name: "a", Status: CloudInitStatusFailed, ExitCode: one, ObservedGeneration: 1, Message: "exit code 1: ", ContentHash: hashOf("false"), Attempts: 1},
+					status("b", CloudInitStatusCompleted, zero, 1),
+				},
+				cloudInit: CloudInitStatusCompleted,
+				ready:     xpv1.ReasonAvailable,
+			},
+		},
+		"FailedStepBlocksUntilSpecChanges": {
+			reason: "Should not retry a failed step while the spec is unchanged",
+			params: v1alpha1.VMParameters{
+				ProvisioningSteps: []v1alpha1.VMProvisioningStep{step("a"), step("b")},
+			},
+			generation: 1,
+			observed: v1alpha1.VMObservation{ProvisioningSteps: []v1alpha1.VMProvisioningStepStatus{
+				status("a", CloudInitStatusCompleted, zero, 1),
+				status("b", CloudInitStatusFailed, one, 1),
+			}},
+			want: want{
+				steps: []v1alpha1.VMProvisioningStepStatus{
+					status("a", CloudInitStatusCompleted, zero, 1),
+					status("b", CloudInitStatusFailed, one, 1),
+				},
+				cloudInit: CloudInitStatusFailed,
+				ready:     xpv1.ReasonUnavailable,
+			},
+		},
+		"StaysBlockedOnUnrelatedChange": {
+			reason: "Should not rerun a failed step when only other parts of the spec changed",
+			params: v1alpha1.VMParameters{
+				ProvisioningSteps: []v1alpha1.VMProvisioningStep{step("a"), step("b"), step("c")},
+			},
+			generation: 2,
+			observed: v1alpha1.VMObservation{ProvisioningSteps: []v1alpha1.VMProvisioningStepStatus{
+				status("a", CloudInitStatusCompleted, zero, 1),
+				status("b", CloudInitStatusFailed, one, 1),
+				status("c", CloudInitStatusPending, nil, 0),
+			}},
+			want: want{
+				steps: []v1alpha1.VMProvisioningStepStatus{
+					status("a", CloudInitStatusCompleted, zero, 1),
+					status("b", CloudInitStatusFailed, one, 1),
+					status("c", CloudInitStatusPending, nil, 0),
+				},
+				cloudInit: CloudInitStatusFailed,
+				ready:     xpv1.ReasonUnavailable,
+			},
+		},
+		"ResumesFromFailedStep": {
+			reason: "Should resume from the failed step once its script changed, without rerunning completed steps",
+			params: v1alpha1.VMParameters{
+				ProvisioningSteps: []v1alpha1.VMProvisioningStep{step("a"), step("b"), step("c")},
+			},
+			generation: 2,
+			observed: v1alpha1.VMObservation{ProvisioningSteps: []v1alpha1.VMProvisioningStepStatus{
+				status("a", CloudInitStatusCompleted, zero, 1),
+				withHash(status("b", CloudInitStatusFailed, one, 1), hashOf("echo old")),
+				status("c", CloudInitStatusPending, nil, 0),
+			}},
+			want: want{
+				ran: []string{path(1), path(2)},
+				steps: []v1alpha1.VMProvisioningStepStatus{
+					status("a", CloudInitStatusCompleted, zero, 1),
+					status("b", CloudInitStatusCompleted, zero, 2),
+					status("c", CloudInitStatusCompleted, zero, 2),
+				},
+				cloudInit: CloudInitStatusCompleted,
+				ready:     xpv1.ReasonAvailable,
+			},
+		},
+		"Timeout": {
+			reason: "Should fail a step that runs longer than its timeout",
+			params: v1alpha1.VMParameters{
+				ProvisioningSteps: []v1alpha1.VMProvisioningStep{
+					{Name: "slow", ScriptContent: "sleep 60", Timeout: &metav1.Duration{Duration: time.Millisecond}},
+				},
+			},
+			generation: 1,
+			scripts:    map[string]mockScript{path(0): {block: true}},
+			want: want{
+				ran: []string{path(0)},
+				steps: []v1alpha1.VMProvisioningStepStatus{
+					{Name: "slow", Status: CloudInitStatusFailed, ObservedGeneration: 1, Message: "timed out after 1ms", ContentHash: hashOf("sleep 60"), Attempts: 1},
+				},
+				cloudInit: CloudInitStatusFailed,
+				ready:     xpv1.ReasonUnavailable,
+			},
+		},
+		"RetriesFailedStep": {
+			reason: "Should retry a failed step as its retry policy allows, recording the attempts",
+			params: v1alpha1.VMParameters{
+				ProvisioningSteps: []v1alpha1.VMProvisioningStep{step("a")},
+				RetryPolicy:       &v1alpha1.VMRetryPolicy{MaxAttempts: 3, Backoff: &metav1.Duration{Duration: time.Millisecond}},
+			},
+			generation: 1,
+			scripts:    map[string]mockScript{path(0): {exitCode: 1, failures: 2}},
+			want: want{
+				ran: []string{path(0), path(0), path(0)},
+				steps: []v1alpha1.VMProvisioningStepStatus{
+					func() v1alpha1.VMProvisioningStepStatus {
+						st := status("a", CloudInitStatusCompleted, zero, 1)
+						st.Attempts = 3
+						return st
+					}(),
+				},
+				cloudInit: CloudInitStatusCompleted,
+				ready:     xpv1.ReasonAvailable,
+			},
+		},
+		"StopsRetryingAfterMaxAttempts": {
+			reason: "Should fail a step once it used up its attempts",
+			params: v1alpha1.VMParameters{
+				ProvisioningSteps: []v1alpha1.VMProvisioningStep{{
+					Name: "a", ScriptContent: "echo a",
+					RetryPolicy: &v1alpha1.VMRetryPolicy{MaxAttempts: 2, Backoff: &metav1.Duration{Duration: time.Millisecond}},
+				}},
+			},
+			generation: 1,
+			scripts:    map[string]mockScript{path(0): {exitCode: 1, stderr: "boom"}},
+			want: want{
+				ran: []string{path(0), path(0)},
+				steps: []v1alpha1.VMProvisioningStepStatus{
+					{Name: "a", Status: CloudInitStatusFailed, ExitCode: one, ObservedGeneration: 1, Message: "exit code 1: boom", ContentHash: stepHash(step("a")), Attempts: 2},
+				},
+				cloudInit: CloudInitStatusFailed,
+				ready:     xpv1.ReasonUnavailable,
+			},
+		},
+		"DoesNotRetryOtherExitCodes": {
+			reason: "Should not retry a step whose exit code is not retryable",
+			params: v1alpha1.VMParameters{
+				ProvisioningSteps: []v1alpha1.VMProvisioningStep{step("a")},
+				RetryPolicy:       &v1alpha1.VMRetryPolicy{MaxAttempts: 3, RetryableExitCodes: []int32{75}, Backoff: &metav1.Duration{Duration: time.Millisecond}},
+			},
+			generation: 1,
+			scripts:    map[string]mockScript{path(0): {exitCode: 1, stderr: "boom"}},
+			want: want{
+				ran: []string{path(0)},
+				steps: []v1alpha1.VMProvisioningStepStatus{
+					{Name: "a", Status: CloudInitStatusFailed, ExitCode: one, ObservedGeneration: 1, Message: "exit code 1: boom", ContentHash: stepHash(step("a")), Attempts: 1},
+				},
+				cloudInit: CloudInitStatusFailed,
+				ready:     xpv1.ReasonUnavailable,
+			},
+		},
+		"CarriesOverLegacyStatus": {
+			reason: "Should not rerun a startup script that completed before steps were tracked",
+			params: v1alpha1.VMParameters{
+				StartupScript: &v1alpha1.VMStartupScript{ScriptContent: "echo hi"},
+			},
+			generation: 3,
+			observed:   v1alpha1.VMObservation{CloudInitStatus: CloudInitStatusCompleted},
+			want: want{
+				steps: []v1alpha1.VMProvisioningStepStatus{
+					legacy(withHash(status(StartupScriptStep, CloudInitStatusCompleted, nil, 3), hashOf("echo hi"))),
+				},
+				cloudInit: CloudInitStatusCompleted,
+				ready:     xpv1.ReasonAvailable,
+			},
+		},
+		"RerunsStepWithNewOptions": {
+			reason: "Should rerun a step whose script options changed after it ran",
+			params: v1alpha1.VMParameters{
+				ProvisioningSteps: []v1alpha1.VMProvisioningStep{
+					{Name: "a", ScriptContent: "echo a", VMScriptOptions: v1alpha1.VMScriptOptions{RunAs: "root"}},
+				},
+			},
+			generation: 2,
+			observed: v1alpha1.VMObservation{ProvisioningSteps: []v1alpha1.VMProvisioningStepStatus{
+				status("a", CloudInitStatusCompleted, zero, 1),
+			}},
+			want: want{
+				ran: []string{path(0)},
+				steps: []v1alpha1.VMProvisioningStepStatus{
+					withHash(status("a", CloudInitStatusCompleted, zero, 2), stepHash(v1alpha1.VMProvisioningStep{
+						ScriptContent: "echo a", VMScriptOptions: v1alpha1.VMScriptOptions{RunAs: "root"},
+					})),
+				},
+				cloudInit: CloudInitStatusCompleted,
+				ready:     xpv1.ReasonAvailable,
+			},
+		},
+		"RerunsChangedStep": {
+			reason: "Should rerun only the steps whose script changed after they ran",
+			params: v1alpha1.VMParameters{
+				ProvisioningSteps: []v1alpha1.VMProvisioningStep{step("a"), step("b")},
+			},
+			generation: 2,
+			observed: v1alpha1.VMObservation{ProvisioningSteps: []v1alpha1.VMProvisioningStepStatus{
+				withHash(status("a", CloudInitStatusCompleted, zero, 1), hashOf("echo old")),
+				status("b", CloudInitStatusCompleted, zero, 1),
+			}},
+			want: want{
+				ran: []string{path(0)},
+				steps: []v1alpha1.VMProvisioningStepStatus{
+					status("a", CloudInitStatusCompleted, zero, 2),
+					status("b", CloudInitStatusCompleted, zero, 1),
+				},
+				cloudInit: CloudInitStatusCompleted,
+				ready:     xpv1.ReasonAvailable,
+			},
+		},
+		"IgnoresChangedStep": {
+			reason: "Should keep the VM as provisioned and report the hash that ran when the policy is Ignore",
+			params: v1alpha1.VMParameters{
+				ProvisioningSteps: []v1alpha1.VMProvisioningStep{step("a")},
+				ReprovisionPolicy: v1alpha1.ReprovisionPolicyIgnore,
+			},
+			generation: 2,
+			observed: v1alpha1.VMObservation{ProvisioningSteps: []v1alpha1.VMProvisioningStepStatus{
+				withHash(status("a", CloudInitStatusCompleted, zero, 1), hashOf("echo old")),
+			}},
+			want: want{
+				steps: []v1alpha1.VMProvisioningStepStatus{
+					withHash(status("a", CloudInitStatusCompleted, zero, 1), hashOf("echo old")),
+				},
+				cloudInit: CloudInitStatusCompleted,
+				ready:     xpv1.ReasonAvailable,
+			},
+		},
+		"RecreatesOnChange": {
+			reason: "Should leave changed steps to be applied by recreating the VM when the policy is Recreate",
+			params: v1alpha1.VMParameters{
+				ProvisioningSteps: []v1alpha1.VMProvisioningStep{step("a")},
+				ReprovisionPolicy: v1alpha1.ReprovisionPolicyRecreate,
+			},
+			generation: 2,
+			observed: v1alpha1.VMObservation{
+				CloudInitStatus: CloudInitStatusCompleted,
+				ProvisioningSteps: []v1alpha1.VMProvisioningStepStatus{
+					withHash(status("a", CloudInitStatusCompleted, zero, 1), hashOf("echo old")),
+				},
+			},
+			want: want{
+				steps: []v1alpha1.VMProvisioningStepStatus{
+					withHash(status("a", CloudInitStatusCompleted, zero, 1), hashOf("echo old")),
+				},
+				cloudInit:   CloudInitStatusCompleted,
+				ready:       xpv1.ReasonCreating,
+				reprovision: []string{`provisioning step "a"`},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			session := &mockSession{scripts: tc.scripts}
+			cr := &v1alpha1.VM{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Generation: tc.generation},
+				Spec:       v1alpha1.VMSpec{ForProvider: tc.params},
+				Status:     v1alpha1.VMStatus{AtProvider: tc.observed},
+			}
+			p := startProvisioner(t, 1, func(_ context.Context, _ ssh.TunnelConfig) (ssh.VMSession, error) {
+				return session, nil
+			})
+			e := &external{recorder: event.NewNopRecorder(), provisioner: p, startup: tc.params.StartupScript}
+
+			if err := provision(t, e, cr); err != nil {
+				t.Fatalf("\n%s\ne.handleCloudInit(...): unexpected error: %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.ran, session.scriptsRun()); diff != "" {
+				t.Errorf("\n%s\ne.handleCloudInit(...): -want scripts run, +got scripts run:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.steps, cr.Status.AtProvider.ProvisioningSteps, cmpopts.IgnoreFields(v1alpha1.VMProvisioningStepStatus{}, "Duration", "FirstAttemptTime")); diff != "" {
+				t.Errorf("\n%s\ne.handleCloudInit(...): -want step status, +got step status:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.cloudInit, cr.Status.AtProvider.CloudInitStatus); diff != "" {
+				t.Errorf("\n%s\ne.handleCloudInit(...): -want cloud-init status, +got cloud-init status:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.ready, cr.GetCondition(xpv1.TypeReady).Reason); diff != "" {
+				t.Errorf("\n%s\ne.handleCloudInit(...): -want Ready reason, +got Ready reason:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.reprovision, e.reprovision); diff != "" {
+				t.Errorf("\n%s\ne.handleCloudInit(...): -want reprovision, +got reprovision:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// startProvisioner returns a running provisioner whose events are discarded
+// and whose logs are written to a mock client.
+func startProvisioner(t *testing.T, maxJobs int, newSession func(context.Context, ssh.TunnelConfig) (ssh.VMSession, error)) *provisioner {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	p := newProvisioner(test.NewMockClient(), event.NewNopRecorder(), maxJobs)
+	p.newSession = newSession
+	p.ctx = ctx
+	go func() {
+		for {
+			select {
+			case <-p.events:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// provision calls handleCloudInit until the VM has no provisioning job left,
+// waiting for each job to finish, like successive reconciles would.
+func provision(t *testing.T, e *external, cr *v1alpha1.VM) error {
+	t.Helper()
+
+	for {
+		err := e.handleCloudInit(cr)
+		if _, ok := e.provisioner.Job(cr); !ok {
+			return err
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for job, _ := e.provisioner.Job(cr); !job.Done; job, _ = e.provisioner.Job(cr) {
+			if time.Now().After(deadline) {
+				t.Fatal("provisioning job did not finish")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+// recordingRecorder records the reasons of the events it is sent.
+type recordingRecorder struct {
+	mu     sync.Mutex
+	events []event.Event
+}
+
+func (r *recordingRecorder) Event(_ runtime.Object, e event.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recordingRecorder) WithAnnotations(_ ...string) event.Recorder {
+	return r
+}
+
+func (r *recordingRecorder) reasons() []event.Reason {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reasons := make([]event.Reason, 0, len(r.events))
+	for _, e := range r.events {
+		reasons = append(reasons, e.Reason)
+	}
+	return reasons
+}
+
+func TestRetryDelay(t *testing.T) {
+	now := time.Now()
+	failed := func(attempts int32, exitCode int32) v1alpha1.VMProvisioningStepStatus {
+		return v1alpha1.VMProvisioningStepStatus{
+			Status:           CloudInitStatusFailed,
+			ExitCode:         &exitCode,
+			Attempts:         attempts,
+			FirstAttemptTime: &metav1.Time{Time: now.Add(-time.Minute)},
+		}
+	}
+	duration := func(d time.Duration) *metav1.Duration { return &metav1.Duration{Duration: d} }
+
+	type want struct {
+		delay time.Duration
+		retry bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		policy *v1alpha1.VMRetryPolicy
+		st     v1alpha1.VMProvisioningStepStatus
+		want   want
+	}{
+		"NoPolicy": {
+			reason: "Should not retry steps without a retry policy",
+			st:     failed(1, 1),
+			want:   want{retry: false},
+		},
+		"Defaults": {
+			reason: "Should retry after the default backoff when the policy sets nothing else",
+			policy: &v1alpha1.VMRetryPolicy{},
+			st:     failed(1, 1),
+			want:   want{delay: defaultRetryBackoff, retry: true},
+		},
+		"Exponential": {
+			reason: "Should double the backoff with every further attempt",
+			policy: &v1alpha1.VMRetryPolicy{MaxAttempts: 5, Backoff: duration(time.Second)},
+			st:     failed(3, 1),
+			want:   want{delay: 4 * time.Second, retry: true},
+		},
+		"MaxBackoff": {
+			reason: "Should cap the backoff",
+			policy: &v1alpha1.VMRetryPolicy{MaxAttempts: 10, Backoff: duration(time.Second), MaxBackoff: duration(5 * time.Second)},
+			st:     failed(8, 1),
+			want:   want{delay: 5 * time.Second, retry: true},
+		},
+		"MaxAttempts": {
+			reason: "Should not retry a step that used up its attempts",
+			policy: &v1alpha1.VMRetryPolicy{MaxAttempts: 2},
+			st:     failed(2, 1),
+			want:   want{retry: false},
+		},
+		"RetryableExitCode": {
+			reason: "Should retry a step that exited with a retryable exit code",
+			policy: &v1alpha1.VMRetryPolicy{RetryableExitCodes: []int32{75}, Backoff: duration(time.Second)},
+			st:     failed(1, 75),
+			want:   want{delay: time.Second, retry: true},
+		},
+		"OtherExitCode": {
+			reason: "Should not retry a step that exited with an exit code that is not retryable",
+			policy: &v1alpha1.VMRetryPolicy{RetryableExitCodes: []int32{75}},
+			st:     failed(1, 1),
+			want:   want{retry: false},
+		},
+		"Deadline": {
+			reason: "Should not retry a step whose retry would start after the deadline",
+			policy: &v1alpha1.VMRetryPolicy{Backoff: duration(time.Minute), Deadline: duration(90 * time.Second)},
+			st:     failed(1, 1),
+			want:   want{retry: false},
+		},
+		"NotFailed": {
+			reason: "Should not retry a step that did not fail",
+			policy: &v1alpha1.VMRetryPolicy{},
+			st:     v1alpha1.VMProvisioningStepStatus{Status: CloudInitStatusCompleted, Attempts: 1},
+			want:   want{retry: false},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			delay, retry := retryDelay(tc.policy, tc.st, now)
+			if diff := cmp.Diff(tc.want, want{delay: delay, retry: retry}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nretryDelay(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestProvisioner(t *testing.T) {
+	block := map[string]mockScript{guestProfileFor("").stepScriptPath(0): {block: true}}
+	newSession := func(_ context.Context, _ ssh.TunnelConfig) (ssh.VMSession, error) {
+		return &mockSession{scripts: block}, nil
+	}
+	vm := func(name string) *v1alpha1.VM {
+		return &v1alpha1.VM{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Generation: 1},
+			Spec: v1alpha1.VMSpec{ForProvider: v1alpha1.VMParameters{
+				ProvisioningSteps: []v1alpha1.VMProvisioningStep{{Name: "xcode", ScriptContent: "sleep 1200"}},
+			}},
+		}
+	}
+
+	t.Run("ConcurrencyLimit", func(t *testing.T) {
+		p := startProvisioner(t, 1, newSession)
+		e := &external{recorder: event.NewNopRecorder(), provisioner: p}
+		first, second := vm("first"), vm("second")
+
+		if err := e.handleCloudInit(first); err != nil {
+			t.Fatalf("e.handleCloudInit(...): unexpected error: %v", err)
+		}
+
+		// Wait for the first job to take the only slot and start its step
+		deadline := time.Now().Add(5 * time.Second)
+		for job, _ := p.Job(first); job.Current == ""; job, _ = p.Job(first) {
+			if time.Now().After(deadline) {
+				t.Fatal("first job did not start")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if err := e.handleCloudInit(second); err != nil {
+			t.Fatalf("e.handleCloudInit(...): unexpected error: %v", err)
+		}
+
+		for _, cr := range []*v1alpha1.VM{first, second} {
+			if err := e.handleCloudInit(cr); err != nil {
+				t.Fatalf("e.handleCloudInit(...): unexpected error: %v", err)
+			}
+		}
+		if diff := cmp.Diff(CloudInitStatusRunning, first.Status.AtProvider.CloudInitStatus); diff != "" {
+			t.Errorf("first VM: -want cloud-init status, +got cloud-init status:\n%s\n", diff)
+		}
+		if diff := cmp.Diff(`running step "xcode"`, first.Status.AtProvider.CloudInitMessage); diff != "" {
+			t.Errorf("first VM: -want cloud-init message, +got cloud-init message:\n%s\n", diff)
+		}
+		if diff := cmp.Diff(CloudInitStatusRunning, first.Status.AtProvider.ProvisioningSteps[0].Status); diff != "" {
+			t.Errorf("first VM: -want step status, +got step status:\n%s\n", diff)
+		}
+		if diff := cmp.Diff(CloudInitStatusPending, second.Status.AtProvider.CloudInitStatus); diff != "" {
+			t.Errorf("second VM: -want cloud-init status, +got cloud-init status:\n%s\n", diff)
+		}
+		if diff := cmp.Diff("waiting for a free provisioning slot", second.Status.AtProvider.CloudInitMessage); diff != "" {
+			t.Errorf("second VM: -want cloud-init message, +got cloud-init message:\n%s\n", diff)
+		}
+	})
+
+	t.Run("SSHNotReady", func(t *testing.T) {
+		p := startProvisioner(t, 1, func(_ context.Context, _ ssh.TunnelConfig) (ssh.VMSession, error) {
+			return nil, ssh.ErrVMNotReady
+		})
+		e := &external{recorder: event.NewNopRecorder(), provisioner: p}
+		cr := vm("test")
+
+		err := provision(t, e, cr)
+		if diff := cmp.Diff(errors.Wrap(ssh.ErrVMNotReady, errSSHNotReady), err, test.EquateErrors()); diff != "" {
+			t.Errorf("provision(...): -want error, +got error:\n%s\n", diff)
+		}
+		if diff := cmp.Diff("waiting for SSH", cr.Status.AtProvider.CloudInitMessage); diff != "" {
+			t.Errorf("provision(...): -want cloud-init message, +got cloud-init message:\n%s\n", diff)
+		}
+	})
+
+	t.Run("ScriptOptions", func(t *testing.T) {
+		session := &mockSession{}
+		p := startProvisioner(t, 1, func(_ context.Context, _ ssh.TunnelConfig) (ssh.VMSession, error) {
+			return session, nil
+		})
+		startup := &v1alpha1.VMStartupScript{
+			ScriptContent:   "print('hi')",
+			Env:             map[string]string{"A": "1"},
+			VMScriptOptions: v1alpha1.VMScriptOptions{Interpreter: "/usr/bin/env python3", WorkingDir: "/opt", RunAs: "root"},
+		}
+		e := &external{recorder: event.NewNopRecorder(), provisioner: p, startup: startup}
+		cr := vm("test")
+		cr.Spec.ForProvider.ProvisioningSteps = nil
+
+		if err := provision(t, e, cr); err != nil {
+			t.Fatalf("provision(...): unexpected error: %v", err)
+		}
+		want := []ssh.ScriptOptions{{
+			Env:         map[string]string{"A": "1"},
+			Interpreter: "/usr/bin/env python3",
+			WorkingDir:  "/opt",
+			RunAs:       "root",
+			Escalation:  guestProfileFor("").escalation,
+			PathPrefix:  guestProfileFor("").stepScriptPath(0),
+			OutputVar:   scriptOutputVar,
+			MarkerVar:   rebootMarkerVar,
+		}}
+		if diff := cmp.Diff(want, session.options); diff != "" {
+			t.Errorf("provision(...): -want script options, +got script options:\n%s\n", diff)
+		}
+	})
+
+	t.Run("PersistsLogs", func(t *testing.T) {
+		output := strings.Repeat("x", maxStepLogLen) + "done\n"
+		p := startProvisioner(t, 1, func(_ context.Context, _ ssh.TunnelConfig) (ssh.VMSession, error) {
+			return &mockSession{scripts: map[string]mockScript{
+				guestProfileFor("").stepScriptPath(0): {stdout: "installed\n"},
+				guestProfileFor("").stepScriptPath(1): {exitCode: 2, stdout: output, stderr: "error: disk full\n"},
+			}}, nil
+		})
+		var written *corev1.ConfigMap
+		write := func(obj client.Object) error {
+			written = obj.(*corev1.ConfigMap).DeepCopy()
+			return nil
+		}
+		p.kube = &test.MockClient{
+			MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+				if written == nil {
+					return kerrors.NewNotFound(corev1.Resource("configmaps"), key.Name)
+				}
+				written.DeepCopyInto(obj.(*corev1.ConfigMap))
+				return nil
+			},
+			MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error { return write(obj) },
+			MockUpdate: func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error { return write(obj) },
+		}
+		recorder := &recordingRecorder{}
+		p.recorder = recorder
+		e := &external{recorder: event.NewNopRecorder(), provisioner: p}
+		cr := vm("test")
+		cr.SetUID("test-uid")
+		cr.Spec.ForProvider.ProvisioningSteps = []v1alpha1.VMProvisioningStep{
+			{Name: "install", ScriptContent: "echo installed"},
+			{Name: "configure app", ScriptContent: "exit 2"},
+		}
+
+		if err := provision(t, e, cr); err != nil {
+			t.Fatalf("provision(...): unexpected error: %v", err)
+		}
+
+		if diff := cmp.Diff(&corev1.LocalObjectReference{Name: "test-provisioning-logs"}, cr.Status.AtProvider.ProvisioningLogsRef); diff != "" {
+			t.Errorf("provision(...): -want logs reference, +got logs reference:\n%s\n", diff)
+		}
+		if written == nil {
+			t.Fatal("provision(...): logs ConfigMap was not written")
+		}
+		if diff := cmp.Diff("installed\n", written.Data["install.log"]); diff != "" {
+			t.Errorf("provision(...): -want install log, +got install log:\n%s\n", diff)
+		}
+		wantLog := capLog(output+"\n--- stderr ---\nerror: disk full\n", maxStepLogLen)
+		if diff := cmp.Diff(wantLog, written.Data["configure_app.log"]); diff != "" {
+			t.Errorf("provision(...): -want capped configure log, +got capped configure log:\n%s\n", diff)
+		}
+		if !strings.HasPrefix(written.Data["configure_app.log"], "[") || !strings.HasSuffix(written.Data["configure_app.log"], "error: disk full\n") {
+			t.Errorf("provision(...): configure log was not capped to its last %d bytes", maxStepLogLen)
+		}
+		if owner := written.GetOwnerReferences(); len(owner) != 1 || owner[0].UID != "test-uid" {
+			t.Errorf("provision(...): logs ConfigMap is not owned by the VM: %v", owner)
+		}
+		if diff := cmp.Diff(`step "configure app" failed: exit code 2: error: disk full`, cr.Status.AtProvider.CloudInitMessage); diff != "" {
+			t.Errorf("provision(...): -want cloud-init message, +got cloud-init message:\n%s\n", diff)
+		}
+
+		want := []event.Reason{
+			reasonProvisioningStepStarted, reasonProvisioningStepCompleted,
+			reasonProvisioningStepStarted, reasonProvisioningStepFailed,
+		}
+		if diff := cmp.Diff(want, recorder.reasons()); diff != "" {
+			t.Errorf("provision(...): -want events, +got events:\n%s\n", diff)
+		}
+	})
+
+	t.Run("PublishesOutputs", func(t *testing.T) {
+		p := startProvisioner(t, 1, func(_ context.Context, _ ssh.TunnelConfig) (ssh.VMSession, error) {
+			return &mockSession{scripts: map[string]mockScript{
+				guestProfileFor("").stepScriptPath(0): {output: "runner_id=1\ntoken=s3cr3t\n"},
+				guestProfileFor("").stepScriptPath(1): {output: "runner_id=2\nurl=http://10.0.0.5:8080\n"},
+			}}, nil
+		})
+		var written *corev1.Secret
+		write := func(obj client.Object) error {
+			if s, ok := obj.(*corev1.Secret); ok {
+				written = s.DeepCopy()
+			}
+			return nil
+		}
+		p.kube = &test.MockClient{
+			MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+				s, ok := obj.(*corev1.Secret)
+				if !ok || written == nil {
+					return kerrors.NewNotFound(corev1.Resource("secrets"), key.Name)
+				}
+				written.DeepCopyInto(s)
+				return nil
+			},
+			MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error { return write(obj) },
+			MockUpdate: func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error { return write(obj) },
+		}
+		e := &external{recorder: event.NewNopRecorder(), provisioner: p}
+		cr := vm("test")
+		cr.SetUID("test-uid")
+		cr.Spec.ForProvider.SensitiveOutputs = []string{"token"}
+		cr.Spec.ForProvider.ProvisioningSteps = []v1alpha1.VMProvisioningStep{
+			{Name: "register", ScriptContent: "register-runner"},
+			{Name: "serve", ScriptContent: "start-server"},
+		}
+
+		if err := provision(t, e, cr); err != nil {
+			t.Fatalf("provision(...): unexpected error: %v", err)
+		}
+		want := map[string]string{"runner_id": "2", "url": "http://10.0.0.5:8080"}
+		if diff := cmp.Diff(want, cr.Status.AtProvider.Outputs); diff != "" {
+			t.Errorf("provision(...): -want outputs, +got outputs:\n%s\n", diff)
+		}
+		if written == nil {
+			t.Fatal("provision(...): outputs Secret was not written")
+		}
+		if diff := cmp.Diff("test-script-outputs", written.GetName()); diff != "" {
+			t.Errorf("provision(...): -want outputs Secret name, +got outputs Secret name:\n%s\n", diff)
+		}
+		if owner := written.GetOwnerReferences(); len(owner) != 1 || owner[0].UID != "test-uid" {
+			t.Errorf("provision(...): outputs Secret is not owned by the VM: %v", owner)
+		}
+
+		outputs, err := readSensitiveOutputs(context.Background(), p.kube, cr)
+		if err != nil {
+			t.Fatalf("readSensitiveOutputs(...): unexpected error: %v", err)
+		}
+		e.outputs = outputs
+		details := e.connectionDetails(cr)
+		if diff := cmp.Diff("s3cr3t", string(details["token"])); diff != "" {
+			t.Errorf("connectionDetails(...): -want token, +got token:\n%s\n", diff)
+		}
+		if _, ok := details["runner_id"]; ok {
+			t.Errorf("connectionDetails(...): published output not marked sensitive")
+		}
+	})
+
+	t.Run("InvalidOutputs", func(t *testing.T) {
+		p := startProvisioner(t, 1, func(_ context.Context, _ ssh.TunnelConfig) (ssh.VMSession, error) {
+			return &mockSession{scripts: map[string]mockScript{guestProfileFor("").stepScriptPath(0): {output: "not an output\n"}}}, nil
+		})
+		e := &external{recorder: event.NewNopRecorder(), provisioner: p}
+		cr := vm("test")
+
+		if err := provision(t, e, cr); err != nil {
+			t.Fatalf("provision(...): unexpected error: %v", err)
+		}
+		st := cr.Status.AtProvider.ProvisioningSteps[0]
+		if diff := cmp.Diff(CloudInitStatusFailed, st.Status); diff != "" {
+			t.Errorf("provision(...): -want step status, +got step status:\n%s\n", diff)
+		}
+		if diff := cmp.Diff(fmt.Sprintf(errInvalidOutputLine, 1), st.Message); diff != "" {
+			t.Errorf("provision(...): -want step message, +got step message:\n%s\n", diff)
+		}
+	})
+
+	t.Run("ConnectionLost", func(t *testing.T) {
+		lost := errors.Wrap(ssh.ErrConnectionFailed, "connection reset")
+		p := startProvisioner(t, 1, func(_ context.Context, _ ssh.TunnelConfig) (ssh.VMSession, error) {
+			return &mockSession{scripts: map[string]mockScript{guestProfileFor("").stepScriptPath(0): {err: lost}}}, nil
+		})
+		e := &external{recorder: event.NewNopRecorder(), provisioner: p}
+		cr := vm("test")
+		cr.Spec.ForProvider.ProvisioningSteps[0].ScriptContent = "echo hi"
+
+		err := provision(t, e, cr)
+		if diff := cmp.Diff(errors.Wrap(lost, errSSHNotReady), err, test.EquateErrors()); diff != "" {
+			t.Errorf("provision(...): -want error, +got error:\n%s\n", diff)
+		}
+		want := []v1alpha1.VMProvisioningStepStatus{{Name: "xcode", Status: CloudInitStatusPending, Message: lost.Error()}}
+		if diff := cmp.Diff(want, cr.Status.AtProvider.ProvisioningSteps); diff != "" {
+			t.Errorf("provision(...): a lost connection should leave the step pending without counting an attempt: -want, +got:\n%s\n", diff)
+		}
+	})
+
+	t.Run("RebootsWhenRequested", func(t *testing.T) {
+		defer func(d time.Duration) { rebootPollInterval = d }(rebootPollInterval)
+		rebootPollInterval = time.Millisecond
+
+		boots := &atomic.Int32{}
+		session := &mockSession{boots: boots, scripts: map[string]mockScript{
+			guestProfileFor("").stepScriptPath(0): {exitCode: RebootExitCode},
+			guestProfileFor("").stepScriptPath(1): {marked: true},
+			guestProfileFor("").stepScriptPath(2): {exitCode: 1, marked: true},
+		}}
+		p := startProvisioner(t, 1, func(_ context.Context, _ ssh.TunnelConfig) (ssh.VMSession, error) {
+			return session, nil
+		})
+		recorder := &recordingRecorder{}
+		p.recorder = recorder
+		e := &external{recorder: event.NewNopRecorder(), provisioner: p}
+		cr := vm("test")
+		cr.Spec.ForProvider.ProvisioningSteps = []v1alpha1.VMProvisioningStep{
+			{Name: "install-clt", ScriptContent: "exit 194"},
+			{Name: "enable-policy", ScriptContent: "touch \"$ORCHARD_REBOOT_MARKER\""},
+			{Name: "fail", ScriptContent: "exit 1", ContinueOnFailure: true},
+		}
+
+		if err := provision(t, e, cr); err != nil {
+			t.Fatalf("provision(...): unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(int32(2), boots.Load()); diff != "" {
+			t.Errorf("provision(...): -want reboots, +got reboots:\n%s\n", diff)
+		}
+		var got []string
+		for _, st := range cr.Status.AtProvider.ProvisioningSteps {
+			got = append(got, st.Status+": "+st.Message)
+		}
+		want := []string{
+			CloudInitStatusCompleted + ": requested a reboot",
+			CloudInitStatusCompleted + ": requested a reboot",
+			CloudInitStatusFailed + ": exit code 1: ",
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("provision(...): -want step statuses, +got step statuses:\n%s\n", diff)
+		}
+		wantEvents := []event.Reason{
+			reasonProvisioningStepStarted, reasonProvisioningStepCompleted, reasonProvisioningReboot,
+			reasonProvisioningStepStarted, reasonProvisioningStepCompleted, reasonProvisioningReboot,
+			reasonProvisioningStepStarted, reasonProvisioningStepFailed,
+		}
+		if diff := cmp.Diff(wantEvents, recorder.reasons()); diff != "" {
+			t.Errorf("provision(...): -want events, +got events:\n%s\n", diff)
+		}
+	})
+
+	t.Run("RebootTimeout", func(t *testing.T) {
+		defer func(d time.Duration) { rebootPollInterval = d }(rebootPollInterval)
+		rebootPollInterval = time.Millisecond
+
+		// Every session sees a boot count of its own, so the VM never seems
+		// to come back
+		scripts := map[string]mockScript{guestProfileFor("").stepScriptPath(0): {exitCode: RebootExitCode}}
+		p := startProvisioner(t, 1, func(_ context.Context, _ ssh.TunnelConfig) (ssh.VMSession, error) {
+			return &mockSession{boots: &atomic.Int32{}, scripts: scripts}, nil
+		})
+		e := &external{recorder: event.NewNopRecorder(), provisioner: p}
+		cr := vm("test")
+		cr.Spec.ForProvider.RebootTimeout = &metav1.Duration{Duration: 20 * time.Millisecond}
+		cr.Spec.ForProvider.ProvisioningSteps = []v1alpha1.VMProvisioningStep{
+			{Name: "install-clt", ScriptContent: "exit 194"},
+			{Name: "configure", ScriptContent: "echo hi"},
+		}
+
+		err := provision(t, e, cr)
+		want := errors.Wrap(errors.Wrap(ssh.ErrTimeout, "VM did not come back after rebooting"), errRebootVM)
+		if diff := cmp.Diff(want, err, test.EquateErrors()); diff != "" {
+			t.Errorf("provision(...): -want error, +got error:\n%s\n", diff)
+		}
+		got := []string{cr.Status.AtProvider.ProvisioningSteps[0].Status, cr.Status.AtProvider.ProvisioningSteps[1].Status}
+		if diff := cmp.Diff([]string{CloudInitStatusCompleted, CloudInitStatusPending}, got); diff != "" {
+			t.Errorf("provision(...): the step that requested the reboot should stay completed: -want, +got:\n%s\n", diff)
+		}
+	})
+
+	t.Run("ForgetCancelsJob", func(t *testing.T) {
+		p := startProvisioner(t, 1, newSession)
+		e := &external{recorder: event.NewNopRecorder(), provisioner: p}
+		cr := vm("test")
+
+		if err := e.handleCloudInit(cr); err != nil {
+			t.Fatalf("e.handleCloudInit(...): unexpected error: %v", err)
+		}
+		p.Forget(cr)
+		if _, ok := p.Job(cr); ok {
+			t.Errorf("p.Forget(...): job still tracked")
+		}
+	})
+}
+
+func TestWriteStepLog(t *testing.T) {
+	cr := &v1alpha1.VM{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test", UID: "test-uid"}}
+	owned := func(data map[string]string) *corev1.ConfigMap {
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-provisioning-logs", ResourceVersion: "1"}, Data: data}
+		meta.AddOwnerReference(cm, meta.AsController(meta.TypedReferenceTo(cr, v1alpha1.VMGroupVersionKind)))
+		return cm
+	}
+	full := strings.Repeat("x", maxProvisioningLogsLen-len("a.log")-len("b.log")-10)
+
+	type want struct {
+		log string
+		err error
+	}
+
+	cases := map[string]struct {
+		reason   string
+		existing *corev1.ConfigMap
+		log      string
+		want     want
+	}{
+		"Create": {
+			reason: "Should create the ConfigMap holding the VM's provisioning logs",
+			log:    "installed\n",
+			want:   want{log: "installed\n"},
+		},
+		"Update": {
+			reason:   "Should add the step's log to the ConfigMap the VM controls",
+			existing: owned(map[string]string{"a.log": "done"}),
+			log:      "installed\n",
+			want:     want{log: "installed\n"},
+		},
+		"TotalSizeCapped": {
+			reason:   "Should keep only the end of a step's log once the logs of all steps reach their cap",
+			existing: owned(map[string]string{"a.log": full}),
+			log:      "installed\n",
+			want:     want{log: capLog("installed\n", 10)},
+		},
+		"NotControlled": {
+			reason:   "Should refuse to overwrite a ConfigMap of the same name the VM does not control",
+			existing: &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-provisioning-logs", ResourceVersion: "1"}},
+			log:      "installed\n",
+			want:     want{err: errors.Wrap(errors.Errorf(errProvisioningLogsNotOwned, "default", "test-provisioning-logs"), errWriteProvisioningLogs)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var written *corev1.ConfigMap
+			write := func(obj client.Object) error {
+				written = obj.(*corev1.ConfigMap).DeepCopy()
+				return nil
+			}
+			kube := &test.MockClient{
+				MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+					if tc.existing == nil {
+						return kerrors.NewNotFound(corev1.Resource("configmaps"), key.Name)
+					}
+					tc.existing.DeepCopyInto(obj.(*corev1.ConfigMap))
+					return nil
+				},
+				MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error { return write(obj) },
+				MockUpdate: func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error { return write(obj) },
+			}
+
+			err := writeStepLog(context.Background(), kube, cr, "b", tc.log)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nwriteStepLog(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if tc.want.err != nil {
+				if written != nil {
+					t.Errorf("\n%s\nwriteStepLog(...): wrote a ConfigMap the VM does not control", tc.reason)
+				}
+				return
+			}
+			if diff := cmp.Diff(tc.want.log, written.Data["b.log"]); diff != "" {
+				t.Errorf("\n%s\nwriteStepLog(...): -want log, +got log:\n%s\n", tc.reason, diff)
+			}
+			if !metav1.IsControlledBy(written, cr) {
+				t.Errorf("\n%s\nwriteStepLog(...): logs ConfigMap is not controlled by the VM", tc.reason)
+			}
+		})
+	}
+}
+
+func TestSensitiveOutputsSecret(t *testing.T) {
+	cr := &v1alpha1.VM{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test", UID: "test-uid"}}
+	cr.Spec.ForProvider.SensitiveOutputs = []string{"token"}
+	owned := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-script-outputs", ResourceVersion: "1"}, Data: map[string][]byte{"token": []byte("old")}}
+	meta.AddOwnerReference(owned, meta.AsController(meta.TypedReferenceTo(cr, v1alpha1.VMGroupVersionKind)))
+	foreign := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-script-outputs", ResourceVersion: "1"}, Data: map[string][]byte{"token": []byte("theirs")}}
+
+	type want struct {
+		written map[string][]byte
+		err     error
+		read    map[string][]byte
+	}
+
+	cases := map[string]struct {
+		reason   string
+		existing *corev1.Secret
+		want     want
+	}{
+		"Create": {
+			reason: "Should create the Secret holding the VM's sensitive outputs",
+			want:   want{written: map[string][]byte{"token": []byte("s3cr3t")}},
+		},
+		"Update": {
+			reason:   "Should update the Secret the VM controls",
+			existing: owned,
+			want:     want{written: map[string][]byte{"token": []byte("s3cr3t")}, read: map[string][]byte{"token": []byte("old")}},
+		},
+		"NotControlled": {
+			reason:   "Should neither overwrite nor publish a Secret of the same name the VM does not control",
+			existing: foreign,
+			want:     want{err: errors.Wrap(errors.Errorf(errOutputsNotOwned, "default", "test-script-outputs"), errWriteScriptOutputs)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var written *corev1.Secret
+			write := func(obj client.Object) error {
+				written = obj.(*corev1.Secret).DeepCopy()
+				return nil
+			}
+			kube := &test.MockClient{
+				MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+					if tc.existing == nil {
+						return kerrors.NewNotFound(corev1.Resource("secrets"), key.Name)
+					}
+					tc.existing.DeepCopyInto(obj.(*corev1.Secret))
+					return nil
+				},
+				MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error { return write(obj) },
+				MockUpdate: func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error { return write(obj) },
+			}
+
+			read, err := readSensitiveOutputs(context.Background(), kube, cr)
+			if err != nil {
+				t.Fatalf("\n%s\nreadSensitiveOutputs(...): unexpected error: %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.read, read); diff != "" {
+				t.Errorf("\n%s\nreadSensitiveOutputs(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+
+			err = writeSensitiveOutputs(context.Background(), kube, cr, map[string]string{"token": "s3cr3t"})
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nwriteSensitiveOutputs(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if tc.want.err != nil {
+				if written != nil {
+					t.Errorf("\n%s\nwriteSensitiveOutputs(...): wrote a Secret the VM does not control", tc.reason)
+				}
+				return
+			}
+			if diff := cmp.Diff(tc.want.written, written.Data); diff != "" {
+				t.Errorf("\n%s\nwriteSensitiveOutputs(...): -want data, +got data:\n%s\n", tc.reason, diff)
+			}
+			if !metav1.IsControlledBy(written, cr) {
+				t.Errorf("\n%s\nwriteSensitiveOutputs(...): outputs Secret is not controlled by the VM", tc.reason)
+			}
+		})
+	}
+}
+
+func TestParseOutputs(t *testing.T) {
+	cases := map[string]struct {
+		reason  string
+		content string
+		want    map[string]string
+		err     error
+	}{
+		"Empty": {
+			reason: "A script that wrote no outputs should have none.",
+			want:   map[string]string{},
+		},
+		"KeyValue": {
+			reason:  "Each key=value line should be an output. Values may contain '=', and later lines override earlier ones.",
+			content: "id=1\n\nquery=a=b\r\nid=2\n",
+			want:    map[string]string{"id": "2", "query": "a=b"},
+		},
+		"EmptyValue": {
+			reason:  "A key with nothing after '=' should be an empty output.",
+			content: "id=",
+			want:    map[string]string{"id": ""},
+		},
+		"Multiline": {
+			reason:  "A key<<delimiter line should start a value that ends at the delimiter.",
+			content: "cert<<EOF\n-----BEGIN-----\nabc=\n-----END-----\nEOF\nid=1\n",
+			want:    map[string]string{"cert": "-----BEGIN-----\nabc=\n-----END-----", "id": "1"},
+		},
+		"DelimiterAfterEquals": {
+			reason:  "'<<' after the first '=' should be part of a value.",
+			content: "cmd=cat <<EOF\n",
+			want:    map[string]string{"cmd": "cat <<EOF"},
+		},
+		"InvalidLine": {
+			reason:  "A line that is not an output should be an error.",
+			content: "id=1\nhello\n",
+			err:     errors.Errorf(errInvalidOutputLine, 2),
+		},
+		"InvalidKey": {
+			reason:  "Keys that are not valid Secret keys should be an error.",
+			content: "runner id=1\n",
+			err:     errors.Errorf(errInvalidOutputKey, "runner id", 1),
+		},
+		"Unterminated": {
+			reason:  "A multiline value without its delimiter should be an error.",
+			content: "cert<<EOF\nabc\n",
+			err:     errors.Errorf(errUnterminatedOutput, "cert", "EOF"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseOutputs(tc.content)
+			if diff := cmp.Diff(tc.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nparseOutputs(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nparseOutputs(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestProberRecord(t *testing.T) {
+	errFailed := errors.New("connection refused")
+
+	type want struct {
+		status   string
+		failures int32
+		message  string
+		changed  bool
+	}
+
+	cases := map[string]struct {
+		reason  string
+		probe   v1alpha1.VMProbe
+		results []error
+		want    want
+	}{
+		"SuccessWithinThreshold": {
+			reason:  "Should report success once the success threshold is reached",
+			probe:   v1alpha1.VMProbe{SuccessThreshold: 2},
+			results: []error{nil, nil},
+			want:    want{status: v1alpha1.ProbeStatusSuccess, changed: true},
+		},
+		"SuccessBelowThreshold": {
+			reason:  "Should stay unknown until the success threshold is reached",
+			probe:   v1alpha1.VMProbe{SuccessThreshold: 2},
+			results: []error{nil},
+			want:    want{status: v1alpha1.ProbeStatusUnknown},
+		},
+		"FailureBelowThreshold": {
+			reason:  "Should count failures below the failure threshold without failing",
+			probe:   v1alpha1.VMProbe{FailureThreshold: 3},
+			results: []error{nil, errFailed, errFailed},
+			want:    want{status: v1alpha1.ProbeStatusSuccess, failures: 2, message: errFailed.Error()},
+		},
+		"FailureAtThreshold": {
+			reason:  "Should fail once the failure threshold is reached",
+			probe:   v1alpha1.VMProbe{FailureThreshold: 3},
+			results: []error{nil, errFailed, errFailed, errFailed},
+			want:    want{status: v1alpha1.ProbeStatusFailure, failures: 3, message: errFailed.Error(), changed: true},
+		},
+		"DefaultThresholds": {
+			reason:  "Should use the default thresholds when none are set",
+			results: []error{errFailed, errFailed, errFailed},
+			want:    want{status: v1alpha1.ProbeStatusFailure, failures: 3, message: errFailed.Error(), changed: true},
+		},
+		"Recovers": {
+			reason:  "Should reset the failure count once a probe succeeds again",
+			probe:   v1alpha1.VMProbe{FailureThreshold: 1},
+			results: []error{errFailed, nil},
+			want:    want{status: v1alpha1.ProbeStatusSuccess, changed: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := newProber()
+			st := &probeState{status: v1alpha1.ProbeStatusUnknown}
+			changed := false
+			for _, err := range tc.results {
+				changed = p.record(&tc.probe, st, err)
+			}
+			got := want{status: st.status, failures: st.failures, message: st.message, changed: changed}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\np.record(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestProberCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	dialServer := func(ctx context.Context, _ ssh.TunnelConfig, _ int) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", srv.Listener.Addr().String())
+	}
+	errRefused := errors.New("connection refused")
+	dialRefused := func(_ context.Context, _ ssh.TunnelConfig, _ int) (net.Conn, error) {
+		return nil, errRefused
+	}
+
+	cases := map[string]struct {
+		reason  string
+		dial    func(context.Context, ssh.TunnelConfig, int) (net.Conn, error)
+		scripts map[string]mockScript
+		probe   v1alpha1.VMProbe
+		wantErr bool
+	}{
+		"ExecSuccess": {
+			reason: "Should succeed when the command exits with code 0",
+			probe:  v1alpha1.VMProbe{Exec: &v1alpha1.VMExecAction{Command: "test -f /tmp/ready"}},
+		},
+		"ExecFailure": {
+			reason:  "Should fail when the command exits with a non-zero code",
+			scripts: map[string]mockScript{"/tmp/ready": {exitCode: 1}},
+			probe:   v1alpha1.VMProbe{Exec: &v1alpha1.VMExecAction{Command: "test -f /tmp/ready"}},
+			wantErr: true,
+		},
+		"TCPSuccess": {
+			reason: "Should succeed when the port accepts connections",
+			dial:   dialServer,
+			probe:  v1alpha1.VMProbe{TCPSocket: &v1alpha1.VMTCPSocketAction{Port: 8080}},
+		},
+		"TCPFailure": {
+			reason:  "Should fail when the port cannot be reached",
+			dial:    dialRefused,
+			probe:   v1alpha1.VMProbe{TCPSocket: &v1alpha1.VMTCPSocketAction{Port: 8080}},
+			wantErr: true,
+		},
+		"HTTPSuccess": {
+			reason: "Should succeed when the endpoint returns a 2xx status",
+			dial:   dialServer,
+			probe:  v1alpha1.VMProbe{HTTPGet: &v1alpha1.VMHTTPGetAction{Path: "/healthz", Port: 8080}},
+		},
+		"HTTPErrorStatus": {
+			reason:  "Should fail when the endpoint returns an error status",
+			dial:    dialServer,
+			probe:   v1alpha1.VMProbe{HTTPGet: &v1alpha1.VMHTTPGetAction{Path: "/", Port: 8080}},
+			wantErr: true,
+		},
+		"HTTPUnreachable": {
+			reason:  "Should fail when the endpoint cannot be reached",
+			dial:    dialRefused,
+			probe:   v1alpha1.VMProbe{HTTPGet: &v1alpha1.VMHTTPGetAction{Path: "/healthz", Port: 8080}},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := newProber()
+			p.newSession = func(_ context.Context, _ ssh.TunnelConfig) (ssh.VMSession, error) {
+				return &mockSession{scripts: tc.scripts}, nil
+			}
+			p.dial = tc.dial
+			err := p.check(context.Background(), ssh.TunnelConfig{VMName: "test"}, &tc.probe)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("\n%s\np.check(...): want error %t, got %v", tc.reason, tc.wantErr, err)
+			}
+		})
+	}
+}
+
+// startProber returns a running prober whose probes dial through the
+// supplied function and whose events are discarded.
+func startProber(t *testing.T, dial func(context.Context, ssh.TunnelConfig, int) (net.Conn, error)) *prober {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	p := newProber()
+	p.dial = dial
+	p.ctx = ctx
+	go func() {
+		for {
+			select {
+			case <-p.events:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return p
+}
+
+func TestObserveStopsBackgroundWork(t *testing.T) {
+	respond := func(code int, body string) *orchardclient.OrchardClient {
+		return newMockOrchardClient(&mockHTTPClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: code, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+			},
+		})
+	}
+	dialRefused := func(_ context.Context, _ ssh.TunnelConfig, _ int) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}
+
+	cases := map[string]struct {
+		reason  string
+		client  *orchardclient.OrchardClient
+		deleted bool
+	}{
+		"VMNotFound": {
+			reason: "Should stop watching and probing a VM that no longer exists in Orchard",
+			client: respond(http.StatusNotFound, ""),
+		},
+		"VMBeingDeleted": {
+			reason:  "Should stop watching and probing a VM being deleted, which may be orphaned without reaching Delete",
+			client:  respond(http.StatusOK, `{"name":"test-vm","status":"running"}`),
+			deleted: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			cr := &v1alpha1.VM{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"}}
+			meta.SetExternalName(cr, "test-vm")
+			if tc.deleted {
+				cr.SetDeletionTimestamp(&metav1.Time{Time: time.Now()})
+			}
+			nn := types.NamespacedName{Namespace: "default", Name: "test"}
+
+			m := newWatchManager(logging.NewNopLogger())
+			m.ctx = ctx
+			m.vms[nn] = &vmWatch{vmName: "test-vm", cancel: func() {}}
+			p := startProber(t, dialRefused)
+			p.Probe(cr, probeSpec{readiness: &v1alpha1.VMProbe{TCPSocket: &v1alpha1.VMTCPSocketAction{Port: 8080}}})
+
+			e := &external{client: tc.client, recorder: event.NewNopRecorder(), watches: m, prober: p}
+			if _, err := e.Observe(context.Background(), cr); err != nil {
+				t.Fatalf("\n%s\ne.Observe(...): %v", tc.reason, err)
+			}
+
+			if _, ok := m.vms[nn]; ok {
+				t.Errorf("\n%s\ne.Observe(...): VM is still watched", tc.reason)
+			}
+			if _, ok := p.vms[nn]; ok {
+				t.Errorf("\n%s\ne.Observe(...): VM is still probed", tc.reason)
+			}
+		})
+	}
+}
+
+func TestReferencedDataChanged(t *testing.T) {
+	secret := func(labels map[string]string, data string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "creds", Labels: labels},
+			Data:       map[string][]byte{"password": []byte(data)},
+		}
+	}
+	configMap := func(data string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "script"},
+			Data:       map[string]string{"script.sh": data},
+		}
+	}
+	referencedBy := func(n int) client.Reader {
+		return &test.MockClient{MockList: func(_ context.Context, obj client.ObjectList, _ ...client.ListOption) error {
+			obj.(*v1alpha1.VMList).Items = make([]v1alpha1.VM, n)
+			return nil
+		}}
+	}
+
+	cases := map[string]struct {
+		reason string
+		kube   client.Reader
+		send   func(p predicate.Predicate) bool
+		want   bool
+	}{
+		"Unreferenced": {
+			reason: "Should drop events of objects no VM references",
+			kube:   referencedBy(0),
+			send: func(p predicate.Predicate) bool {
+				return p.Create(ctrlevent.CreateEvent{Object: secret(nil, "a")})
+			},
+			want: false,
+		},
+		"ReferencedCreated": {
+			reason: "Should pass the creation of an object a VM references",
+			kube:   referencedBy(1),
+			send: func(p predicate.Predicate) bool {
+				return p.Create(ctrlevent.CreateEvent{Object: secret(nil, "a")})
+			},
+			want: true,
+		},
+		"ReferencedDataChanged": {
+			reason: "Should pass updates that change the data of a referenced Secret",
+			kube:   referencedBy(1),
+			send: func(p predicate.Predicate) bool {
+				return p.Update(ctrlevent.UpdateEvent{ObjectOld: secret(nil, "a"), ObjectNew: secret(nil, "b")})
+			},
+			want: true,
+		},
+		"ReferencedMetadataChanged": {
+			reason: "Should drop updates that only change the metadata of a referenced Secret",
+			kube:   referencedBy(1),
+			send: func(p predicate.Predicate) bool {
+				return p.Update(ctrlevent.UpdateEvent{ObjectOld: secret(nil, "a"), ObjectNew: secret(map[string]string{"a": "b"}, "a")})
+			},
+			want: false,
+		},
+		"ReferencedConfigMapChanged": {
+			reason: "Should pass updates that change the data of a referenced ConfigMap",
+			kube:   referencedBy(1),
+			send: func(p predicate.Predicate) bool {
+				return p.Update(ctrlevent.UpdateEvent{ObjectOld: configMap("a"), ObjectNew: configMap("b")})
+			},
+			want: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := tc.send(referencedDataChanged(tc.kube, secretRefIndex))
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nreferencedDataChanged(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestStopOnDelete(t *testing.T) {
+	dialRefused := func(_ context.Context, _ ssh.TunnelConfig, _ int) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}
+
+	type want struct {
+		watched      bool
+		probed       bool
+		provisioning bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		send   func(h handler.EventHandler, cr *v1alpha1.VM)
+		want   want
+	}{
+		"OrphanDeleted": {
+			reason: "Should stop all background work of a VM deleted under the Orphan policy, which never reaches Observe or Delete",
+			send: func(h handler.EventHandler, cr *v1alpha1.VM) {
+				h.Delete(context.Background(), ctrlevent.DeleteEvent{Object: cr}, nil)
+			},
+		},
+		"BeingDeleted": {
+			reason: "Should stop watching and probing a VM being deleted, but leave its pre-delete job running",
+			send: func(h handler.EventHandler, cr *v1alpha1.VM) {
+				deleting := cr.DeepCopy()
+				deleting.SetDeletionTimestamp(&metav1.Time{Time: time.Now()})
+				h.Update(context.Background(), ctrlevent.UpdateEvent{ObjectOld: cr, ObjectNew: deleting}, nil)
+			},
+			want: want{provisioning: true},
+		},
+		"Updated": {
+			reason: "Should leave the background work of a VM that is not being deleted alone",
+			send: func(h handler.EventHandler, cr *v1alpha1.VM) {
+				h.Update(context.Background(), ctrlevent.UpdateEvent{ObjectOld: cr, ObjectNew: cr.DeepCopy()}, nil)
+			},
+			want: want{watched: true, probed: true, provisioning: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := &v1alpha1.VM{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"}}
+			nn := types.NamespacedName{Namespace: "default", Name: "test"}
+
+			m := newWatchManager(logging.NewNopLogger())
+			m.vms[nn] = &vmWatch{vmName: "test-vm", cancel: func() {}}
+			p := startProber(t, dialRefused)
+			p.Probe(cr, probeSpec{readiness: &v1alpha1.VMProbe{TCPSocket: &v1alpha1.VMTCPSocketAction{Port: 8080}}})
+			prov := newProvisioner(test.NewMockClient(), event.NewNopRecorder(), 1)
+			prov.jobs[nn] = &provisionJob{cancel: func() {}}
+
+			tc.send(stopOnDelete(m, p, prov), cr)
+
+			got := want{}
+			_, got.watched = m.vms[nn]
+			_, got.probed = p.vms[nn]
+			_, got.provisioning = prov.jobs[nn]
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nstopOnDelete(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestHandleProbes(t *testing.T) {
+	dialOK := func(_ context.Context, _ ssh.TunnelConfig, _ int) (net.Conn, error) {
+		client, server := net.Pipe()
+		_ = server.Close()
+		return client, nil
+	}
+	dialRefused := func(_ context.Context, _ ssh.TunnelConfig, _ int) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}
+	tcp := &v1alpha1.VMProbe{TCPSocket: &v1alpha1.VMTCPSocketAction{Port: 8080}, FailureThreshold: 1}
+
+	type want struct {
+		reason    xpv1.ConditionReason
+		readiness string
+		liveness  string
+		unhealthy bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		dial   func(context.Context, ssh.TunnelConfig, int) (net.Conn, error)
+		params v1alpha1.VMParameters
+		ready  xpv1.Condition
+		want   want
+	}{
+		"NoProbes": {
+			reason: "Should leave the VM available when it has no probes",
+			dial:   dialRefused,
+			ready:  xpv1.Available(),
+			want:   want{reason: xpv1.ReasonAvailable},
+		},
+		"NotProvisioned": {
+			reason: "Should not probe a VM that is not provisioned yet",
+			dial:   dialOK,
+			params: v1alpha1.VMParameters{ReadinessProbe: tcp},
+			ready:  xpv1.Creating(),
+			want:   want{reason: xpv1.ReasonCreating},
+		},
+		"Ready": {
+			reason: "Should keep the VM available while its readiness probe succeeds",
+			dial:   dialOK,
+			params: v1alpha1.VMParameters{ReadinessProbe: tcp},
+			ready:  xpv1.Available(),
+			want:   want{reason: xpv1.ReasonAvailable, readiness: v1alpha1.ProbeStatusSuccess},
+		},
+		"NotReady": {
+			reason: "Should mark the VM unavailable when its readiness probe fails",
+			dial:   dialRefused,
+			params: v1alpha1.VMParameters{ReadinessProbe: tcp},
+			ready:  xpv1.Available(),
+			want:   want{reason: xpv1.ReasonUnavailable, readiness: v1alpha1.ProbeStatusFailure},
+		},
+		"NotLive": {
+			reason: "Should mark the VM unavailable without recreating it when its liveness probe fails",
+			dial:   dialRefused,
+			params: v1alpha1.VMParameters{LivenessProbe: tcp},
+			ready:  xpv1.Available(),
+			want:   want{reason: xpv1.ReasonUnavailable, liveness: v1alpha1.ProbeStatusFailure},
+		},
+		"NotLiveRecreate": {
+			reason: "Should mark the VM for recreation when its liveness probe fails and the action asks for it",
+			dial:   dialRefused,
+			params: v1alpha1.VMParameters{LivenessProbe: tcp, LivenessFailureAction: v1alpha1.LivenessFailureActionRecreate},
+			ready:  xpv1.Available(),
+			want:   want{reason: xpv1.ReasonUnavailable, liveness: v1alpha1.ProbeStatusFailure, unhealthy: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := &external{prober: startProber(t, tc.dial)}
+			cr := &v1alpha1.VM{
+				ObjectMeta: metav1.ObjectMeta{Name: "test"},
+				Spec:       v1alpha1.VMSpec{ForProvider: tc.params},
+			}
+
+			// Wait for the probes to report a result, like successive
+			// reconciles would
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				cr.SetConditions(tc.ready)
+				e.handleProbes(cr)
+				r, l := cr.Status.AtProvider.ReadinessProbe, cr.Status.AtProvider.LivenessProbe
+				if (r == nil || r.Status != v1alpha1.ProbeStatusUnknown) && (l == nil || l.Status != v1alpha1.ProbeStatusUnknown) {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("probes did not report a result")
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			got := want{reason: cr.GetCondition(xpv1.TypeReady).Reason, unhealthy: e.unhealthy != ""}
+			if r := cr.Status.AtProvider.ReadinessProbe; r != nil {
+				got.readiness = r.Status
+			}
+			if l := cr.Status.AtProvider.LivenessProbe; l != nil {
+				got.liveness = l.Status
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\ne.handleProbes(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGuestProfile(t *testing.T) {
+	type want struct {
+		username    string
+		stepScript  string
+		interpreter string
+		shebang     string
+		hostname    string
+		addUser     string
+		escalation  ssh.Escalation
+	}
+
+	cases := map[string]struct {
+		reason  string
+		guestOS string
+		want    want
+	}{
+		"Default": {
+			reason: "VMs that set no guest OS should be treated as macOS, whose sudo is given the SSH password on stdin.",
+			want: want{
+				username:    DefaultSSHUsername,
+				stepScript:  "/tmp/cloudinit-step-2",
+				interpreter: "/bin/zsh",
+				hostname:    "scutil --set HostName 'ci.local' && scutil --set LocalHostName 'ci' && scutil --set ComputerName 'ci.local'",
+				addUser:     "id -u 'ci' >/dev/null 2>&1 || { sysadminctl -addUser 'ci' && createhomedir -c -u 'ci' >/dev/null; }",
+				escalation:  ssh.Escalation{Command: "sudo -S -p '' -H -u %s --", Password: ssh.PasswordStdin},
+			},
+		},
+		"Linux": {
+			reason:  "Linux guests should upload scripts to /var/tmp, run them with bash, use Linux tools and passwordless sudo.",
+			guestOS: v1alpha1.GuestOSLinux,
+			want: want{
+				username:    DefaultSSHUsername,
+				stepScript:  "/var/tmp/cloudinit-step-2",
+				interpreter: "/bin/bash",
+				hostname:    "hostnamectl set-hostname 'ci.local'",
+				addUser:     "id -u 'ci' >/dev/null 2>&1 || useradd -m 'ci'",
+				escalation:  ssh.Escalation{Command: "sudo -n -H -u %s --", Password: ssh.PasswordNone},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := guestProfileFor(tc.guestOS)
+			username, _ := getSSHCredentials(sshCredentials{}, p)
+			got := want{
+				username:    username,
+				stepScript:  p.stepScriptPath(2),
+				interpreter: p.interpreter("echo hi", ""),
+				shebang:     p.interpreter("#!/usr/bin/env python3\nprint('hi')", ""),
+				hostname:    p.hostnameCommand("ci.local"),
+				addUser:     p.addUserCommand("ci"),
+				escalation:  p.escalation,
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nguestProfileFor(%q): -want, +got:\n%s\n", tc.reason, tc.guestOS, diff)
+			}
+		})
+	}
+}
+
+func TestGuestProfileEscalation(t *testing.T) {
+	macOS, linux := guestProfileFor(v1alpha1.GuestOSMacOS), guestProfileFor(v1alpha1.GuestOSLinux)
+
+	s := &commandSession{}
+	for _, p := range []guestProfile{macOS, linux} {
+		if _, err := runCloudConfig(context.Background(), s, "#cloud-config\nhostname: ci\n", p); err != nil {
+			t.Fatalf("runCloudConfig(...): unexpected error: %v", err)
+		}
+	}
+	if diff := cmp.Diff([]ssh.Escalation{macOS.escalation, linux.escalation}, s.escalations); diff != "" {
+		t.Errorf("runCloudConfig(...): -want escalations, +got escalations:\n%s\n", diff)
+	}
+	if macOS.escalation == linux.escalation {
+		t.Errorf("guestProfiles: macOS and Linux share the escalation %+v", macOS.escalation)
+	}
+}
+
+func TestCloudConfigActions(t *testing.T) {
+	type want struct {
+		unsupported []string
+		actions     []string
+		runAs       []string
+		uploads     map[string]string
+		err         bool
+	}
+
+	cases := map[string]struct {
+		reason   string
+		guestOS  string
+		userData string
+		want     want
+	}{
+		"MissingHeader": {
+			reason:   "Should reject user data that is not a cloud-config document",
+			userData: "#!/bin/sh\necho hi\n",
+			want:     want{err: true},
+		},
+		"InvalidYAML": {
+			reason:   "Should reject user data that is not valid YAML",
+			userData: "#cloud-config\nruncmd: [\n",
+			want:     want{err: true},
+		},
+		"UnsupportedKeys": {
+			reason:   "Should report unsupported keys and apply the rest",
+			userData: "#cloud-config\npackages: [git]\nbootcmd: [date]\nhostname: build-01\n",
+			want: want{
+				unsupported: []string{"bootcmd", "packages"},
+				actions:     []string{"hostname build-01"},
+				runAs:       []string{"root"},
+			},
+		},
+		"ModuleOrder": {
+			reason: "Should apply modules in the order cloud-init does",
+			userData: `#cloud-config
+runcmd:
+  - echo done
+  - [touch, /tmp/it's done]
+write_files:
+  - path: /etc/late.conf
+    content: late
+    defer: true
+  - path: /etc/motd
+    content: hello
+    permissions: '0600'
+users:
+  - default
+  - name: ci
+    sudo: ALL=(ALL) NOPASSWD:ALL
+    ssh_authorized_keys: [ssh-ed25519 AAAA ci]
+ssh_authorized_keys:
+  - ssh-ed25519 BBBB admin
+hostname: build-01
+`,
+			want: want{
+				actions: []string{
+					"hostname build-01",
+					"write_files /etc/motd",
+					"users ci",
+					"users ci sudo",
+					"users ci ssh_authorized_keys",
+					"ssh_authorized_keys",
+					"write_files /etc/late.conf",
+					"runcmd",
+				},
+				runAs: []string{"root", "root", "root", "root", "root", "", "root", "root"},
+				uploads: map[string]string{
+					"/tmp/cloudinit-userdata-0123-0": "hello",
+					"/tmp/cloudinit-userdata-0123-1": "ci ALL=(ALL) NOPASSWD:ALL\n",
+					"/tmp/cloudinit-userdata-0123-2": "ssh-ed25519 AAAA ci\n",
+					"/tmp/cloudinit-userdata-0123-3": "ssh-ed25519 BBBB admin\n",
+					"/tmp/cloudinit-userdata-0123-4": "late",
+					"/tmp/cloudinit-runcmd-0123.sh":  "#!/bin/sh\necho done\n'touch' '/tmp/it'\"'\"'s done'\n",
+				},
+			},
+		},
+		"EncodedContent": {
+			reason:   "Should decode base64 and gzipped file content",
+			userData: "#cloud-config\nwrite_files:\n  - path: /a\n    encoding: b64\n    content: aGVsbG8=\n  - path: /b\n    encoding: gz+b64\n    content: H4sIAAAAAAAAA8tIzcnJBwCGphA2BQAAAA==\n",
+			want: want{
+				actions: []string{"write_files /a", "write_files /b"},
+				runAs:   []string{"root", "root"},
+				uploads: map[string]string{"/tmp/cloudinit-userdata-0123-0": "hello", "/tmp/cloudinit-userdata-0123-1": "hello"},
+			},
+		},
+		"LinuxGuest": {
+			reason:   "Should upload files to the Linux guest's temporary directory",
+			guestOS:  v1alpha1.GuestOSLinux,
+			userData: "#cloud-config\nwrite_files:\n  - path: /etc/motd\n    content: hello\nruncmd: [date]\n",
+			want: want{
+				actions: []string{"write_files /etc/motd", "runcmd"},
+				runAs:   []string{"root", "root"},
+				uploads: map[string]string{
+					"/var/tmp/cloudinit-userdata-0123-0": "hello",
+					"/var/tmp/cloudinit-runcmd-0123.sh":  "#!/bin/sh\ndate\n",
+				},
+			},
+		},
+		"UnsupportedEncoding": {
+			reason:   "Should reject files with an unknown encoding",
+			userData: "#cloud-config\nwrite_files:\n  - path: /a\n    encoding: rot13\n    content: uryyb\n",
+			want:     want{err: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg, unsupported, err := parseCloudConfig(tc.userData)
+			var actions []cloudConfigAction
+			if err == nil {
+				actions, err = cloudConfigActions(cfg, guestProfileFor(tc.guestOS), "0123")
+			}
+			if (err != nil) != tc.want.err {
+				t.Fatalf("\n%s\ncloudConfigActions(...): want error %t, got %v", tc.reason, tc.want.err, err)
+			}
+
+			got := want{unsupported: unsupported, err: err != nil}
+			for _, a := range actions {
+				got.actions = append(got.actions, a.Description)
+				got.runAs = append(got.runAs, a.RunAs)
+				if a.UploadPath != "" {
+					if got.uploads == nil {
+						got.uploads = map[string]string{}
+					}
+					got.uploads[a.UploadPath] = string(a.Upload)
+				}
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\ncloudConfigActions(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// commandSession is an SSH session that records uploads, commands and the
+// users they run as, and fails commands containing a marker.
+type commandSession struct {
+	mockSession
+	fail        string
+	uploads     []string
+	commands    []string
+	users       []string
+	escalations []ssh.Escalation
+}
+
+func (s *commandSession) UploadBytes(_ context.Context, _ []byte, opts ssh.FileUploadOptions) error {
+	s.uploads = append(s.uploads, opts.RemotePath)
+	return nil
+}
+
+func (s *commandSession) ExecuteCommandAs(ctx context.Context, command string, opts ssh.SudoOptions) (*ssh.CommandResult, error) {
+	s.users = append(s.users, opts.User)
+	s.escalations = append(s.escalations, opts.Escalation)
+	return s.ExecuteCommand(ctx, command)
+}
+
+func (s *commandSession) ExecuteCommand(_ context.Context, command string) (*ssh.CommandResult, error) {
+	s.commands = append(s.commands, command)
+	if s.fail != "" && strings.Contains(command, s.fail) {
+		return &ssh.CommandResult{ExitCode: 1, Stderr: "failed\n"}, nil
+	}
+	return &ssh.CommandResult{Stdout: "ok\n"}, nil
+}
+
+func TestRunCloudConfig(t *testing.T) {
+	userData := "#cloud-config\nhostname: build-01\nwrite_files:\n  - path: /etc/motd\n    content: hello\nruncmd:\n  - echo hi\n"
+
+	t.Run("AppliesEveryAction", func(t *testing.T) {
+		s := &commandSession{}
+		result, err := runCloudConfig(context.Background(), s, userData, guestProfileFor(""))
+		if err != nil {
+			t.Fatalf("runCloudConfig(...): unexpected error: %v", err)
+		}
+		uploads := regexp.MustCompile(`^/tmp/cloudinit-(userdata-[0-9a-f]{16}-0|runcmd-[0-9a-f]{16}\.sh)$`)
+		if len(s.uploads) != 2 || !uploads.MatchString(s.uploads[0]) || !uploads.MatchString(s.uploads[1]) {
+			t.Errorf("runCloudConfig(...): want uploads with unique names, got %v", s.uploads)
+		}
+		if diff := cmp.Diff([]string{"root", "root", "root"}, s.users); diff != "" {
+			t.Errorf("runCloudConfig(...): -want commands run as, +got commands run as:\n%s\n", diff)
+		}
+		want := "==> hostname build-01\nok\n==> write_files /etc/motd\nok\n==> runcmd\nok\n"
+		if diff := cmp.Diff(&ssh.CommandResult{Stdout: want}, result); diff != "" {
+			t.Errorf("runCloudConfig(...): -want result, +got result:\n%s\n", diff)
+		}
+	})
+
+	t.Run("StopsAtFailedAction", func(t *testing.T) {
+		s := &commandSession{fail: "/etc/motd"}
+		result, err := runCloudConfig(context.Background(), s, userData, guestProfileFor(""))
+		if err != nil {
+			t.Fatalf("runCloudConfig(...): unexpected error: %v", err)
+		}
+		if len(s.commands) != 2 {
+			t.Errorf("runCloudConfig(...): want runcmd skipped after a failed action, got %d commands", len(s.commands))
+		}
+		want := &ssh.CommandResult{ExitCode: 1, Stdout: "==> hostname build-01\nok\n==> write_files /etc/motd\n", Stderr: "failed\n"}
+		if diff := cmp.Diff(want, result); diff != "" {
+			t.Errorf("runCloudConfig(...): -want result, +got result:\n%s\n", diff)
+		}
+	})
+}
+
+func TestPreDelete(t *testing.T) {
+	script := guestProfileFor("").stepScriptPath(0)
+
+	type want struct {
+		requests []string
+		err      error
+		status   string
+		reasons  []event.Reason
+	}
+
+	cases := map[string]struct {
+		reason  string
+		script  *v1alpha1.VMPreDeleteScript
+		vmState string
+		scripts map[string]mockScript
+		want    want
+	}{
+		"NoScript": {
+			reason:  "Should delete the VM right away when it has no pre-delete script",
+			vmState: "running",
+			want:    want{requests: []string{"DELETE /vms/test-vm"}},
+		},
+		"NotRunning": {
+			reason:  "Should skip the pre-delete script of a VM that is not running",
+			script:  &v1alpha1.VMPreDeleteScript{ScriptContent: "deregister"},
+			vmState: "failed",
+			want: want{
+				requests: []string{"DELETE /vms/test-vm"},
+				reasons:  []event.Reason{reasonPreDeleteScriptSkipped},
+			},
+		},
+		"Completed": {
+			reason:  "Should delete the VM once its pre-delete script completed",
+			script:  &v1alpha1.VMPreDeleteScript{ScriptContent: "deregister"},
+			vmState: "running",
+			want: want{
+				requests: []string{"DELETE /vms/test-vm"},
+				status:   CloudInitStatusCompleted,
+				reasons:  []event.Reason{reasonProvisioningStepStarted, reasonProvisioningStepCompleted, reasonPreDeleteScriptCompleted},
+			},
+		},
+		"FailedContinue": {
+			reason:  "Should delete the VM anyway when its pre-delete script fails and the policy is Continue",
+			script:  &v1alpha1.VMPreDeleteScript{ScriptContent: "deregister", OnFailure: v1alpha1.PreDeleteFailurePolicyContinue},
+			vmState: "running",
+			scripts: map[string]mockScript{script: {exitCode: 1, stderr: "boom"}},
+			want: want{
+				requests: []string{"DELETE /vms/test-vm"},
+				status:   CloudInitStatusFailed,
+				reasons:  []event.Reason{reasonProvisioningStepStarted, reasonProvisioningStepFailed, reasonPreDeleteScriptFailed},
+			},
+		},
+		"FailedBlock": {
+			reason:  "Should keep the VM when its pre-delete script fails and the policy is Block",
+			script:  &v1alpha1.VMPreDeleteScript{ScriptContent: "deregister", OnFailure: v1alpha1.PreDeleteFailurePolicyBlock},
+			vmState: "running",
+			scripts: map[string]mockScript{script: {exitCode: 1, stderr: "boom"}},
+			want: want{
+				err:     errors.Wrap(errors.New("exit code 1: boom"), errPreDeleteScript),
+				status:  CloudInitStatusFailed,
+				reasons: []event.Reason{reasonProvisioningStepStarted, reasonProvisioningStepFailed, reasonPreDeleteScriptFailed},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var requests []string
+			client := newMockOrchardClient(&mockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					requests = append(requests, req.Method+" "+req.URL.Path)
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString(""))}, nil
+				},
+			})
+			p := startProvisioner(t, 1, func(_ context.Context, _ ssh.TunnelConfig) (ssh.VMSession, error) {
+				return &mockSession{scripts: tc.scripts}, nil
+			})
+			recorder := &recordingRecorder{}
+			p.recorder = recorder
+			e := &external{client: client, recorder: recorder, provisioner: p}
+			cr := &v1alpha1.VM{
+				ObjectMeta: metav1.ObjectMeta{Name: "test"},
+				Spec:       v1alpha1.VMSpec{ForProvider: v1alpha1.VMParameters{Image: "ubuntu:22.04", PreDeleteScript: tc.script}},
+				Status:     v1alpha1.VMStatus{AtProvider: v1alpha1.VMObservation{Status: tc.vmState}},
+			}
+			meta.SetExternalName(cr, "test-vm")
+
+			// Call Delete until it deletes the VM or fails, waiting for the
+			// script to finish in between, like successive reconciles would
+			var err error
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				_, err = e.Delete(context.Background(), cr)
+				if err != nil || len(requests) > 0 {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("e.Delete(...): VM was not deleted")
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			got := want{requests: requests, err: err, reasons: recorder.reasons()}
+			if st := cr.Status.AtProvider.PreDeleteScript; st != nil {
+				got.status = st.Status
+			}
+			if len(got.reasons) == 0 {
+				got.reasons = nil
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{}), test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Delete(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
 }
@@ -0,0 +1,377 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	v1alpha1 "github.com/ravan/provider-orchard/apis/compute/v1alpha1"
+	orchardclient "github.com/ravan/provider-orchard/internal/clients/orchard"
+)
+
+const (
+	errWatchVM = "cannot watch VM"
+
+	// Orchard watch notification types
+	watchEventDeleted = "DELETED"
+
+	// Delays before reopening a dropped watch stream. The VM is polled at the
+	// regular interval until the stream is back.
+	minWatchBackoff = 1 * time.Second
+	maxWatchBackoff = 2 * time.Minute
+
+	// watchResyncInterval is how often VMs with a healthy watch stream are
+	// polled anyway, to catch anything the stream missed.
+	watchResyncInterval = 10 * time.Minute
+)
+
+// errWatchNotFound is returned when the watched VM does not exist in Orchard.
+var errWatchNotFound = errors.New("VM not found")
+
+// A watchEvent is a notification of Orchard's ?watch=true stream.
+type watchEvent struct {
+	Type   string           `json:"type"`
+	Object orchardclient.VM `json:"object"`
+}
+
+// A providerKey identifies the ProviderConfig or ClusterProviderConfig a VM
+// references.
+type providerKey struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+// providerKeyFor returns the key of the provider config a VM references.
+func providerKeyFor(cr *v1alpha1.VM) providerKey {
+	ref := cr.GetProviderConfigReference()
+	if ref == nil {
+		return providerKey{}
+	}
+	key := providerKey{kind: ref.Kind, name: ref.Name}
+	if ref.Kind != "ClusterProviderConfig" {
+		key.namespace = cr.GetNamespace()
+	}
+	return key
+}
+
+// A providerClient is the Orchard client shared by the watch streams of all
+// VMs using a provider config.
+type providerClient struct {
+	config orchardclient.OrchardConfig
+	client *orchardclient.OrchardClient
+}
+
+// A vmWatch is the watch stream of a single VM.
+type vmWatch struct {
+	provider  providerKey
+	vmName    string
+	cancel    context.CancelFunc
+	streaming atomic.Bool
+}
+
+// A watchManager streams changes to Orchard VMs and enqueues the VM resources
+// that changed, so status changes such as pending to running are observed in
+// seconds rather than at the next poll. Orchard clients are shared per
+// provider config. While a VM's stream is down the VM is polled at the
+// regular interval.
+//
+// Orchard only streams changes to a single VM; GET /vms has no watch
+// parameter. Each VM therefore has its own stream, but the streams of a
+// provider config share its client and thus its connection pool.
+type watchManager struct {
+	log    logging.Logger
+	events chan event.GenericEvent
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	mu        sync.Mutex
+	ctx       context.Context
+	providers map[providerKey]*providerClient
+	vms       map[types.NamespacedName]*vmWatch
+}
+
+// newWatchManager returns a watchManager. Streams are only opened once it is
+// started by the controller manager.
+func newWatchManager(log logging.Logger) *watchManager {
+	return &watchManager{
+		log:        log,
+		events:     make(chan event.GenericEvent),
+		minBackoff: minWatchBackoff,
+		maxBackoff: maxWatchBackoff,
+		providers:  map[providerKey]*providerClient{},
+		vms:        map[types.NamespacedName]*vmWatch{},
+	}
+}
+
+// Start runs the watchManager until the supplied context is done.
+func (m *watchManager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	m.ctx = ctx
+	m.mu.Unlock()
+
+	<-ctx.Done()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for nn, w := range m.vms {
+		w.cancel()
+		delete(m.vms, nn)
+	}
+	m.providers = map[providerKey]*providerClient{}
+	return nil
+}
+
+// Source returns the source that enqueues VMs whose streams reported a change.
+func (m *watchManager) Source() source.Source {
+	return source.Channel(m.events, &handler.EnqueueRequestForObject{})
+}
+
+// Watch ensures the Orchard VM of the supplied VM resource is being watched.
+// Streams using outdated provider config credentials are restarted.
+func (m *watchManager) Watch(cr *v1alpha1.VM, cfg orchardclient.OrchardConfig, vmName string) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ctx == nil || m.ctx.Err() != nil {
+		return
+	}
+
+	key := providerKeyFor(cr)
+	pc, ok := m.providers[key]
+	if !ok || pc.config.BaseURL != cfg.BaseURL || pc.config.Token != cfg.Token {
+		c, err := orchardclient.NewOrchardClient(cfg)
+		if err != nil {
+			m.log.Debug(errWatchVM, "error", err)
+			return
+		}
+		pc = &providerClient{config: cfg, client: c}
+		m.providers[key] = pc
+		m.stopProviderLocked(key)
+	}
+
+	nn := types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()}
+	if w, ok := m.vms[nn]; ok {
+		if w.provider == key && w.vmName == vmName {
+			return
+		}
+		w.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	w := &vmWatch{provider: key, vmName: vmName, cancel: cancel}
+	m.vms[nn] = w
+	go m.run(ctx, pc.client, nn, w)
+}
+
+// Stop stops watching the Orchard VM of the supplied VM resource.
+func (m *watchManager) Stop(cr *v1alpha1.VM) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nn := types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()}
+	if w, ok := m.vms[nn]; ok {
+		w.cancel()
+		delete(m.vms, nn)
+		m.releaseProviderLocked(w.provider)
+	}
+}
+
+// Streaming returns true if changes to the supplied VM resource are currently
+// streamed from Orchard.
+func (m *watchManager) Streaming(mg resource.Managed) bool {
+	if m == nil {
+		return false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, ok := m.vms[types.NamespacedName{Namespace: mg.GetNamespace(), Name: mg.GetName()}]
+	return ok && w.streaming.Load()
+}
+
+// PollInterval polls VMs with a healthy watch stream at the resync interval,
+// and all others at the regular poll interval.
+func (m *watchManager) PollInterval(mg resource.Managed, pollInterval time.Duration) time.Duration {
+	if m.Streaming(mg) {
+		return max(pollInterval, watchResyncInterval)
+	}
+	return pollInterval
+}
+
+// stopProviderLocked stops the streams of all VMs using a provider config.
+// They are restarted by the next Observe of each VM.
+func (m *watchManager) stopProviderLocked(key providerKey) {
+	for nn, w := range m.vms {
+		if w.provider == key {
+			w.cancel()
+			delete(m.vms, nn)
+		}
+	}
+}
+
+// remove forgets a watch, unless it was already replaced.
+func (m *watchManager) remove(nn types.NamespacedName, w *vmWatch) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.vms[nn] == w {
+		w.cancel()
+		delete(m.vms, nn)
+		m.releaseProviderLocked(w.provider)
+	}
+}
+
+// releaseProviderLocked drops the client of a provider config once none of
+// its VMs are watched.
+func (m *watchManager) releaseProviderLocked(key providerKey) {
+	for _, w := range m.vms {
+		if w.provider == key {
+			return
+		}
+	}
+	delete(m.providers, key)
+}
+
+// run keeps a VM's watch stream open until its context is done or the VM no
+// longer exists, reopening dropped streams with exponential backoff. The
+// backoff is reset once a stream connects, so the streams Orchard closes
+// routinely are reopened quickly.
+func (m *watchManager) run(ctx context.Context, c *orchardclient.OrchardClient, nn types.NamespacedName, w *vmWatch) {
+	backoff := m.minBackoff
+	for {
+		connected, err := m.stream(ctx, c, nn, w)
+		w.streaming.Store(false)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = m.minBackoff
+		}
+
+		// Reconcile right away, so nothing is missed while the stream is down
+		enqueueVM(ctx, m.events, nn)
+
+		if errors.Is(err, errWatchNotFound) {
+			m.remove(nn, w)
+			return
+		}
+		m.log.Debug("VM watch stream dropped, falling back to polling", "vm", nn.String(), "error", err, "retry", backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, m.maxBackoff)
+	}
+}
+
+// stream opens a VM's watch stream and enqueues the VM for every notification
+// until the stream ends. It returns true if the stream was connected.
+func (m *watchManager) stream(ctx context.Context, c *orchardclient.OrchardClient, nn types.NamespacedName, w *vmWatch) (bool, error) {
+	watch := true
+	resp, err := c.GetVmsName(ctx, w.vmName, &orchardclient.GetVmsNameParams{Watch: &watch})
+	if err != nil {
+		return false, errors.Wrap(err, errWatchVM)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return false, errWatchNotFound
+	default:
+		return false, errors.Errorf("%s: unexpected status code: %d", errWatchVM, resp.StatusCode)
+	}
+
+	w.streaming.Store(true)
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var e watchEvent
+		if err := dec.Decode(&e); err != nil {
+			if errors.Is(err, io.EOF) {
+				return true, errors.New("stream closed by Orchard")
+			}
+			return true, errors.Wrap(err, errWatchVM)
+		}
+		if e.Type == watchEventDeleted {
+			return true, errWatchNotFound
+		}
+		enqueueVM(ctx, m.events, nn)
+	}
+}
+
+// stopOnDelete returns an event handler that stops the background work of VM
+// resources that are deleted, or being deleted. The managed reconciler never
+// connects to a VM deleted under the Orphan deletion policy, or without
+// Delete in its management policies, so neither Observe nor Delete would stop
+// its watch stream, probes or provisioning job. Provisioning jobs, which run
+// pre-delete scripts, are only forgotten once the resource is gone.
+func stopOnDelete(watches *watchManager, prober *prober, provisioner *provisioner) handler.EventHandler {
+	return handler.Funcs{
+		UpdateFunc: func(_ context.Context, e event.UpdateEvent, _ workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			if cr, ok := e.ObjectNew.(*v1alpha1.VM); ok && meta.WasDeleted(cr) {
+				watches.Stop(cr)
+				prober.Stop(cr)
+			}
+		},
+		DeleteFunc: func(_ context.Context, e event.DeleteEvent, _ workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			if cr, ok := e.Object.(*v1alpha1.VM); ok {
+				watches.Stop(cr)
+				prober.Stop(cr)
+				provisioner.Forget(cr)
+			}
+		},
+	}
+}
+
+// enqueueVM triggers a reconcile of a VM resource through a channel source.
+func enqueueVM(ctx context.Context, events chan<- event.GenericEvent, nn types.NamespacedName) {
+	select {
+	case events <- event.GenericEvent{Object: &v1alpha1.VM{ObjectMeta: metav1.ObjectMeta{Namespace: nn.Namespace, Name: nn.Name}}}:
+	case <-ctx.Done():
+	}
+}
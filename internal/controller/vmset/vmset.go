@@ -0,0 +1,469 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vmset
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/controller"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/event"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/ratelimiter"
+
+	v1alpha1 "github.com/ravan/provider-orchard/apis/compute/v1alpha1"
+)
+
+const (
+	errGetVMSet        = "cannot get VMSet"
+	errListVMs         = "cannot list VMs owned by VMSet"
+	errCreateVM        = "cannot create VM"
+	errUpdateVM        = "cannot update VM"
+	errDeleteVM        = "cannot delete VM"
+	errUpdateStatus    = "cannot update VMSet status"
+	errResolveStrategy = "cannot resolve update strategy"
+	errMergeTemplate   = "cannot merge template into VM"
+
+	reasonCreateVM = event.Reason("CreateVM")
+	reasonDeleteVM = event.Reason("DeleteVM")
+
+	// defaultMaxUnavailable is used when neither maxUnavailable nor maxSurge
+	// allow any progress.
+	defaultMaxUnavailable = 1
+
+	// reconcileTimeout bounds a single reconcile of a VMSet.
+	reconcileTimeout = 1 * time.Minute
+)
+
+// SetupGated adds a controller that reconciles VMSets with safe-start support.
+func SetupGated(mgr ctrl.Manager, o controller.Options) error {
+	o.Gate.Register(func() {
+		if err := Setup(mgr, o); err != nil {
+			panic(errors.Wrap(err, "cannot setup VMSet controller"))
+		}
+	}, v1alpha1.VMSetGroupVersionKind, v1alpha1.VMGroupVersionKind)
+	return nil
+}
+
+// Setup adds a controller that reconciles VMSets by creating, updating and
+// deleting the VMs they own.
+func Setup(mgr ctrl.Manager, o controller.Options) error {
+	name := "vmset/" + strings.ToLower(v1alpha1.VMSetGroupKind)
+
+	r := &Reconciler{
+		kube:   mgr.GetClient(),
+		log:    o.Logger.WithValues("controller", name),
+		record: event.NewAPIRecorder(mgr.GetEventRecorderFor(name)),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		For(&v1alpha1.VMSet{}).
+		Owns(&v1alpha1.VM{}).
+		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
+}
+
+// A Reconciler reconciles VMSets.
+type Reconciler struct {
+	kube   client.Client
+	log    logging.Logger
+	record event.Recorder
+}
+
+// Reconcile a VMSet by driving its VMs towards the desired replicas and
+// template.
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := r.log.WithValues("request", req)
+	log.Debug("Reconciling")
+
+	ctx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+	defer cancel()
+
+	set := &v1alpha1.VMSet{}
+	if err := r.kube.Get(ctx, req.NamespacedName, set); err != nil {
+		// Owned VMs are garbage collected through their owner references.
+		return reconcile.Result{}, errors.Wrap(client.IgnoreNotFound(err), errGetVMSet)
+	}
+
+	if meta.WasDeleted(set) {
+		return reconcile.Result{}, nil
+	}
+
+	vms := &v1alpha1.VMList{}
+	if err := r.kube.List(ctx, vms, client.InNamespace(set.GetNamespace()), client.MatchingLabels{v1alpha1.LabelVMSetName: set.GetName()}); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, errListVMs)
+	}
+
+	children := make([]*v1alpha1.VM, 0, len(vms.Items))
+	for i := range vms.Items {
+		if metav1.IsControlledBy(&vms.Items[i], set) {
+			children = append(children, &vms.Items[i])
+		}
+	}
+
+	p, err := planRollout(set, children)
+	if err != nil {
+		set.Status.SetConditions(xpv1.ReconcileError(errors.Wrap(err, errResolveStrategy)))
+		return reconcile.Result{}, errors.Wrap(r.kube.Status().Update(ctx, set), errUpdateStatus)
+	}
+
+	if err := r.apply(ctx, set, p); err != nil {
+		log.Debug("Cannot apply rollout", "error", err)
+		set.Status.SetConditions(xpv1.ReconcileError(err))
+		_ = r.kube.Status().Update(ctx, set)
+		return reconcile.Result{}, err
+	}
+
+	updateStatus(set, afterRollout(set, children, p))
+	set.Status.SetConditions(xpv1.ReconcileSuccess())
+	return reconcile.Result{}, errors.Wrap(r.kube.Status().Update(ctx, set), errUpdateStatus)
+}
+
+// apply carries out a rollout plan.
+func (r *Reconciler) apply(ctx context.Context, set *v1alpha1.VMSet, p rollout) error {
+	for _, vm := range p.delete {
+		if err := r.kube.Delete(ctx, vm); client.IgnoreNotFound(err) != nil {
+			return errors.Wrap(err, errDeleteVM)
+		}
+		r.record.Event(set, event.Normal(reasonDeleteVM, fmt.Sprintf("Deleted VM %s", vm.GetName())))
+	}
+
+	for _, vm := range p.update {
+		if err := r.kube.Update(ctx, vm); err != nil {
+			return errors.Wrap(err, errUpdateVM)
+		}
+	}
+
+	for _, ordinal := range p.create {
+		vm := newVM(set, ordinal)
+		if err := r.kube.Create(ctx, vm); err != nil && !kerrors.IsAlreadyExists(err) {
+			return errors.Wrap(err, errCreateVM)
+		}
+		r.record.Event(set, event.Normal(reasonCreateVM, fmt.Sprintf("Created VM %s", vm.GetName())))
+	}
+
+	return nil
+}
+
+// A rollout describes the changes needed to move a VMSet one step closer to
+// its desired state.
+type rollout struct {
+	create []int
+	update []*v1alpha1.VM
+	delete []*v1alpha1.VM
+}
+
+// planRollout decides which VMs to create, update in place and delete. VMs
+// whose template hash is stale are replaced; at no point do fewer than
+// replicas-maxUnavailable VMs stay available or more than replicas+maxSurge
+// VMs exist. Scaling down ignores maxUnavailable, as a Deployment does.
+func planRollout(set *v1alpha1.VMSet, children []*v1alpha1.VM) (rollout, error) {
+	replicas := desiredReplicas(set)
+	maxSurge, maxUnavailable, err := resolveStrategy(set.Spec.UpdateStrategy, replicas)
+	if err != nil {
+		return rollout{}, err
+	}
+
+	hash := templateHash(&set.Spec.Template)
+	p := rollout{}
+
+	var updated, outdated []*v1alpha1.VM
+	used := map[int]bool{}
+	total, ready := 0, 0
+	for _, vm := range children {
+		if ordinal, ok := ordinalOf(set, vm); ok {
+			used[ordinal] = true
+		}
+		total++
+		if isAvailable(vm) {
+			ready++
+		}
+		if meta.WasDeleted(vm) {
+			continue
+		}
+		if vm.GetAnnotations()[v1alpha1.AnnotationTemplateHash] == hash {
+			updated = append(updated, vm)
+			continue
+		}
+		outdated = append(outdated, vm)
+	}
+
+	// Keep non-replacement fields of up to date VMs in sync with the template.
+	// Fields the template leaves unset may have been late-initialized by the
+	// VM controller and are ignored.
+	for _, vm := range updated {
+		if !equality.Semantic.DeepDerivative(set.Spec.Template, vm.Spec.ForProvider) ||
+			!equality.Semantic.DeepEqual(vm.Spec.ProviderConfigReference, set.Spec.ProviderConfigReference) {
+			desired := vm.DeepCopy()
+			if err := mergeTemplate(&set.Spec.Template, &desired.Spec.ForProvider); err != nil {
+				return rollout{}, err
+			}
+			desired.Spec.ProviderConfigReference = set.Spec.ProviderConfigReference.DeepCopy()
+			p.update = append(p.update, desired)
+		}
+	}
+
+	// Delete surplus VMs, preferring outdated and unavailable ones, then the
+	// highest ordinals. Surplus beyond replicas+maxSurge is always removed;
+	// surplus within the surge budget only once the rest stay available.
+	sortForDeletion(set, outdated)
+	sortForDeletion(set, updated)
+	candidates := append(append([]*v1alpha1.VM{}, outdated...), updated...)
+	live := len(outdated) + len(updated)
+	minAvailable := replicas - maxUnavailable
+
+	deleted := map[*v1alpha1.VM]bool{}
+	for _, vm := range candidates {
+		surplus := live > replicas
+		stale := vm.GetAnnotations()[v1alpha1.AnnotationTemplateHash] != hash
+		if !surplus && !stale {
+			break
+		}
+		available := isAvailable(vm)
+		// Scaling down with only up to date VMs is not constrained by
+		// availability. Replacing stale VMs is.
+		if available && (stale || len(outdated) > 0) && ready-1 < minAvailable {
+			continue
+		}
+		p.delete = append(p.delete, vm)
+		deleted[vm] = true
+		live--
+		if available {
+			ready--
+		}
+	}
+
+	// Create up to date VMs in the lowest free ordinals until there are
+	// enough of them, without exceeding the surge budget. VMs being deleted
+	// still count against the budget until Orchard has removed them.
+	remainingUpdated := 0
+	for _, vm := range updated {
+		if !deleted[vm] {
+			remainingUpdated++
+		}
+	}
+	for ordinal := 0; remainingUpdated < replicas && total < replicas+maxSurge; ordinal++ {
+		if used[ordinal] {
+			continue
+		}
+		p.create = append(p.create, ordinal)
+		used[ordinal] = true
+		remainingUpdated++
+		total++
+	}
+
+	return p, nil
+}
+
+// mergeTemplate sets the fields a template sets on a VM's parameters. Fields
+// the template leaves unset keep the VM's values, which may have been
+// late-initialized, so the result is always a DeepDerivative of the template.
+func mergeTemplate(t, params *v1alpha1.VMParameters) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return errors.Wrap(err, errMergeTemplate)
+	}
+	return errors.Wrap(json.Unmarshal(b, params), errMergeTemplate)
+}
+
+// afterRollout returns the VMs a set owns once a rollout was applied, so its
+// status does not lag behind by a reconcile.
+func afterRollout(set *v1alpha1.VMSet, children []*v1alpha1.VM, p rollout) []*v1alpha1.VM {
+	replaced := make(map[string]*v1alpha1.VM, len(p.update))
+	for _, vm := range p.update {
+		replaced[vm.GetName()] = vm
+	}
+	result := make([]*v1alpha1.VM, 0, len(children)+len(p.create))
+	for _, vm := range children {
+		if slices.Contains(p.delete, vm) {
+			continue
+		}
+		if u, ok := replaced[vm.GetName()]; ok {
+			vm = u
+		}
+		result = append(result, vm)
+	}
+	for _, ordinal := range p.create {
+		result = append(result, newVM(set, ordinal))
+	}
+	return result
+}
+
+// updateStatus summarizes the VMs owned by a set.
+func updateStatus(set *v1alpha1.VMSet, children []*v1alpha1.VM) {
+	hash := templateHash(&set.Spec.Template)
+	var current, ready, updated int32
+	for _, vm := range children {
+		if meta.WasDeleted(vm) {
+			continue
+		}
+		current++
+		if isAvailable(vm) {
+			ready++
+		}
+		if vm.GetAnnotations()[v1alpha1.AnnotationTemplateHash] == hash {
+			updated++
+		}
+	}
+
+	set.Status.Replicas = current
+	set.Status.ReadyReplicas = ready
+	set.Status.UpdatedReplicas = updated
+	set.Status.ObservedGeneration = set.GetGeneration()
+
+	replicas := int32(desiredReplicas(set))
+	if ready >= replicas && updated >= replicas && current == replicas {
+		set.Status.SetConditions(xpv1.Available())
+		return
+	}
+	cond := xpv1.Creating()
+	cond.Message = fmt.Sprintf("%d/%d VMs available, %d/%d up to date", ready, replicas, updated, replicas)
+	set.Status.SetConditions(cond)
+}
+
+// newVM returns the VM with the supplied ordinal for a set.
+func newVM(set *v1alpha1.VMSet, ordinal int) *v1alpha1.VM {
+	vm := &v1alpha1.VM{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      vmName(set, ordinal),
+			Namespace: set.GetNamespace(),
+			Labels: map[string]string{
+				v1alpha1.LabelVMSetName: set.GetName(),
+			},
+			Annotations: map[string]string{
+				v1alpha1.AnnotationTemplateHash: templateHash(&set.Spec.Template),
+			},
+		},
+		Spec: v1alpha1.VMSpec{
+			ForProvider: *set.Spec.Template.DeepCopy(),
+		},
+	}
+	vm.Spec.ProviderConfigReference = set.Spec.ProviderConfigReference.DeepCopy()
+	vm.Spec.ManagementPolicies = set.Spec.ManagementPolicies
+	meta.AddOwnerReference(vm, meta.AsController(meta.TypedReferenceTo(set, v1alpha1.VMSetGroupVersionKind)))
+	return vm
+}
+
+// vmName returns the stable name of the VM with the supplied ordinal.
+func vmName(set *v1alpha1.VMSet, ordinal int) string {
+	return fmt.Sprintf("%s-%d", set.GetName(), ordinal)
+}
+
+// ordinalOf extracts the ordinal from a VM name produced by vmName.
+func ordinalOf(set *v1alpha1.VMSet, vm *v1alpha1.VM) (int, bool) {
+	suffix, ok := strings.CutPrefix(vm.GetName(), set.GetName()+"-")
+	if !ok {
+		return 0, false
+	}
+	ordinal, err := strconv.Atoi(suffix)
+	if err != nil || ordinal < 0 {
+		return 0, false
+	}
+	return ordinal, true
+}
+
+// sortForDeletion orders VMs so that unavailable VMs come first, then VMs
+// with higher ordinals.
+func sortForDeletion(set *v1alpha1.VMSet, vms []*v1alpha1.VM) {
+	sort.SliceStable(vms, func(i, j int) bool {
+		ai, aj := isAvailable(vms[i]), isAvailable(vms[j])
+		if ai != aj {
+			return !ai
+		}
+		oi, _ := ordinalOf(set, vms[i])
+		oj, _ := ordinalOf(set, vms[j])
+		return oi > oj
+	})
+}
+
+// isAvailable reports whether the VM controller considers a VM Available,
+// i.e. running with an IP and its startup script finished.
+func isAvailable(vm *v1alpha1.VM) bool {
+	if meta.WasDeleted(vm) {
+		return false
+	}
+	c := vm.GetCondition(xpv1.TypeReady)
+	return c.Status == corev1.ConditionTrue && c.Reason == xpv1.ReasonAvailable
+}
+
+// desiredReplicas returns the desired replica count, defaulting to 1.
+func desiredReplicas(set *v1alpha1.VMSet) int {
+	if set.Spec.Replicas == nil {
+		return 1
+	}
+	return int(*set.Spec.Replicas)
+}
+
+// resolveStrategy returns maxSurge and maxUnavailable as absolute numbers.
+// Surge rounds up and unavailability rounds down, as for Deployments.
+func resolveStrategy(s v1alpha1.VMSetUpdateStrategy, replicas int) (int, int, error) {
+	surge := intstr.FromInt32(0)
+	if s.MaxSurge != nil {
+		surge = *s.MaxSurge
+	}
+	unavailable := intstr.FromInt32(defaultMaxUnavailable)
+	if s.MaxUnavailable != nil {
+		unavailable = *s.MaxUnavailable
+	}
+
+	maxSurge, err := intstr.GetScaledValueFromIntOrPercent(&surge, replicas, true)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "invalid maxSurge")
+	}
+	maxUnavailable, err := intstr.GetScaledValueFromIntOrPercent(&unavailable, replicas, false)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "invalid maxUnavailable")
+	}
+
+	if maxSurge == 0 && maxUnavailable == 0 {
+		maxUnavailable = defaultMaxUnavailable
+	}
+	return maxSurge, maxUnavailable, nil
+}
+
+// templateHash hashes the template fields that cannot be changed without
+// replacing a VM: its image, CPU and memory.
+func templateHash(t *v1alpha1.VMParameters) string {
+	b, _ := json.Marshal(struct {
+		Image  string `json:"image"`
+		CPU    *int32 `json:"cpu,omitempty"`
+		Memory *int32 `json:"memory,omitempty"`
+	}{t.Image, t.CPU, t.Memory})
+	h := fnv.New32a()
+	_, _ = h.Write(b)
+	return strconv.FormatUint(uint64(h.Sum32()), 16)
+}
@@ -0,0 +1,371 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vmset
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	v1alpha1 "github.com/ravan/provider-orchard/apis/compute/v1alpha1"
+)
+
+const (
+	imageOld = "ghcr.io/cirruslabs/macos-sonoma-vanilla:latest"
+	imageNew = "ghcr.io/cirruslabs/macos-sequoia-vanilla:latest"
+)
+
+type vmOption func(*v1alpha1.VM)
+
+func available() vmOption {
+	return func(vm *v1alpha1.VM) { vm.SetConditions(xpv1.Available()) }
+}
+
+func deleting() vmOption {
+	return func(vm *v1alpha1.VM) {
+		now := metav1.NewTime(time.Now())
+		vm.SetDeletionTimestamp(&now)
+	}
+}
+
+func withTemplate(t v1alpha1.VMParameters) vmOption {
+	return func(vm *v1alpha1.VM) { vm.Spec.ForProvider = t }
+}
+
+func vmSet(replicas int32, image string, strategy v1alpha1.VMSetUpdateStrategy) *v1alpha1.VMSet {
+	return &v1alpha1.VMSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "runners", Namespace: "default"},
+		Spec: v1alpha1.VMSetSpec{
+			Replicas:       &replicas,
+			Template:       v1alpha1.VMParameters{Image: image},
+			UpdateStrategy: strategy,
+		},
+	}
+}
+
+func child(ordinal int, image string, o ...vmOption) *v1alpha1.VM {
+	t := v1alpha1.VMParameters{Image: image}
+	vm := &v1alpha1.VM{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("runners-%d", ordinal),
+			Namespace:   "default",
+			Annotations: map[string]string{v1alpha1.AnnotationTemplateHash: templateHash(&t)},
+		},
+		Spec: v1alpha1.VMSpec{ForProvider: t},
+	}
+	for _, fn := range o {
+		fn(vm)
+	}
+	return vm
+}
+
+// plan is a comparable summary of a rollout.
+type plan struct {
+	Create []int
+	Update []string
+	Delete []string
+}
+
+func summarize(r rollout) plan {
+	p := plan{Create: r.create}
+	for _, vm := range r.update {
+		p.Update = append(p.Update, vm.GetName())
+	}
+	for _, vm := range r.delete {
+		p.Delete = append(p.Delete, vm.GetName())
+	}
+	return p
+}
+
+func TestPlanRollout(t *testing.T) {
+	surge := intstr.FromInt32(1)
+	noUnavailable := intstr.FromInt32(0)
+	half := intstr.FromString("50%")
+
+	cases := map[string]struct {
+		reason   string
+		set      *v1alpha1.VMSet
+		children []*v1alpha1.VM
+		want     plan
+	}{
+		"ScaleUpFromZero": {
+			reason: "Should create every missing VM at once",
+			set:    vmSet(3, imageOld, v1alpha1.VMSetUpdateStrategy{}),
+			want:   plan{Create: []int{0, 1, 2}},
+		},
+		"Steady": {
+			reason: "Should do nothing when all VMs are up to date",
+			set:    vmSet(2, imageOld, v1alpha1.VMSetUpdateStrategy{}),
+			children: []*v1alpha1.VM{
+				child(0, imageOld, available()),
+				child(1, imageOld, available()),
+			},
+			want: plan{},
+		},
+		"FillGap": {
+			reason: "Should reuse the lowest free ordinal",
+			set:    vmSet(3, imageOld, v1alpha1.VMSetUpdateStrategy{}),
+			children: []*v1alpha1.VM{
+				child(0, imageOld, available()),
+				child(2, imageOld, available()),
+			},
+			want: plan{Create: []int{1}},
+		},
+		"ScaleDown": {
+			reason: "Should delete the highest ordinals regardless of availability budget",
+			set:    vmSet(1, imageOld, v1alpha1.VMSetUpdateStrategy{}),
+			children: []*v1alpha1.VM{
+				child(0, imageOld, available()),
+				child(1, imageOld, available()),
+				child(2, imageOld, available()),
+			},
+			want: plan{Delete: []string{"runners-2", "runners-1"}},
+		},
+		"ScaleDownPrefersUnavailable": {
+			reason: "Should delete unavailable VMs before available ones",
+			set:    vmSet(2, imageOld, v1alpha1.VMSetUpdateStrategy{}),
+			children: []*v1alpha1.VM{
+				child(0, imageOld),
+				child(1, imageOld, available()),
+				child(2, imageOld, available()),
+			},
+			want: plan{Delete: []string{"runners-0"}},
+		},
+		"RollingDefaultDeletesOne": {
+			reason: "Should replace one VM at a time by default, without surging",
+			set:    vmSet(3, imageNew, v1alpha1.VMSetUpdateStrategy{}),
+			children: []*v1alpha1.VM{
+				child(0, imageOld, available()),
+				child(1, imageOld, available()),
+				child(2, imageOld, available()),
+			},
+			want: plan{Delete: []string{"runners-2"}},
+		},
+		"RollingWaitsForDeletion": {
+			reason: "Should not create a replacement while the old VM is still being deleted",
+			set:    vmSet(3, imageNew, v1alpha1.VMSetUpdateStrategy{}),
+			children: []*v1alpha1.VM{
+				child(0, imageOld, available()),
+				child(1, imageOld, available()),
+				child(2, imageOld, deleting()),
+			},
+			want: plan{},
+		},
+		"RollingCreatesReplacement": {
+			reason: "Should create an up to date VM once the old one is gone",
+			set:    vmSet(3, imageNew, v1alpha1.VMSetUpdateStrategy{}),
+			children: []*v1alpha1.VM{
+				child(0, imageOld, available()),
+				child(1, imageOld, available()),
+			},
+			want: plan{Create: []int{2}},
+		},
+		"RollingWaitsForAvailability": {
+			reason: "Should not take down another VM while the replacement is not yet available",
+			set:    vmSet(3, imageNew, v1alpha1.VMSetUpdateStrategy{}),
+			children: []*v1alpha1.VM{
+				child(0, imageOld, available()),
+				child(1, imageOld, available()),
+				child(2, imageNew),
+			},
+			want: plan{},
+		},
+		"RollingDeletesUnavailableOutdated": {
+			reason: "Should replace outdated VMs that are not available while keeping the rest available",
+			set:    vmSet(2, imageNew, v1alpha1.VMSetUpdateStrategy{}),
+			children: []*v1alpha1.VM{
+				child(0, imageOld),
+				child(1, imageOld, available()),
+			},
+			want: plan{Delete: []string{"runners-0"}},
+		},
+		"SurgeCreatesFirst": {
+			reason: "Should create a replacement above replicas before deleting when surging",
+			set:    vmSet(2, imageNew, v1alpha1.VMSetUpdateStrategy{MaxSurge: &surge, MaxUnavailable: &noUnavailable}),
+			children: []*v1alpha1.VM{
+				child(0, imageOld, available()),
+				child(1, imageOld, available()),
+			},
+			want: plan{Create: []int{2}},
+		},
+		"SurgeDeletesOnceAvailable": {
+			reason: "Should delete an outdated VM once the surged replacement is available",
+			set:    vmSet(2, imageNew, v1alpha1.VMSetUpdateStrategy{MaxSurge: &surge, MaxUnavailable: &noUnavailable}),
+			children: []*v1alpha1.VM{
+				child(0, imageOld, available()),
+				child(1, imageOld, available()),
+				child(2, imageNew, available()),
+			},
+			want: plan{Delete: []string{"runners-1"}},
+		},
+		"PercentageUnavailable": {
+			reason: "Should resolve maxUnavailable percentages against replicas",
+			set:    vmSet(4, imageNew, v1alpha1.VMSetUpdateStrategy{MaxUnavailable: &half}),
+			children: []*v1alpha1.VM{
+				child(0, imageOld, available()),
+				child(1, imageOld, available()),
+				child(2, imageOld, available()),
+				child(3, imageOld, available()),
+			},
+			want: plan{Delete: []string{"runners-3", "runners-2"}},
+		},
+		"InPlaceUpdate": {
+			reason: "Should update VMs in place when only non-replacement fields changed",
+			set: func() *v1alpha1.VMSet {
+				s := vmSet(1, imageOld, v1alpha1.VMSetUpdateStrategy{})
+				s.Spec.Template.Headless = ptr(true)
+				return s
+			}(),
+			children: []*v1alpha1.VM{
+				child(0, imageOld, available(), withTemplate(v1alpha1.VMParameters{Image: imageOld})),
+			},
+			want: plan{Update: []string{"runners-0"}},
+		},
+		"LateInitializedFieldsIgnored": {
+			reason: "Should not revert fields the VM controller late-initialized",
+			set:    vmSet(1, imageOld, v1alpha1.VMSetUpdateStrategy{}),
+			children: []*v1alpha1.VM{
+				child(0, imageOld, available(), withTemplate(v1alpha1.VMParameters{Image: imageOld, DiskSize: ptr(int32(50)), Headless: ptr(false)})),
+			},
+			want: plan{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := planRollout(tc.set, tc.children)
+			if err != nil {
+				t.Fatalf("\n%s\nplanRollout(...): unexpected error: %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, summarize(got), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("\n%s\nplanRollout(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestMergeTemplate(t *testing.T) {
+	cases := map[string]struct {
+		reason   string
+		template v1alpha1.VMParameters
+		params   v1alpha1.VMParameters
+		want     v1alpha1.VMParameters
+	}{
+		"KeepsLateInitializedFields": {
+			reason:   "Should set the fields the template sets and keep those it leaves unset",
+			template: v1alpha1.VMParameters{Image: imageOld, Headless: ptr(true)},
+			params:   v1alpha1.VMParameters{Image: imageOld, Headless: ptr(false), DiskSize: ptr(int32(50)), CPU: ptr(int32(4))},
+			want:     v1alpha1.VMParameters{Image: imageOld, Headless: ptr(true), DiskSize: ptr(int32(50)), CPU: ptr(int32(4))},
+		},
+		"ReplacesLists": {
+			reason:   "Should replace lists the template sets rather than append to them",
+			template: v1alpha1.VMParameters{Image: imageOld, NetSoftnetAllow: []string{"10.0.0.0/8"}},
+			params:   v1alpha1.VMParameters{Image: imageOld, NetSoftnetAllow: []string{"0.0.0.0/0", "::/0"}},
+			want:     v1alpha1.VMParameters{Image: imageOld, NetSoftnetAllow: []string{"10.0.0.0/8"}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if err := mergeTemplate(&tc.template, &tc.params); err != nil {
+				t.Fatalf("\n%s\nmergeTemplate(...): unexpected error: %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, tc.params); diff != "" {
+				t.Errorf("\n%s\nmergeTemplate(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestAfterRollout(t *testing.T) {
+	set := vmSet(2, imageNew, v1alpha1.VMSetUpdateStrategy{})
+	kept, stale := child(0, imageNew, available()), child(1, imageOld, available())
+	updated := kept.DeepCopy()
+	updated.Spec.ForProvider.Headless = ptr(true)
+
+	got := afterRollout(set, []*v1alpha1.VM{kept, stale}, rollout{create: []int{2}, update: []*v1alpha1.VM{updated}, delete: []*v1alpha1.VM{stale}})
+
+	names := make([]string, 0, len(got))
+	for _, vm := range got {
+		names = append(names, vm.GetName())
+	}
+	if diff := cmp.Diff([]string{"runners-0", "runners-2"}, names); diff != "" {
+		t.Errorf("afterRollout(...): -want VMs, +got VMs:\n%s\n", diff)
+	}
+	if got[0] != updated {
+		t.Errorf("afterRollout(...): want the updated VM in place of the one read before the rollout")
+	}
+
+	updateStatus(set, got)
+	want := v1alpha1.VMSetStatus{Replicas: 2, ReadyReplicas: 1, UpdatedReplicas: 2}
+	if diff := cmp.Diff(want, set.Status, cmpopts.IgnoreFields(v1alpha1.VMSetStatus{}, "ConditionedStatus")); diff != "" {
+		t.Errorf("updateStatus(afterRollout(...)): -want, +got:\n%s\n", diff)
+	}
+}
+
+func TestUpdateStatus(t *testing.T) {
+	cases := map[string]struct {
+		reason   string
+		set      *v1alpha1.VMSet
+		children []*v1alpha1.VM
+		want     v1alpha1.VMSetStatus
+		ready    xpv1.ConditionReason
+	}{
+		"AllAvailable": {
+			reason: "Should report Available when every VM is up to date and available",
+			set:    vmSet(2, imageOld, v1alpha1.VMSetUpdateStrategy{}),
+			children: []*v1alpha1.VM{
+				child(0, imageOld, available()),
+				child(1, imageOld, available()),
+			},
+			want:  v1alpha1.VMSetStatus{Replicas: 2, ReadyReplicas: 2, UpdatedReplicas: 2},
+			ready: xpv1.ReasonAvailable,
+		},
+		"RollingUpdate": {
+			reason: "Should count outdated VMs as ready but not updated",
+			set:    vmSet(2, imageNew, v1alpha1.VMSetUpdateStrategy{}),
+			children: []*v1alpha1.VM{
+				child(0, imageOld, available()),
+				child(1, imageNew),
+			},
+			want:  v1alpha1.VMSetStatus{Replicas: 2, ReadyReplicas: 1, UpdatedReplicas: 1},
+			ready: xpv1.ReasonCreating,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			updateStatus(tc.set, tc.children)
+			if diff := cmp.Diff(tc.want, tc.set.Status, cmpopts.IgnoreFields(v1alpha1.VMSetStatus{}, "ConditionedStatus")); diff != "" {
+				t.Errorf("\n%s\nupdateStatus(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if got := tc.set.Status.GetCondition(xpv1.TypeReady).Reason; got != tc.ready {
+				t.Errorf("\n%s\nupdateStatus(...): want Ready reason %q, got %q", tc.reason, tc.ready, got)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
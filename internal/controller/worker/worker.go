@@ -0,0 +1,245 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/feature"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+
+	"github.com/pkg/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/controller"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/event"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/ratelimiter"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/statemetrics"
+
+	v1alpha1 "github.com/ravan/provider-orchard/apis/compute/v1alpha1"
+	apisv1alpha1 "github.com/ravan/provider-orchard/apis/v1alpha1"
+	orchardclient "github.com/ravan/provider-orchard/internal/clients/orchard"
+)
+
+const (
+	errNotWorker    = "managed resource is not a Worker custom resource"
+	errTrackPCUsage = "cannot track ProviderConfig usage"
+
+	errNewClient    = "cannot create new Orchard client"
+	errGetWorker    = "cannot get worker"
+	errDeleteWorker = "cannot delete worker"
+	errCreateWorker = "workers cannot be created through the Orchard API; import an existing worker with the Observe management policy"
+)
+
+// SetupGated adds a controller that reconciles Worker managed resources with safe-start support.
+func SetupGated(mgr ctrl.Manager, o controller.Options) error {
+	o.Gate.Register(func() {
+		if err := Setup(mgr, o); err != nil {
+			panic(errors.Wrap(err, "cannot setup Worker controller"))
+		}
+	}, v1alpha1.WorkerGroupVersionKind)
+	return nil
+}
+
+func Setup(mgr ctrl.Manager, o controller.Options) error {
+	name := managed.ControllerName(v1alpha1.WorkerGroupKind)
+
+	opts := []managed.ReconcilerOption{
+		managed.WithExternalConnector(&connector{
+			kube:  mgr.GetClient(),
+			usage: resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
+		}),
+		managed.WithLogger(o.Logger.WithValues("controller", name)),
+		managed.WithPollInterval(o.PollInterval),
+		managed.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+	}
+
+	if o.Features.Enabled(feature.EnableBetaManagementPolicies) {
+		opts = append(opts, managed.WithManagementPolicies())
+	}
+
+	if o.Features.Enabled(feature.EnableAlphaChangeLogs) {
+		opts = append(opts, managed.WithChangeLogger(o.ChangeLogOptions.ChangeLogger))
+	}
+
+	if o.MetricOptions != nil {
+		opts = append(opts, managed.WithMetricRecorder(o.MetricOptions.MRMetrics))
+	}
+
+	if o.MetricOptions != nil && o.MetricOptions.MRStateMetrics != nil {
+		stateMetricsRecorder := statemetrics.NewMRStateRecorder(
+			mgr.GetClient(), o.Logger, o.MetricOptions.MRStateMetrics, &v1alpha1.WorkerList{}, o.MetricOptions.PollStateMetricInterval,
+		)
+		if err := mgr.Add(stateMetricsRecorder); err != nil {
+			return errors.Wrap(err, "cannot register MR state metrics recorder for kind v1alpha1.WorkerList")
+		}
+	}
+
+	r := managed.NewReconciler(mgr, resource.ManagedKind(v1alpha1.WorkerGroupVersionKind), opts...)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		WithEventFilter(resource.DesiredStateChanged()).
+		For(&v1alpha1.Worker{}).
+		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
+}
+
+// A connector is expected to produce an ExternalClient when its Connect method
+// is called.
+type connector struct {
+	kube  client.Client
+	usage *resource.ProviderConfigUsageTracker
+}
+
+// Connect tracks ProviderConfig usage and builds an Orchard client from the
+// credentials of the referenced ProviderConfig.
+func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.ExternalClient, error) {
+	cr, ok := mg.(*v1alpha1.Worker)
+	if !ok {
+		return nil, errors.New(errNotWorker)
+	}
+
+	if err := c.usage.Track(ctx, cr); err != nil {
+		return nil, errors.Wrap(err, errTrackPCUsage)
+	}
+
+	cfg, err := orchardclient.GetConfig(ctx, c.kube, cr)
+	if err != nil {
+		return nil, err
+	}
+
+	orchardClient, err := orchardclient.NewOrchardClient(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, errNewClient)
+	}
+
+	return &external{client: orchardClient}, nil
+}
+
+// An ExternalClient observes, then either creates, updates, or deletes an
+// external resource to ensure it reflects the managed resource's desired state.
+type external struct {
+	client *orchardclient.OrchardClient
+}
+
+func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
+	cr, ok := mg.(*v1alpha1.Worker)
+	if !ok {
+		return managed.ExternalObservation{}, errors.New(errNotWorker)
+	}
+
+	workerName := meta.GetExternalName(cr)
+	if workerName == "" {
+		return managed.ExternalObservation{ResourceExists: false}, nil
+	}
+
+	resp, err := c.client.GetWorkersName(ctx, workerName)
+	if err != nil {
+		return managed.ExternalObservation{}, errors.Wrap(err, errGetWorker)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return managed.ExternalObservation{ResourceExists: false}, nil
+	case http.StatusOK:
+		var worker orchardclient.Worker
+		if err := json.NewDecoder(resp.Body).Decode(&worker); err != nil {
+			return managed.ExternalObservation{}, errors.Wrap(err, "cannot decode worker response")
+		}
+
+		updateWorkerStatus(cr, &worker)
+		cr.SetConditions(xpv1.Available())
+
+		// A worker has no desired state beyond its existence.
+		return managed.ExternalObservation{
+			ResourceExists:   true,
+			ResourceUpToDate: true,
+		}, nil
+	default:
+		return managed.ExternalObservation{}, errors.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+}
+
+// updateWorkerStatus updates the CR status fields from the worker response
+func updateWorkerStatus(cr *v1alpha1.Worker, worker *orchardclient.Worker) {
+	if worker.Name != nil {
+		cr.Status.AtProvider.Name = *worker.Name
+	}
+	cr.Status.AtProvider.Resources = nil
+	if worker.Resources != nil {
+		cr.Status.AtProvider.Resources = *worker.Resources
+	}
+}
+
+func (c *external) Create(ctx context.Context, mg resource.Managed) (managed.ExternalCreation, error) {
+	if _, ok := mg.(*v1alpha1.Worker); !ok {
+		return managed.ExternalCreation{}, errors.New(errNotWorker)
+	}
+
+	// Workers join the cluster by running `orchard worker run`; the API has
+	// no endpoint to create one.
+	return managed.ExternalCreation{}, errors.New(errCreateWorker)
+}
+
+func (c *external) Update(ctx context.Context, mg resource.Managed) (managed.ExternalUpdate, error) {
+	if _, ok := mg.(*v1alpha1.Worker); !ok {
+		return managed.ExternalUpdate{}, errors.New(errNotWorker)
+	}
+
+	// Nothing to update - Observe always reports workers as up to date.
+	return managed.ExternalUpdate{}, nil
+}
+
+func (c *external) Delete(ctx context.Context, mg resource.Managed) (managed.ExternalDelete, error) {
+	cr, ok := mg.(*v1alpha1.Worker)
+	if !ok {
+		return managed.ExternalDelete{}, errors.New(errNotWorker)
+	}
+
+	workerName := meta.GetExternalName(cr)
+	if workerName == "" {
+		// Nothing to delete
+		return managed.ExternalDelete{}, nil
+	}
+
+	cr.SetConditions(xpv1.Deleting())
+
+	resp, err := c.client.DeleteWorkersName(ctx, workerName)
+	if err != nil {
+		return managed.ExternalDelete{}, errors.Wrap(err, errDeleteWorker)
+	}
+	defer resp.Body.Close()
+
+	// 404 is acceptable - worker is already gone
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return managed.ExternalDelete{}, errors.Errorf("unexpected status code deleting worker: %d", resp.StatusCode)
+	}
+
+	return managed.ExternalDelete{}, nil
+}
+
+func (c *external) Disconnect(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,206 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1alpha1 "github.com/ravan/provider-orchard/apis/compute/v1alpha1"
+	orchardclient "github.com/ravan/provider-orchard/internal/clients/orchard"
+)
+
+// Mock HTTP client for testing
+type mockHTTPClient struct {
+	DoFunc func(req *http.Request) (*http.Response, error)
+}
+
+func (m *mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	return m.DoFunc(req)
+}
+
+// newMockOrchardClient creates an OrchardClient with a mock HTTP client for testing
+func newMockOrchardClient(httpClient *mockHTTPClient) *orchardclient.OrchardClient {
+	client, err := orchardclient.NewClientWithResponses(
+		"http://localhost:6120",
+		orchardclient.WithHTTPClient(httpClient),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return &orchardclient.OrchardClient{
+		ClientWithResponses: client,
+	}
+}
+
+func respondWith(status int, body []byte) *mockHTTPClient {
+	return &mockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: status,
+				Body:       io.NopCloser(bytes.NewBuffer(body)),
+			}, nil
+		},
+	}
+}
+
+func newWorker(name string) *v1alpha1.Worker {
+	w := &v1alpha1.Worker{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+	}
+	meta.SetExternalName(w, name)
+	return w
+}
+
+func TestObserve(t *testing.T) {
+	workerName := "mac-mini-1"
+
+	type want struct {
+		o         managed.ExternalObservation
+		resources map[string]int
+		err       error
+	}
+
+	cases := map[string]struct {
+		reason string
+		client *orchardclient.OrchardClient
+		mg     resource.Managed
+		want   want
+	}{
+		"NoExternalName": {
+			reason: "Should return ResourceExists=false if external name is not set",
+			mg:     &v1alpha1.Worker{ObjectMeta: metav1.ObjectMeta{Name: workerName}},
+			want: want{
+				o: managed.ExternalObservation{ResourceExists: false},
+			},
+		},
+		"WorkerNotFound": {
+			reason: "Should return ResourceExists=false if the worker is not registered",
+			client: newMockOrchardClient(respondWith(http.StatusNotFound, nil)),
+			mg:     newWorker(workerName),
+			want: want{
+				o: managed.ExternalObservation{ResourceExists: false},
+			},
+		},
+		"WorkerExists": {
+			reason: "Should report the worker as existing and up to date and copy its resources to status",
+			client: newMockOrchardClient(respondWith(http.StatusOK, func() []byte {
+				body, _ := json.Marshal(orchardclient.Worker{
+					Name:      &workerName,
+					Resources: &map[string]int{"org.cirruslabs.tart-vms": 2},
+				})
+				return body
+			}())),
+			mg: newWorker(workerName),
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:   true,
+					ResourceUpToDate: true,
+				},
+				resources: map[string]int{"org.cirruslabs.tart-vms": 2},
+			},
+		},
+		"UnexpectedStatus": {
+			reason: "Should return an error on an unexpected status code",
+			client: newMockOrchardClient(respondWith(http.StatusInternalServerError, nil)),
+			mg:     newWorker(workerName),
+			want: want{
+				err: errors.Errorf("unexpected status code: %d", http.StatusInternalServerError),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := &external{client: tc.client}
+			got, err := e.Observe(context.Background(), tc.mg)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.o, got); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			cr := tc.mg.(*v1alpha1.Worker)
+			if diff := cmp.Diff(tc.want.resources, cr.Status.AtProvider.Resources); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want resources, +got resources:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	e := &external{}
+	_, err := e.Create(context.Background(), newWorker("mac-mini-1"))
+	if diff := cmp.Diff(errors.New(errCreateWorker), err, test.EquateErrors()); diff != "" {
+		t.Errorf("e.Create(...): -want error, +got error:\n%s\n", diff)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		client *orchardclient.OrchardClient
+		mg     resource.Managed
+		want   error
+	}{
+		"NoExternalName": {
+			reason: "Should return nil if external name is not set (nothing to delete)",
+			mg:     &v1alpha1.Worker{ObjectMeta: metav1.ObjectMeta{Name: "mac-mini-1"}},
+		},
+		"SuccessfulDelete": {
+			reason: "Should decommission the worker",
+			client: newMockOrchardClient(respondWith(http.StatusOK, nil)),
+			mg:     newWorker("mac-mini-1"),
+		},
+		"AlreadyDeleted": {
+			reason: "Should handle 404 gracefully (already deleted)",
+			client: newMockOrchardClient(respondWith(http.StatusNotFound, nil)),
+			mg:     newWorker("mac-mini-1"),
+		},
+		"DeleteError": {
+			reason: "Should return error on unexpected status code",
+			client: newMockOrchardClient(respondWith(http.StatusInternalServerError, nil)),
+			mg:     newWorker("mac-mini-1"),
+			want:   errors.Errorf("unexpected status code deleting worker: %d", http.StatusInternalServerError),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := &external{client: tc.client}
+			_, err := e.Delete(context.Background(), tc.mg)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Delete(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -81,6 +81,14 @@ type CommandResult struct {
 
 	// Stderr contains the standard error output
 	Stderr string
+
+	// Output holds what a script wrote to its output file, if
+	// ScriptOptions.OutputVar was set
+	Output string
+
+	// Marked is true if a script created its marker file, if
+	// ScriptOptions.MarkerVar was set
+	Marked bool
 }
 
 // FileUploadOptions configures file upload behavior
@@ -94,3 +102,86 @@ type FileUploadOptions struct {
 	// CreateDirs creates parent directories if they don't exist
 	CreateDirs bool
 }
+
+// DefaultEscalationCommand runs a command as another user with sudo, which
+// reads the password from stdin if it asks for one
+const DefaultEscalationCommand = "sudo -S -p '' -H -u %s --"
+
+// A PasswordMode is how an escalation command is given the password
+type PasswordMode int
+
+const (
+	// PasswordStdin writes the password, followed by a newline, to the
+	// escalation command's stdin. The command that runs never sees it.
+	PasswordStdin PasswordMode = iota
+
+	// PasswordNone gives the escalation command no password. It must not
+	// ask for one.
+	PasswordNone
+)
+
+// Escalation configures how commands run as another user
+type Escalation struct {
+	// Command runs the command that follows it as another user, whose
+	// quoted name replaces %s (default: DefaultEscalationCommand)
+	Command string
+
+	// Password is how Command is given the password (default:
+	// PasswordStdin)
+	Password PasswordMode
+}
+
+// SudoOptions configures how a command runs as another user
+type SudoOptions struct {
+	// User the command runs as (default: root)
+	User string
+
+	// Password is given to the escalation command if it takes one
+	// (default: the SSH password)
+	Password string
+
+	// Escalation runs the command as User (default: sudo, given the
+	// password on stdin)
+	Escalation Escalation
+}
+
+// ScriptOptions configures how a script runs on the VM
+type ScriptOptions struct {
+	// Env holds environment variables for the script
+	Env map[string]string
+
+	// Interpreter runs the script, e.g. "/bin/zsh" or "/usr/bin/env python3".
+	// The script runs by its shebang if empty.
+	Interpreter string
+
+	// WorkingDir is the directory the script runs in (default: the home
+	// directory of the SSH user)
+	WorkingDir string
+
+	// RunAs runs the script as another user, with Escalation (default: the
+	// SSH user)
+	RunAs string
+
+	// SudoPassword is given to the escalation command if it takes one
+	// (default: the SSH password)
+	SudoPassword string
+
+	// Escalation runs the script as RunAs (default: sudo, given the password
+	// on stdin)
+	Escalation Escalation
+
+	// PathPrefix is where the script is uploaded, followed by a unique suffix
+	// (default: "/tmp/orchard-script")
+	PathPrefix string
+
+	// OutputVar, if set, names an environment variable that holds the path
+	// of an empty file the script may write to. Only the user the script
+	// runs as may read or write it. The file is read into
+	// CommandResult.Output after the script exits, then removed.
+	OutputVar string
+
+	// MarkerVar, if set, names an environment variable that holds the path
+	// of a file the script may create to signal the caller. Whether it did
+	// is reported in CommandResult.Marked.
+	MarkerVar string
+}
@@ -19,11 +19,14 @@ package ssh
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -36,8 +39,13 @@ type VMSession interface {
 	// ExecuteCommand runs a command on the VM and returns the result.
 	ExecuteCommand(ctx context.Context, command string) (*CommandResult, error)
 
-	// ExecuteScript runs a multi-line script on the VM with optional environment variables.
-	ExecuteScript(ctx context.Context, script string, env map[string]string) (*CommandResult, error)
+	// ExecuteCommandAs runs a shell command on the VM as another user, with
+	// sudo, and returns the result.
+	ExecuteCommandAs(ctx context.Context, command string, opts SudoOptions) (*CommandResult, error)
+
+	// ExecuteScript uploads a script to a unique path on the VM, runs it with
+	// the supplied options and removes it.
+	ExecuteScript(ctx context.Context, script string, opts ScriptOptions) (*CommandResult, error)
 
 	// UploadFile uploads content to a file on the VM.
 	UploadFile(ctx context.Context, content io.Reader, opts FileUploadOptions) error
@@ -102,18 +110,34 @@ func NewVMSession(ctx context.Context, config TunnelConfig) (VMSession, error) {
 
 // ExecuteCommand runs a single command on the VM.
 func (s *vmSession) ExecuteCommand(ctx context.Context, command string) (*CommandResult, error) {
+	return s.execute(ctx, command, nil)
+}
+
+// ExecuteCommandAs runs a shell command on the VM as another user, with sudo.
+// sudo is given the password if it asks for one.
+func (s *vmSession) ExecuteCommandAs(ctx context.Context, command string, opts SudoOptions) (*CommandResult, error) {
+	user := opts.User
+	if user == "" {
+		user = "root"
+	}
+	return s.execute(ctx, sudoCommand(opts.Escalation, user, command), s.sudoPassword(opts.Escalation, opts.Password))
+}
+
+// execute runs a single command on the VM, feeding it the supplied stdin.
+func (s *vmSession) execute(ctx context.Context, command string, stdin io.Reader) (*CommandResult, error) {
 	session, err := s.sshClient.NewSession()
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create SSH session")
+		return nil, errors.Wrapf(ErrConnectionFailed, "failed to create SSH session: %v", err)
 	}
 	defer session.Close()
 
 	var stdout, stderr bytes.Buffer
 	session.Stdout = &stdout
 	session.Stderr = &stderr
+	session.Stdin = stdin
 
 	// Run the command
-	err = session.Run(command)
+	err = runSession(ctx, session, command)
 
 	result := &CommandResult{
 		Stdout: stdout.String(),
@@ -125,55 +149,237 @@ func (s *vmSession) ExecuteCommand(ctx context.Context, command string) (*Comman
 			result.ExitCode = exitErr.ExitStatus()
 			return result, nil // Non-zero exit is not an error, just captured in result
 		}
-		return result, errors.Wrap(err, "command execution failed")
+		if errors.Is(err, ErrTimeout) {
+			return result, err
+		}
+		// The connection was lost before the command exited
+		return result, errors.Wrapf(ErrConnectionFailed, "command execution failed: %v", err)
 	}
 
 	result.ExitCode = 0
 	return result, nil
 }
 
-// ExecuteScript runs a multi-line script on the VM with optional environment variables.
-func (s *vmSession) ExecuteScript(ctx context.Context, script string, env map[string]string) (*CommandResult, error) {
-	session, err := s.sshClient.NewSession()
+// ExecuteScript uploads a script to a unique path on the VM, runs it with the
+// supplied options and removes it. Scripts run as another user are given the
+// SSH password if sudo asks for one.
+func (s *vmSession) ExecuteScript(ctx context.Context, script string, opts ScriptOptions) (*CommandResult, error) {
+	path, err := uniqueScriptPath(opts.PathPrefix)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create SSH session")
+		return nil, err
+	}
+	if err := s.UploadBytes(ctx, []byte(script), FileUploadOptions{
+		RemotePath:  path,
+		Permissions: 0755,
+		CreateDirs:  true,
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to upload script")
 	}
-	defer session.Close()
 
-	// Build script with environment variables
-	var scriptBuilder strings.Builder
-	scriptBuilder.WriteString("#!/bin/bash\nset -e\n")
-	for key, value := range env {
-		// Escape the value for shell
-		escapedValue := strings.ReplaceAll(value, "'", "'\"'\"'")
-		scriptBuilder.WriteString(fmt.Sprintf("export %s='%s'\n", key, escapedValue))
+	env := make(map[string]string, len(opts.Env)+2)
+	for k, v := range opts.Env {
+		env[k] = v
+	}
+	outputPath, markerPath := "", ""
+	if opts.OutputVar != "" {
+		outputPath = strings.TrimSuffix(path, ".sh") + ".out"
+		if err := s.createOutputFile(ctx, outputPath, opts); err != nil {
+			return nil, err
+		}
+		env[opts.OutputVar] = outputPath
+	}
+	if opts.MarkerVar != "" {
+		markerPath = strings.TrimSuffix(path, ".sh") + ".marker"
+		env[opts.MarkerVar] = markerPath
+	}
+	if len(env) > 0 {
+		opts.Env = env
 	}
-	scriptBuilder.WriteString(script)
 
-	var stdout, stderr bytes.Buffer
-	session.Stdout = &stdout
-	session.Stderr = &stderr
+	var stdin io.Reader
+	if opts.RunAs != "" {
+		stdin = s.sudoPassword(opts.Escalation, opts.SudoPassword)
+	}
+	result, err := s.execute(ctx, scriptCommand(path, opts), stdin)
+	if err != nil {
+		return result, err
+	}
+	if outputPath != "" {
+		output, err := s.takeOutputFile(ctx, outputPath, opts)
+		if err != nil {
+			return result, err
+		}
+		result.Output = output
+	}
+	if markerPath != "" {
+		marked, err := s.exists(markerPath)
+		if err != nil {
+			return result, err
+		}
+		result.Marked = marked
+		// Markers created by another user may not be removable. Their path
+		// is unique to this run, so a leftover marker is harmless.
+		_ = s.sftpClient.Remove(markerPath)
+	}
+	return result, nil
+}
 
-	// Use stdin to pass the script
-	session.Stdin = strings.NewReader(scriptBuilder.String())
+// createOutputFile creates the empty file a script writes its outputs to.
+// Only the user the script runs as may write to it, so no other user of the
+// VM can tamper with the outputs. Files of scripts run as another user are
+// created by that user, through sudo. Creating the file fails if it exists.
+func (s *vmSession) createOutputFile(ctx context.Context, path string, opts ScriptOptions) error {
+	if opts.RunAs == "" {
+		if err := s.ensureSFTP(); err != nil {
+			return err
+		}
+		f, err := s.sftpClient.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+		if err != nil {
+			return errors.Wrapf(ErrSFTPFailed, "failed to create output file %s: %v", path, err)
+		}
+		_ = f.Close()
+		if err := s.sftpClient.Chmod(path, 0600); err != nil {
+			return errors.Wrapf(ErrSFTPFailed, "failed to set permissions of output file %s: %v", path, err)
+		}
+		return nil
+	}
 
-	err = session.Run("/bin/bash")
+	result, err := s.execute(ctx, sudoCommand(opts.Escalation, opts.RunAs, createFileCommand(path)), s.sudoPassword(opts.Escalation, opts.SudoPassword))
+	if err != nil {
+		return errors.Wrap(err, "failed to create output file")
+	}
+	if result.ExitCode != 0 {
+		return errors.Errorf("failed to create output file %s: %s", path, strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
 
-	result := &CommandResult{
-		Stdout: stdout.String(),
-		Stderr: stderr.String(),
+// takeOutputFile returns the content of a script's output file and removes
+// it. Files of scripts run as another user are read and removed by that
+// user, as only they may read them.
+func (s *vmSession) takeOutputFile(ctx context.Context, path string, opts ScriptOptions) (string, error) {
+	if opts.RunAs == "" {
+		output, err := s.readFile(path)
+		if err != nil {
+			return "", err
+		}
+		if err := s.sftpClient.Remove(path); err != nil {
+			return "", errors.Wrapf(ErrSFTPFailed, "failed to remove file %s: %v", path, err)
+		}
+		return string(output), nil
 	}
 
+	result, err := s.execute(ctx, sudoCommand(opts.Escalation, opts.RunAs, "cat "+shellQuote(path)+" && rm -f "+shellQuote(path)), s.sudoPassword(opts.Escalation, opts.SudoPassword))
 	if err != nil {
-		if exitErr, ok := err.(*ssh.ExitError); ok {
-			result.ExitCode = exitErr.ExitStatus()
-			return result, nil
-		}
-		return result, errors.Wrap(err, "script execution failed")
+		return "", errors.Wrap(err, "failed to read output file")
+	}
+	if result.ExitCode != 0 {
+		return "", errors.Errorf("failed to read output file %s: %s", path, strings.TrimSpace(result.Stderr))
 	}
+	return result.Stdout, nil
+}
 
-	result.ExitCode = 0
-	return result, nil
+// sudoPassword returns the stdin of an escalation command: the supplied
+// password, or the SSH password if none is supplied, if the escalation
+// takes one on stdin.
+func (s *vmSession) sudoPassword(esc Escalation, password string) io.Reader {
+	if esc.Password == PasswordNone {
+		return nil
+	}
+	if password == "" {
+		password = s.config.SSHPassword
+	}
+	return strings.NewReader(password + "\n")
+}
+
+// sudoCommand returns a command that runs a shell command as another user
+// with the supplied escalation. The command runs with stdin closed, so it
+// never sees a password written to it.
+func sudoCommand(esc Escalation, user, command string) string {
+	escalate := esc.Command
+	if escalate == "" {
+		escalate = DefaultEscalationCommand
+	}
+	return fmt.Sprintf(escalate, shellQuote(user)) + " /bin/sh -c " + shellQuote("exec </dev/null; "+command)
+}
+
+// createFileCommand creates an empty file only its owner may read and
+// write, failing if the file exists.
+func createFileCommand(path string) string {
+	return "umask 077 && set -C && : > " + shellQuote(path)
+}
+
+// scriptCommand returns the command that runs the script at the supplied
+// path with the supplied options, then removes it. Scripts run as another
+// user read the sudo password from stdin, and run with stdin closed so they
+// never see it.
+func scriptCommand(path string, opts ScriptOptions) string {
+	var b strings.Builder
+	keys := make([]string, 0, len(opts.Env))
+	for k := range opts.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "export %s=%s; ", k, shellQuote(opts.Env[k]))
+	}
+	if opts.WorkingDir != "" {
+		fmt.Fprintf(&b, "cd %s && ", shellQuote(opts.WorkingDir))
+	}
+	if opts.Interpreter != "" {
+		b.WriteString(opts.Interpreter + " ")
+	}
+	b.WriteString(shellQuote(path))
+
+	run := "(" + b.String() + ")"
+	if opts.RunAs != "" {
+		run = sudoCommand(opts.Escalation, opts.RunAs, b.String())
+	}
+	return run + "; status=$?; rm -f " + shellQuote(path) + "; exit $status"
+}
+
+// uniqueScriptPath returns a path for a script that no other run uses.
+func uniqueScriptPath(prefix string) (string, error) {
+	if prefix == "" {
+		prefix = "/tmp/orchard-script"
+	}
+	suffix, err := UniqueSuffix()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate script path")
+	}
+	return prefix + "-" + suffix + ".sh", nil
+}
+
+// UniqueSuffix returns a random suffix for the names of files uploaded to
+// shared directories, so other users of the VM cannot predict them.
+func UniqueSuffix() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// shellQuote quotes a string for use as a single shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+// runSession runs a command in an SSH session. If the context is done first,
+// the command is killed and ErrTimeout is returned.
+func runSession(ctx context.Context, session *ssh.Session, command string) error {
+	done := make(chan error, 1)
+	go func() { done <- session.Run(command) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		_ = session.Signal(ssh.SIGKILL)
+		_ = session.Close()
+		<-done
+		return errors.Wrap(ErrTimeout, ctx.Err().Error())
+	}
 }
 
 // ensureSFTP initializes the SFTP client if not already done.
@@ -226,6 +432,37 @@ func (s *vmSession) UploadFile(ctx context.Context, content io.Reader, opts File
 	return nil
 }
 
+// readFile returns the content of a file on the VM.
+func (s *vmSession) readFile(path string) ([]byte, error) {
+	if err := s.ensureSFTP(); err != nil {
+		return nil, err
+	}
+	f, err := s.sftpClient.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(ErrSFTPFailed, "failed to open file %s: %v", path, err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, errors.Wrapf(ErrSFTPFailed, "failed to read file %s: %v", path, err)
+	}
+	return data, nil
+}
+
+// exists returns true if a file exists on the VM.
+func (s *vmSession) exists(path string) (bool, error) {
+	if err := s.ensureSFTP(); err != nil {
+		return false, err
+	}
+	if _, err := s.sftpClient.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(ErrSFTPFailed, "failed to stat file %s: %v", path, err)
+	}
+	return true, nil
+}
+
 // UploadBytes is a convenience method for uploading byte content.
 func (s *vmSession) UploadBytes(ctx context.Context, data []byte, opts FileUploadOptions) error {
 	return s.UploadFile(ctx, bytes.NewReader(data), opts)
@@ -261,14 +498,14 @@ func (s *vmSession) Close() error {
 
 // RunCloudInit executes cloud-init style initialization on a VM.
 // This is a convenience function that creates a session, runs the script, and closes.
-func RunCloudInit(ctx context.Context, config TunnelConfig, script string, env map[string]string) (*CommandResult, error) {
+func RunCloudInit(ctx context.Context, config TunnelConfig, script string, opts ScriptOptions) (*CommandResult, error) {
 	session, err := NewVMSession(ctx, config)
 	if err != nil {
 		return nil, err
 	}
 	defer session.Close()
 
-	return session.ExecuteScript(ctx, script, env)
+	return session.ExecuteScript(ctx, script, opts)
 }
 
 // UploadAndRunScript uploads a script file and executes it.
@@ -280,23 +517,5 @@ func UploadAndRunScript(ctx context.Context, config TunnelConfig, script string,
 	}
 	defer session.Close()
 
-	// Upload script to temp location
-	scriptPath := "/tmp/cloudinit-script.sh"
-	if err := session.UploadBytes(ctx, []byte(script), FileUploadOptions{
-		RemotePath:  scriptPath,
-		Permissions: 0755,
-		CreateDirs:  true,
-	}); err != nil {
-		return nil, errors.Wrap(err, "failed to upload script")
-	}
-
-	// Build command with environment variables
-	var cmdBuilder strings.Builder
-	for key, value := range env {
-		escapedValue := strings.ReplaceAll(value, "'", "'\"'\"'")
-		cmdBuilder.WriteString(fmt.Sprintf("export %s='%s'; ", key, escapedValue))
-	}
-	cmdBuilder.WriteString(scriptPath)
-
-	return session.ExecuteCommand(ctx, cmdBuilder.String())
+	return session.ExecuteScript(ctx, script, ScriptOptions{Env: env})
 }
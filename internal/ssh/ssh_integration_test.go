@@ -440,7 +440,7 @@ echo "Done"
 		"MY_VAR": "test-value",
 	}
 
-	result, err := session.ExecuteScript(ctx, script, env)
+	result, err := session.ExecuteScript(ctx, script, ScriptOptions{Env: env})
 	if err != nil {
 		t.Fatalf("ExecuteScript failed: %v", err)
 	}
@@ -519,6 +519,99 @@ echo "Environment: $TEST_ENV"
 	t.Logf("Script output: %s", result.Stdout)
 }
 
+func TestIntegration_ExecuteScriptOptions(t *testing.T) {
+	config := getTestConfig(t)
+	ctx := context.Background()
+
+	session, err := NewVMSession(ctx, config)
+	if err != nil {
+		t.Fatalf("NewVMSession failed: %v", err)
+	}
+	defer session.Close()
+
+	script := `pwd
+whoami
+echo "Environment: $TEST_ENV"
+`
+	result, err := session.ExecuteScript(ctx, script, ScriptOptions{
+		Env:         map[string]string{"TEST_ENV": "integration-test"},
+		Interpreter: "/bin/sh",
+		WorkingDir:  "/tmp",
+		RunAs:       "root",
+	})
+	if err != nil {
+		t.Fatalf("ExecuteScript failed: %v", err)
+	}
+
+	if result.ExitCode != 0 {
+		t.Errorf("ExitCode = %d, want 0\nStderr: %s", result.ExitCode, result.Stderr)
+	}
+
+	expected := "/tmp\nroot\nEnvironment: integration-test\n"
+	if result.Stdout != expected {
+		t.Errorf("Stdout = %q, want %q", result.Stdout, expected)
+	}
+}
+
+func TestIntegration_ExecuteScriptOutput(t *testing.T) {
+	config := getTestConfig(t)
+	ctx := context.Background()
+
+	session, err := NewVMSession(ctx, config)
+	if err != nil {
+		t.Fatalf("NewVMSession failed: %v", err)
+	}
+	defer session.Close()
+
+	script := `echo "runner_id=42" >> "$ORCHARD_OUTPUT"
+ls -l "$ORCHARD_OUTPUT" | cut -c1-10
+`
+	result, err := session.ExecuteScript(ctx, script, ScriptOptions{
+		RunAs:     "root",
+		OutputVar: "ORCHARD_OUTPUT",
+	})
+	if err != nil {
+		t.Fatalf("ExecuteScript failed: %v", err)
+	}
+
+	if result.ExitCode != 0 {
+		t.Errorf("ExitCode = %d, want 0\nStderr: %s", result.ExitCode, result.Stderr)
+	}
+
+	expected := "runner_id=42\n"
+	if result.Output != expected {
+		t.Errorf("Output = %q, want %q", result.Output, expected)
+	}
+	if mode := strings.TrimSpace(result.Stdout); mode != "-rw-------" {
+		t.Errorf("output file mode = %q, want -rw-------", mode)
+	}
+}
+
+func TestIntegration_ExecuteScriptMarker(t *testing.T) {
+	config := getTestConfig(t)
+	ctx := context.Background()
+
+	session, err := NewVMSession(ctx, config)
+	if err != nil {
+		t.Fatalf("NewVMSession failed: %v", err)
+	}
+	defer session.Close()
+
+	for _, marked := range []bool{true, false} {
+		script := "true\n"
+		if marked {
+			script = `touch "$ORCHARD_REBOOT_MARKER"` + "\n"
+		}
+		result, err := session.ExecuteScript(ctx, script, ScriptOptions{MarkerVar: "ORCHARD_REBOOT_MARKER"})
+		if err != nil {
+			t.Fatalf("ExecuteScript failed: %v", err)
+		}
+		if result.Marked != marked {
+			t.Errorf("Marked = %t, want %t", result.Marked, marked)
+		}
+	}
+}
+
 func TestIntegration_RunCloudInit(t *testing.T) {
 	config := getTestConfig(t)
 	ctx := context.Background()
@@ -528,7 +621,7 @@ echo "Cloud-init simulation"
 whoami
 pwd
 `
-	result, err := RunCloudInit(ctx, config, script, nil)
+	result, err := RunCloudInit(ctx, config, script, ScriptOptions{})
 	if err != nil {
 		t.Fatalf("RunCloudInit failed: %v", err)
 	}
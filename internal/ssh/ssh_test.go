@@ -17,6 +17,10 @@ limitations under the License.
 package ssh
 
 import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -207,3 +211,87 @@ func TestFileUploadOptions(t *testing.T) {
 		t.Error("CreateDirs = false, want true")
 	}
 }
+
+func TestScriptCommand(t *testing.T) {
+	path := "/tmp/orchard-script-0123456789abcdef.sh"
+	cleanup := "; status=$?; rm -f '" + path + "'; exit $status"
+
+	tests := []struct {
+		name     string
+		opts     ScriptOptions
+		expected string
+	}{
+		{
+			name:     "runs the script by its shebang",
+			expected: "('" + path + "')" + cleanup,
+		},
+		{
+			name:     "exports env in a stable order and quotes values",
+			opts:     ScriptOptions{Env: map[string]string{"B": "it's", "A": "1"}},
+			expected: "(export A='1'; export B='it'\"'\"'s'; '" + path + "')" + cleanup,
+		},
+		{
+			name:     "runs the script with an interpreter in a working directory",
+			opts:     ScriptOptions{Interpreter: "/usr/bin/env python3", WorkingDir: "/opt/app"},
+			expected: "(cd '/opt/app' && /usr/bin/env python3 '" + path + "')" + cleanup,
+		},
+		{
+			name:     "runs the script as another user with sudo",
+			opts:     ScriptOptions{RunAs: "root", Interpreter: "/bin/zsh"},
+			expected: "sudo -S -p '' -H -u 'root' -- /bin/sh -c 'exec </dev/null; /bin/zsh '\"'\"'" + path + "'\"'\"''" + cleanup,
+		},
+		{
+			name:     "runs the script as another user with a custom escalation",
+			opts:     ScriptOptions{RunAs: "root", Escalation: Escalation{Command: "sudo -n -u %s --", Password: PasswordNone}},
+			expected: "sudo -n -u 'root' -- /bin/sh -c 'exec </dev/null; '\"'\"'" + path + "'\"'\"''" + cleanup,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scriptCommand(path, tt.opts); got != tt.expected {
+				t.Errorf("scriptCommand() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUniqueScriptPath(t *testing.T) {
+	a, err := uniqueScriptPath("")
+	if err != nil {
+		t.Fatalf("uniqueScriptPath() error = %v", err)
+	}
+	b, err := uniqueScriptPath("/tmp/step")
+	if err != nil {
+		t.Fatalf("uniqueScriptPath() error = %v", err)
+	}
+
+	if !strings.HasPrefix(a, "/tmp/orchard-script-") || !strings.HasSuffix(a, ".sh") {
+		t.Errorf("uniqueScriptPath(\"\") = %q, want /tmp/orchard-script-*.sh", a)
+	}
+	if !strings.HasPrefix(b, "/tmp/step-") {
+		t.Errorf("uniqueScriptPath(\"/tmp/step\") = %q, want /tmp/step-*.sh", b)
+	}
+	if c, _ := uniqueScriptPath("/tmp/step"); c == b {
+		t.Errorf("uniqueScriptPath() returned %q twice", c)
+	}
+}
+
+func TestCreateFileCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.out")
+
+	if out, err := exec.Command("/bin/sh", "-c", createFileCommand(path)).CombinedOutput(); err != nil {
+		t.Fatalf("createFileCommand() failed: %v: %s", err, out)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("createFileCommand() did not create the file: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("createFileCommand() mode = %04o, want 0600", mode)
+	}
+
+	if err := exec.Command("/bin/sh", "-c", createFileCommand(path)).Run(); err == nil {
+		t.Error("createFileCommand() succeeded although the file exists")
+	}
+}
@@ -71,6 +71,21 @@ func dialWebSocket(ctx context.Context, config TunnelConfig) (net.Conn, error) {
 	return newWSNetConn(ctx, ws), nil
 }
 
+// DialPort opens a connection to a port of the VM in config through Orchard's
+// port-forward endpoint, e.g. to probe a service running on the VM.
+func DialPort(ctx context.Context, config TunnelConfig, port int) (net.Conn, error) {
+	config.SetDefaults()
+	config.SSHPort = port
+	return dialWebSocket(ctx, config)
+}
+
+// PortForwardURL returns the WebSocket URL of Orchard's port-forward endpoint
+// for the VM and port in config. Clients must authenticate with the bearer
+// token when dialing it.
+func PortForwardURL(config TunnelConfig) (string, error) {
+	return buildWebSocketURL(config)
+}
+
 // buildWebSocketURL constructs the WebSocket URL for the port-forward endpoint.
 func buildWebSocketURL(config TunnelConfig) (string, error) {
 	baseURL, err := url.Parse(config.OrchardBaseURL)